@@ -3,6 +3,9 @@ package main
 import (
 	"encoding/hex"
 	"fmt"
+	"golang-bitcoin/pkg/address"
+	"golang-bitcoin/pkg/chaincfg"
+	"golang-bitcoin/pkg/message"
 	"golang-bitcoin/pkg/privkey"
 	"golang-bitcoin/pkg/script"
 	"golang-bitcoin/pkg/transaction"
@@ -19,42 +22,69 @@ const (
 
 // NOTE: PubKey Address: mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm
 
+// NOTE: ネットワークは環境変数 NETWORK で指定する (main, test, testnet4, signet, regtest)。デフォルトは test
+// NOTE: 引数なしで実行した場合はトランザクションを作成する
+// NOTE: signmessage <legacy|p2wpkh|p2tr> <message>
+// NOTE: verifymessage <address|scriptPubKey hex> <signature> <message>
 func main() {
 	godotenv.Load()
 
-	secretString := os.Getenv("SECRET_STRING")
-	secret := new(big.Int).SetBytes([]byte(secretString))
-	fmt.Println("Secret:", secret.Text(16))
-	privKey := privkey.NewPrivKey(secret)
-	fmt.Println("WIF:", privKey.WIF(true, true))
+	params, err := loadParams()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "signmessage":
+			err = signMessage(os.Args[2:], params)
+		case "verifymessage":
+			err = verifyMessage(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command: %s", os.Args[1])
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	privKey, err := loadPrivKey(params)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Secret:", privKey.Secret().Text(16))
+	fmt.Println("WIF:", privKey.WIF(true, params))
 	pubKey := privKey.PubKey()
 	fmt.Printf("Pubkey:\n%s\n", hex.EncodeToString(pubKey.Serialize(true)))
-	fmt.Println("Pubkey Address:", pubKey.Address(true, true))
+	fmt.Println("Pubkey Address:", pubKey.Address(true, params))
 
 	// NOTE: 使いたいトランザクションのID
 	prevOutputHash, _ := hex.DecodeString("ec1728d31875b50e0f17f2e475eb43819d54b696ab8b114dbda029ed52a03941")
 	prevOutputIndex := uint32(1)
 	txIn := transaction.NewInput(prevOutputHash, prevOutputIndex, nil, 0xffffffff)
 
-	scriptPubKey, err := script.NewP2PKHScriptPubkey(sendbackAddress)
+	sendback, err := address.Decode(sendbackAddress, params)
 	if err != nil {
 		panic(err)
 	}
-	txOut := transaction.NewOutput(0.00015627*satoshiPerBitcoin, scriptPubKey)
+	txOut := transaction.NewOutput(0.00015627*satoshiPerBitcoin, sendback.ScriptPubKey())
 
 	lockTime := uint32(0)
 
 	tx := transaction.NewTransaction(1, []*transaction.Input{txIn}, []*transaction.Output{txOut}, lockTime, false)
 
-	sigHash, err := tx.SigHash(0, true)
+	sigHash, err := tx.SigHash(0, script.SIGHASH_ALL, nil, transaction.NewTransactionFetcher(params))
 	if err != nil {
 		panic(err)
 	}
 	z := new(big.Int).SetBytes(sigHash)
-	sig := privKey.SignWithK(z, big.NewInt(1))
+	sig := privKey.Sign(z)
 	serializedSig := sig.Serialize()
 	serializedPubKey := pubKey.Serialize(true)
-	scriptSig := script.NewScriptSig(serializedSig, serializedPubKey)
+	scriptSig := script.NewScriptSig(serializedSig, serializedPubKey, script.SIGHASH_ALL)
 	tx.Inputs[0].ScriptSig = scriptSig
 
 	serializedScriptSig, _ := scriptSig.Serialize()
@@ -71,3 +101,113 @@ func main() {
 	fmt.Println("TransactionID: ", txID)
 	fmt.Printf("Transaction:\n%s\n", hex.EncodeToString(serialized))
 }
+
+func loadParams() (*chaincfg.Params, error) {
+	name := os.Getenv("NETWORK")
+	if name == "" {
+		return &chaincfg.TestNet3Params, nil
+	}
+	return chaincfg.ParamsByName(name)
+}
+
+// NOTE: 他のウォレットから書き出した鍵は環境変数 WIF で指定する。なければ SECRET_STRING から作る
+func loadPrivKey(params *chaincfg.Params) (privkey.PrivKey, error) {
+	if wif := os.Getenv("WIF"); wif != "" {
+		privKey, metadata, err := privkey.ParseWIF(wif)
+		if err != nil {
+			return privkey.PrivKey{}, err
+		}
+		if metadata.Params.PrivateKeyID != params.PrivateKeyID {
+			privKey.Zero()
+			return privkey.PrivKey{}, fmt.Errorf("WIF is for %s network, not %s", metadata.Params.Name, params.Name)
+		}
+		return privKey, nil
+	}
+	secretString := os.Getenv("SECRET_STRING")
+	secret := new(big.Int).SetBytes([]byte(secretString))
+	return privkey.NewPrivKey(secret), nil
+}
+
+func signMessage(args []string, params *chaincfg.Params) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: signmessage <legacy|p2wpkh|p2tr> <message>")
+	}
+	privKey, err := loadPrivKey(params)
+	if err != nil {
+		return err
+	}
+	defer privKey.Zero()
+
+	var scriptType address.ScriptType
+	var sign func(key privkey.PrivKey, msg string) (string, error)
+	switch args[0] {
+	case "legacy":
+		scriptType = address.P2PKH
+		sign = func(key privkey.PrivKey, msg string) (string, error) {
+			return message.SignMessage(key, msg, true)
+		}
+	case "p2wpkh":
+		scriptType = address.P2WPKH
+		sign = message.SignBIP322P2WPKH
+	case "p2tr":
+		scriptType = address.P2TR
+		sign = message.SignBIP322P2TR
+	default:
+		return fmt.Errorf("unknown signature type: %s", args[0])
+	}
+	addr, err := address.FromPubKey(privKey.PubKey(), scriptType, params)
+	if err != nil {
+		return err
+	}
+	sig, err := sign(privKey, args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Println("Address:", addr)
+	fmt.Println("Signature:", sig)
+	return nil
+}
+
+func verifyMessage(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: verifymessage <address|scriptPubKey hex> <signature> <message>")
+	}
+	var ok bool
+	var err error
+	// NOTE: P2PKHアドレスはlegacy形式、それ以外はBIP322として扱う
+	addr, decodeErr := decodeAddress(args[0])
+	if decodeErr == nil && addr.Type() == address.P2PKH {
+		ok, err = message.VerifyMessage(args[0], args[1], args[2], addr.Params())
+	} else {
+		var scriptPubKey *script.Script
+		if decodeErr == nil {
+			scriptPubKey = addr.ScriptPubKey()
+		} else {
+			raw, hexErr := hex.DecodeString(args[0])
+			if hexErr != nil {
+				return decodeErr
+			}
+			if scriptPubKey, err = script.ParseRawScript(raw); err != nil {
+				return err
+			}
+		}
+		ok, err = message.VerifyBIP322(scriptPubKey, args[1], args[2])
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(ok)
+	return nil
+}
+
+// NOTE: どのネットワークのアドレスでも受け付ける
+func decodeAddress(s string) (*address.Address, error) {
+	var err error
+	for _, params := range chaincfg.Networks {
+		var addr *address.Address
+		if addr, err = address.Decode(s, params); err == nil {
+			return addr, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,191 @@
+package address
+
+import (
+	"fmt"
+	"golang-bitcoin/pkg/bech32"
+	"golang-bitcoin/pkg/chaincfg"
+	"golang-bitcoin/pkg/script"
+	"golang-bitcoin/pkg/secp256k1"
+	"golang-bitcoin/pkg/utils"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+type ScriptType int
+
+const (
+	P2PKH ScriptType = iota + 1
+	P2SH
+	P2WPKH
+	P2WSH
+	P2TR
+	// NOTE: 将来のwitness versionやversion 1の32バイト以外のprogram
+	WitnessUnknown
+)
+
+// NOTE: Bitcoin Core の TxoutType と同じ名前
+func (t ScriptType) String() string {
+	switch t {
+	case P2PKH:
+		return "pubkeyhash"
+	case P2SH:
+		return "scripthash"
+	case P2WPKH:
+		return "witness_v0_keyhash"
+	case P2WSH:
+		return "witness_v0_scripthash"
+	case P2TR:
+		return "witness_v1_taproot"
+	case WitnessUnknown:
+		return "witness_unknown"
+	default:
+		return "nonstandard"
+	}
+}
+
+// NOTE: Base58CheckのP2PKH/P2SHとbech32/bech32mのwitnessアドレスをまとめて扱う
+// NOTE: P2PKH/P2SHのhashはHash160、witnessアドレスのhashはwitness program
+type Address struct {
+	params     *chaincfg.Params
+	scriptType ScriptType
+	version    int
+	hash       []byte
+}
+
+func NewP2PKHAddress(hash160 []byte, params *chaincfg.Params) (*Address, error) {
+	if len(hash160) != 20 {
+		return nil, fmt.Errorf("invalid hash160 length: %d", len(hash160))
+	}
+	return &Address{params: params, scriptType: P2PKH, hash: hash160}, nil
+}
+
+func NewP2SHAddress(hash160 []byte, params *chaincfg.Params) (*Address, error) {
+	if len(hash160) != 20 {
+		return nil, fmt.Errorf("invalid hash160 length: %d", len(hash160))
+	}
+	return &Address{params: params, scriptType: P2SH, hash: hash160}, nil
+}
+
+func NewWitnessAddress(version int, program []byte, params *chaincfg.Params) (*Address, error) {
+	// NOTE: versionやprogramの長さの検証はbech32の符号化と同じ
+	if _, err := bech32.EncodeSegwitAddress(params.Bech32HRP, version, program); err != nil {
+		return nil, err
+	}
+	scriptType := WitnessUnknown
+	switch {
+	case version == 0 && len(program) == 20:
+		scriptType = P2WPKH
+	case version == 0 && len(program) == 32:
+		scriptType = P2WSH
+	case version == 1 && len(program) == 32:
+		scriptType = P2TR
+	}
+	return &Address{params: params, scriptType: scriptType, version: version, hash: program}, nil
+}
+
+// NOTE: 圧縮公開鍵からscriptTypeのアドレスを作る。P2SHやP2WSHは公開鍵だけでは決まらないのでエラーにする
+func FromPubKey(pubkey secp256k1.Secp256k1Point, scriptType ScriptType, params *chaincfg.Params) (*Address, error) {
+	switch scriptType {
+	case P2PKH:
+		return NewP2PKHAddress(utils.Hash160(pubkey.Serialize(true)), params)
+	case P2WPKH:
+		return NewWitnessAddress(0, utils.Hash160(pubkey.Serialize(true)), params)
+	case P2TR:
+		outputKey, err := pubkey.TaprootTweak(nil)
+		if err != nil {
+			return nil, err
+		}
+		return NewWitnessAddress(1, outputKey.SerializeXOnly(), params)
+	default:
+		return nil, fmt.Errorf("cannot derive %s address from a public key", scriptType)
+	}
+}
+
+// NOTE: 文字列のアドレスを読み、paramsのネットワークのアドレスでなければエラーにする
+func Decode(s string, params *chaincfg.Params) (*Address, error) {
+	if version, hash160, err := secp256k1.DecodeBase58Address(s); err == nil {
+		switch version {
+		case params.PubKeyHashAddrID:
+			return NewP2PKHAddress(hash160, params)
+		case params.ScriptHashAddrID:
+			return NewP2SHAddress(hash160, params)
+		default:
+			return nil, fmt.Errorf("address version 0x%02x is not for %s network: %s", version, params.Name, s)
+		}
+	}
+	hrp, _, _, err := bech32.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %s", s)
+	}
+	if hrp != params.Bech32HRP {
+		return nil, fmt.Errorf("address hrp %s is not for %s network: %s", hrp, params.Name, s)
+	}
+	version, program, err := bech32.DecodeSegwitAddress(params.Bech32HRP, s)
+	if err != nil {
+		return nil, err
+	}
+	return NewWitnessAddress(version, program, params)
+}
+
+// NOTE: 表示のためにscriptPubKeyからアドレスを求める。アドレスを持たないスクリプトはエラーにする
+func FromScriptPubKey(scriptPubKey *script.Script, params *chaincfg.Params) (*Address, error) {
+	if scriptPubKey.IsP2PKHScriptPubkey() {
+		return NewP2PKHAddress(scriptPubKey.Instructions[2].Data, params)
+	}
+	if scriptPubKey.IsP2SHScriptPubkey() {
+		return NewP2SHAddress(scriptPubKey.Instructions[1].Data, params)
+	}
+	if version, program, ok := scriptPubKey.WitnessProgram(); ok {
+		return NewWitnessAddress(version, program, params)
+	}
+	return nil, fmt.Errorf("script has no address")
+}
+
+func (a *Address) Params() *chaincfg.Params {
+	return a.params
+}
+
+func (a *Address) Type() ScriptType {
+	return a.scriptType
+}
+
+// NOTE: P2PKH/P2SHはHash160、witnessアドレスはwitness program
+func (a *Address) Hash() []byte {
+	return a.hash
+}
+
+// NOTE: witnessアドレスでなければ-1を返す
+func (a *Address) WitnessVersion() int {
+	if a.scriptType == P2PKH || a.scriptType == P2SH {
+		return -1
+	}
+	return a.version
+}
+
+func (a *Address) ScriptPubKey() *script.Script {
+	switch a.scriptType {
+	case P2PKH:
+		return script.NewP2PKHScriptFromHash160(a.hash)
+	case P2SH:
+		return script.NewP2SHScriptFromHash160(a.hash)
+	default:
+		return script.NewWitnessScriptPubkey(a.version, a.hash)
+	}
+}
+
+func (a *Address) String() string {
+	switch a.scriptType {
+	case P2PKH, P2SH:
+		prefix := a.params.PubKeyHashAddrID
+		if a.scriptType == P2SH {
+			prefix = a.params.ScriptHashAddrID
+		}
+		joint := append([]byte{prefix}, a.hash...)
+		checksum := utils.Hash256(joint)[:4]
+		return base58.Encode(append(joint, checksum...))
+	default:
+		// NOTE: 作成時に検証しているので符号化には失敗しない
+		encoded, _ := bech32.EncodeSegwitAddress(a.params.Bech32HRP, a.version, a.hash)
+		return encoded
+	}
+}
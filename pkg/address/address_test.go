@@ -0,0 +1,252 @@
+package address
+
+import (
+	"encoding/hex"
+	"golang-bitcoin/pkg/chaincfg"
+	"golang-bitcoin/pkg/script"
+	"golang-bitcoin/pkg/secp256k1"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name             string
+		address          string
+		params           *chaincfg.Params
+		wantType         ScriptType
+		wantScriptPubKey string
+		wantErr          bool
+	}{
+		{
+			name:             "mainnet p2pkh",
+			address:          "1F1Pn2y6pDb68E5nYJJeba4TLg2U7B6KF1",
+			params:           &chaincfg.MainNetParams,
+			wantType:         P2PKH,
+			wantScriptPubKey: "76a91499a4c61750789253f69fd750ac0d02126337330588ac",
+		},
+		{
+			name:             "testnet p2pkh",
+			address:          "mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm",
+			params:           &chaincfg.TestNet3Params,
+			wantType:         P2PKH,
+			wantScriptPubKey: "76a9147c78d7b2146fbd9200fcbc12e72a528c08b563e588ac",
+		},
+		{
+			name:             "regtest p2pkh",
+			address:          "mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm",
+			params:           &chaincfg.RegressionNetParams,
+			wantType:         P2PKH,
+			wantScriptPubKey: "76a9147c78d7b2146fbd9200fcbc12e72a528c08b563e588ac",
+		},
+		{
+			name:             "signet p2pkh",
+			address:          "mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm",
+			params:           &chaincfg.SigNetParams,
+			wantType:         P2PKH,
+			wantScriptPubKey: "76a9147c78d7b2146fbd9200fcbc12e72a528c08b563e588ac",
+		},
+		{
+			name:             "mainnet p2sh",
+			address:          "3CLoMMyuoDQTPRD3XYZtCvgvkadrAdvdXh",
+			params:           &chaincfg.MainNetParams,
+			wantType:         P2SH,
+			wantScriptPubKey: "a91474d691da1574e6b3c192ecfb52cc8984ee7b6c5687",
+		},
+		{
+			name:             "testnet p2sh",
+			address:          "2N2JD6wb56AfK4tfmM6PwdVmoYk2dCKf4Br",
+			params:           &chaincfg.TestNet3Params,
+			wantType:         P2SH,
+			wantScriptPubKey: "a9146349a418fc4578d10a372b54b45c280cc8c4382f87",
+		},
+		{
+			name:             "mainnet p2wpkh",
+			address:          "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4",
+			params:           &chaincfg.MainNetParams,
+			wantType:         P2WPKH,
+			wantScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+		},
+		{
+			name:             "testnet p2wsh",
+			address:          "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7",
+			params:           &chaincfg.TestNet3Params,
+			wantType:         P2WSH,
+			wantScriptPubKey: "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262",
+		},
+		{
+			name:             "mainnet p2tr",
+			address:          "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+			params:           &chaincfg.MainNetParams,
+			wantType:         P2TR,
+			wantScriptPubKey: "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		},
+		{
+			name:             "future witness version",
+			address:          "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs",
+			params:           &chaincfg.MainNetParams,
+			wantType:         WitnessUnknown,
+			wantScriptPubKey: "5210751e76e8199196d454941c45d1b3a323",
+		},
+		{
+			name:             "regtest p2wpkh",
+			address:          "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080",
+			params:           &chaincfg.RegressionNetParams,
+			wantType:         P2WPKH,
+			wantScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+		},
+		{
+			name:    "mainnet p2pkh on testnet",
+			address: "1F1Pn2y6pDb68E5nYJJeba4TLg2U7B6KF1",
+			params:  &chaincfg.TestNet3Params,
+			wantErr: true,
+		},
+		{
+			name:    "testnet p2sh on mainnet",
+			address: "2N2JD6wb56AfK4tfmM6PwdVmoYk2dCKf4Br",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "mainnet bech32 on testnet",
+			address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+			params:  &chaincfg.TestNet3Params,
+			wantErr: true,
+		},
+		{
+			name:    "testnet bech32 on regtest",
+			address: "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7",
+			params:  &chaincfg.RegressionNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "invalid base58 checksum",
+			address: "1F1Pn2y6pDb68E5nYJJeba4TLg2U7B6KF2",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "version 1 with bech32 checksum",
+			address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.address, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Type() != tt.wantType {
+				t.Errorf("Address.Type() = %v, want %v", got.Type(), tt.wantType)
+			}
+			serialized, err := got.ScriptPubKey().Serialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(serialized) != tt.wantScriptPubKey {
+				t.Errorf("Address.ScriptPubKey() = %x, want %v", serialized, tt.wantScriptPubKey)
+			}
+			// NOTE: scriptPubKeyから同じアドレスに戻る。bech32は小文字で表示する
+			scriptPubKey, err := script.ParseRawScript(serialized)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromScript, err := FromScriptPubKey(scriptPubKey, tt.params)
+			if err != nil {
+				t.Fatalf("FromScriptPubKey() error = %v", err)
+			}
+			want := tt.address
+			if tt.wantType != P2PKH && tt.wantType != P2SH {
+				want = strings.ToLower(want)
+			}
+			if fromScript.String() != want {
+				t.Errorf("FromScriptPubKey().String() = %v, want %v", fromScript.String(), want)
+			}
+		})
+	}
+}
+
+func TestFromScriptPubKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		scriptPubKey string
+		wantErr      bool
+	}{
+		{
+			name:         "p2pk",
+			scriptPubKey: "210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798ac",
+			wantErr:      true,
+		},
+		{
+			name:         "op_return",
+			scriptPubKey: "6a0474657374",
+			wantErr:      true,
+		},
+		{
+			name:         "invalid version 0 program length",
+			scriptPubKey: "0010751e76e8199196d454941c45d1b3a323",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.scriptPubKey)
+			scriptPubKey, err := script.ParseRawScript(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := FromScriptPubKey(scriptPubKey, &chaincfg.MainNetParams); (err != nil) != tt.wantErr {
+				t.Errorf("FromScriptPubKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFromPubKey(t *testing.T) {
+	g := secp256k1.NewSecp256k1G()
+	tests := []struct {
+		name       string
+		scriptType ScriptType
+		params     *chaincfg.Params
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "p2pkh",
+			scriptType: P2PKH,
+			params:     &chaincfg.MainNetParams,
+			want:       "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+		},
+		{
+			name:       "p2wpkh",
+			scriptType: P2WPKH,
+			params:     &chaincfg.TestNet3Params,
+			want:       "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx",
+		},
+		{
+			name:       "p2sh",
+			scriptType: P2SH,
+			params:     &chaincfg.MainNetParams,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromPubKey(g, tt.scriptType, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromPubKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("FromPubKey() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
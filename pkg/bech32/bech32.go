@@ -0,0 +1,242 @@
+package bech32
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Encoding int
+
+const (
+	// NOTE: BIP173 witness version 0で使う
+	Bech32 Encoding = iota + 1
+	// NOTE: BIP350 witness version 1以降で使う
+	Bech32m
+)
+
+const (
+	charset        = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32Const    = 1
+	bech32mConst   = 0x2bc830a3
+	maxLength      = 90
+	checksumLength = 6
+)
+
+func (e Encoding) checksumConst() uint32 {
+	if e == Bech32m {
+		return bech32mConst
+	}
+	return bech32Const
+}
+
+func (e Encoding) String() string {
+	switch e {
+	case Bech32:
+		return "bech32"
+	case Bech32m:
+		return "bech32m"
+	default:
+		return "unknown"
+	}
+}
+
+// NOTE: BCH符号の剰余を計算する
+func polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// NOTE: HRPの各文字の上位3bit、0、下位5bitを並べてチェックサムの計算に含める
+func hrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&0x1f)
+	}
+	return expanded
+}
+
+func createChecksum(hrp string, data []byte, enc Encoding) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, make([]byte, checksumLength)...)
+	mod := polymod(values) ^ enc.checksumConst()
+	checksum := make([]byte, checksumLength)
+	for i := range checksum {
+		checksum[i] = byte(mod>>(5*(5-i))) & 0x1f
+	}
+	return checksum
+}
+
+// NOTE: dataは5bitずつの値。HRPは小文字で出力する
+func Encode(hrp string, data []byte, enc Encoding) (string, error) {
+	if enc != Bech32 && enc != Bech32m {
+		return "", fmt.Errorf("unknown encoding: %d", enc)
+	}
+	if len(hrp) < 1 || len(hrp) > 83 {
+		return "", fmt.Errorf("invalid hrp length: %d", len(hrp))
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", fmt.Errorf("invalid hrp character: %q", hrp[i])
+		}
+	}
+	if len(hrp)+1+len(data)+checksumLength > maxLength {
+		return "", fmt.Errorf("bech32 string is too long")
+	}
+	hrp = strings.ToLower(hrp)
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range data {
+		if d > 31 {
+			return "", fmt.Errorf("invalid data value: %d", d)
+		}
+		sb.WriteByte(charset[d])
+	}
+	for _, d := range createChecksum(hrp, data, enc) {
+		sb.WriteByte(charset[d])
+	}
+	return sb.String(), nil
+}
+
+// NOTE: チェックサムの定数からbech32とbech32mのどちらで符号化されているかを判定する
+// NOTE: 返すdataは5bitずつの値で、HRPは小文字に揃える
+func Decode(s string) (string, []byte, Encoding, error) {
+	if len(s) > maxLength {
+		return "", nil, 0, fmt.Errorf("bech32 string is too long: %d", len(s))
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return "", nil, 0, fmt.Errorf("invalid character: %q", s[i])
+		}
+	}
+	lower := strings.ToLower(s)
+	if lower != s && strings.ToUpper(s) != s {
+		return "", nil, 0, fmt.Errorf("mixed case string")
+	}
+	sep := strings.LastIndexByte(lower, '1')
+	if sep < 1 {
+		return "", nil, 0, fmt.Errorf("missing hrp or separator")
+	}
+	if sep+1+checksumLength > len(lower) {
+		return "", nil, 0, fmt.Errorf("checksum is too short")
+	}
+	hrp := lower[:sep]
+	data := make([]byte, 0, len(lower)-sep-1)
+	for i := sep + 1; i < len(lower); i++ {
+		d := strings.IndexByte(charset, lower[i])
+		if d < 0 {
+			return "", nil, 0, fmt.Errorf("invalid data character: %q", lower[i])
+		}
+		data = append(data, byte(d))
+	}
+	var enc Encoding
+	switch polymod(append(hrpExpand(hrp), data...)) {
+	case bech32Const:
+		enc = Bech32
+	case bech32mConst:
+		enc = Bech32m
+	default:
+		return "", nil, 0, fmt.Errorf("invalid checksum")
+	}
+	return hrp, data[:len(data)-checksumLength], enc, nil
+}
+
+// NOTE: fromBitsずつの値をtoBitsずつの値に詰め直す
+// NOTE: padがfalseの場合、余ったビットはfromBits未満かつ全て0でなければならない
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxValue := uint32(1)<<toBits - 1
+	converted := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, d := range data {
+		if uint32(d)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data value: %d", d)
+		}
+		acc = acc<<fromBits | uint32(d)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			converted = append(converted, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			converted = append(converted, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits {
+		return nil, fmt.Errorf("excess padding")
+	} else if acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("non-zero padding")
+	}
+	return converted, nil
+}
+
+// NOTE: witness version 0はbech32、1以降はbech32mで符号化する
+func EncodeSegwitAddress(hrp string, version int, program []byte) (string, error) {
+	if err := checkWitnessProgram(version, program); err != nil {
+		return "", err
+	}
+	enc := Bech32
+	if version > 0 {
+		enc = Bech32m
+	}
+	converted, err := ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return Encode(hrp, append([]byte{byte(version)}, converted...), enc)
+}
+
+// NOTE: hrpが一致しないアドレスは別のネットワークのものとしてエラーにする
+func DecodeSegwitAddress(hrp string, address string) (int, []byte, error) {
+	decodedHRP, data, enc, err := Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if decodedHRP != strings.ToLower(hrp) {
+		return 0, nil, fmt.Errorf("unexpected hrp: %s", decodedHRP)
+	}
+	if len(data) < 1 {
+		return 0, nil, fmt.Errorf("empty data section")
+	}
+	version := int(data[0])
+	program, err := ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := checkWitnessProgram(version, program); err != nil {
+		return 0, nil, err
+	}
+	if version == 0 && enc != Bech32 || version > 0 && enc != Bech32m {
+		return 0, nil, fmt.Errorf("witness version %d must not use %s", version, enc)
+	}
+	return version, program, nil
+}
+
+// NOTE: BIP141 versionは0-16、programは2-40バイト。version 0のprogramは20か32バイト
+func checkWitnessProgram(version int, program []byte) error {
+	if version < 0 || version > 16 {
+		return fmt.Errorf("invalid witness version: %d", version)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return fmt.Errorf("invalid witness program length: %d", len(program))
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return fmt.Errorf("invalid witness v0 program length: %d", len(program))
+	}
+	return nil
+}
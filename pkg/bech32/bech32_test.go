@@ -0,0 +1,191 @@
+package bech32
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// NOTE: BIP173, BIP350 のテストベクタ
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Encoding
+		wantErr bool
+	}{
+		{name: "bech32 uppercase", s: "A12UEL5L", want: Bech32},
+		{name: "bech32 lowercase", s: "a12uel5l", want: Bech32},
+		{name: "bech32 long hrp", s: "an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", want: Bech32},
+		{name: "bech32 charset", s: "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", want: Bech32},
+		{name: "bech32 long data", s: "11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", want: Bech32},
+		{name: "bech32 split", s: "split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", want: Bech32},
+		{name: "bech32 question mark hrp", s: "?1ezyfcl", want: Bech32},
+		{name: "bech32m uppercase", s: "A1LQFN3A", want: Bech32m},
+		{name: "bech32m lowercase", s: "a1lqfn3a", want: Bech32m},
+		{name: "bech32m long hrp", s: "an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6", want: Bech32m},
+		{name: "bech32m charset", s: "abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", want: Bech32m},
+		{name: "bech32m long data", s: "11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8", want: Bech32m},
+		{name: "bech32m split", s: "split1checkupstagehandshakeupstreamerranterredcaperredlc445v", want: Bech32m},
+		{name: "bech32m question mark hrp", s: "?1v759aa", want: Bech32m},
+		{name: "hrp character out of range (space)", s: "\x201xj0phk", wantErr: true},
+		{name: "hrp character out of range (DEL)", s: "\x7f1g6xzxy", wantErr: true},
+		{name: "hrp character out of range (0x80)", s: "\x801vctc34", wantErr: true},
+		{name: "overall max length exceeded", s: "an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4", wantErr: true},
+		{name: "no separator", s: "qyrz8wqd2c9m", wantErr: true},
+		{name: "empty hrp", s: "1qyrz8wqd2c9m", wantErr: true},
+		{name: "invalid data character", s: "y1b0jsk6g", wantErr: true},
+		{name: "invalid data character i", s: "lt1igcx5c0", wantErr: true},
+		{name: "too short checksum", s: "in1muywd", wantErr: true},
+		{name: "invalid character in checksum", s: "mm1crxm3i", wantErr: true},
+		{name: "invalid character in checksum o", s: "au1s5cgom", wantErr: true},
+		{name: "checksum calculated with uppercase hrp", s: "M1VUXWEZ", wantErr: true},
+		{name: "empty hrp with checksum", s: "16plkw9", wantErr: true},
+		{name: "empty hrp with data", s: "1p2gdwpf", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hrp, data, got, err := Decode(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Decode() encoding = %v, want %v", got, tt.want)
+			}
+			// NOTE: 小文字に揃えて再エンコードすると元に戻る
+			encoded, err := Encode(hrp, data, got)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if encoded != strings.ToLower(tt.s) {
+				t.Errorf("Encode() = %v, want %v", encoded, strings.ToLower(tt.s))
+			}
+		})
+	}
+}
+
+func TestDecodeSegwitAddress(t *testing.T) {
+	tests := []struct {
+		name             string
+		address          string
+		hrp              string
+		wantScriptPubKey string
+		wantErr          bool
+	}{
+		{
+			name:             "mainnet p2wpkh",
+			address:          "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4",
+			hrp:              "bc",
+			wantScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+		},
+		{
+			name:             "testnet p2wsh",
+			address:          "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7",
+			hrp:              "tb",
+			wantScriptPubKey: "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262",
+		},
+		{
+			name:             "version 1 with 40-byte program",
+			address:          "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y",
+			hrp:              "bc",
+			wantScriptPubKey: "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6",
+		},
+		{
+			name:             "version 16",
+			address:          "BC1SW50QGDZ25J",
+			hrp:              "bc",
+			wantScriptPubKey: "6002751e",
+		},
+		{
+			name:             "version 2",
+			address:          "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs",
+			hrp:              "bc",
+			wantScriptPubKey: "5210751e76e8199196d454941c45d1b3a323",
+		},
+		{
+			name:             "testnet p2wsh with leading zeros",
+			address:          "tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy",
+			hrp:              "tb",
+			wantScriptPubKey: "0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433",
+		},
+		{
+			name:             "testnet p2tr",
+			address:          "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c",
+			hrp:              "tb",
+			wantScriptPubKey: "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433",
+		},
+		{
+			name:             "mainnet p2tr",
+			address:          "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+			hrp:              "bc",
+			wantScriptPubKey: "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		},
+		{name: "invalid hrp", address: "tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut", hrp: "tb", wantErr: true},
+		{name: "unexpected hrp", address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", hrp: "tb", wantErr: true},
+		{name: "version 1 with bech32", address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", hrp: "bc", wantErr: true},
+		{name: "version 2 with bech32", address: "tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf", hrp: "tb", wantErr: true},
+		{name: "version 16 with bech32", address: "BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL", hrp: "bc", wantErr: true},
+		{name: "version 0 with bech32m", address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", hrp: "bc", wantErr: true},
+		{name: "testnet version 0 with bech32m", address: "tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47", hrp: "tb", wantErr: true},
+		{name: "invalid character in checksum", address: "bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4", hrp: "bc", wantErr: true},
+		{name: "invalid witness version", address: "BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R", hrp: "bc", wantErr: true},
+		{name: "program too short", address: "bc1pw5dgrnzv", hrp: "bc", wantErr: true},
+		{name: "program too long", address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav", hrp: "bc", wantErr: true},
+		{name: "invalid version 0 program length", address: "BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", hrp: "bc", wantErr: true},
+		{name: "mixed case", address: "tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq", hrp: "tb", wantErr: true},
+		{name: "zero padding of more than 4 bits", address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf", hrp: "bc", wantErr: true},
+		{name: "non-zero padding", address: "tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j", hrp: "tb", wantErr: true},
+		{name: "empty data section", address: "bc1gmk9yu", hrp: "bc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, program, err := DecodeSegwitAddress(tt.hrp, tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeSegwitAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// NOTE: OP_0 または OP_1-OP_16 に続けてprogramをpushする
+			op := byte(0x00)
+			if version > 0 {
+				op = byte(0x50 + version)
+			}
+			scriptPubKey := append([]byte{op, byte(len(program))}, program...)
+			if got := hex.EncodeToString(scriptPubKey); got != tt.wantScriptPubKey {
+				t.Errorf("DecodeSegwitAddress() scriptPubKey = %v, want %v", got, tt.wantScriptPubKey)
+			}
+			encoded, err := EncodeSegwitAddress(tt.hrp, version, program)
+			if err != nil {
+				t.Fatalf("EncodeSegwitAddress() error = %v", err)
+			}
+			if encoded != strings.ToLower(tt.address) {
+				t.Errorf("EncodeSegwitAddress() = %v, want %v", encoded, strings.ToLower(tt.address))
+			}
+		})
+	}
+}
+
+func TestEncodeSegwitAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		program string
+		wantErr bool
+	}{
+		{name: "invalid version", version: 17, program: "751e76e8199196d454941c45d1b3a323f1433bd6", wantErr: true},
+		{name: "invalid version 0 program length", version: 0, program: "751e76e8199196d454941c45d1b3a323", wantErr: true},
+		{name: "program too short", version: 1, program: "75", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, _ := hex.DecodeString(tt.program)
+			if _, err := EncodeSegwitAddress("bc", tt.version, program); (err != nil) != tt.wantErr {
+				t.Errorf("EncodeSegwitAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package chaincfg
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"golang-bitcoin/pkg/utils"
+)
+
+// NOTE: ネットワークごとに異なる値をまとめたもの。Bitcoin Core の CChainParams に相当する
+type Params struct {
+	// NOTE: Bitcoin Core の -chain に指定する名前
+	Name string
+	// NOTE: P2Pメッセージの先頭に付けるマジックバイト
+	Magic [4]byte
+	// NOTE: P2PとJSON-RPCのデフォルトのポート
+	DefaultPort string
+	RPCPort     string
+
+	GenesisBlock BlockHeader
+	// NOTE: ジェネシスブロックのハッシュ (表示用のlittle-endian)
+	GenesisHash string
+
+	// NOTE: Base58Checkのバージョンバイト
+	PubKeyHashAddrID byte
+	ScriptHashAddrID byte
+	PrivateKeyID     byte
+	// NOTE: BIP173 bech32アドレスのHRP
+	Bech32HRP string
+
+	// NOTE: Esplora互換APIのベースURL。公開されたエクスプローラがない場合は空
+	ExplorerURL string
+}
+
+// NOTE: 80バイトのブロックヘッダ。ハッシュは表示用のlittle-endianの16進数で持つ
+type BlockHeader struct {
+	Version    uint32
+	PrevBlock  string
+	MerkleRoot string
+	Timestamp  uint32
+	Bits       uint32
+	Nonce      uint32
+}
+
+func (h *BlockHeader) Serialize() ([]byte, error) {
+	prevBlock, err := hex.DecodeString(h.PrevBlock)
+	if err != nil || len(prevBlock) != 32 {
+		return nil, fmt.Errorf("invalid previous block hash: %s", h.PrevBlock)
+	}
+	merkleRoot, err := hex.DecodeString(h.MerkleRoot)
+	if err != nil || len(merkleRoot) != 32 {
+		return nil, fmt.Errorf("invalid merkle root: %s", h.MerkleRoot)
+	}
+	serialized := make([]byte, 0, 80)
+	serialized = binary.LittleEndian.AppendUint32(serialized, h.Version)
+	serialized = append(serialized, utils.ReverseBytes(prevBlock)...)
+	serialized = append(serialized, utils.ReverseBytes(merkleRoot)...)
+	serialized = binary.LittleEndian.AppendUint32(serialized, h.Timestamp)
+	serialized = binary.LittleEndian.AppendUint32(serialized, h.Bits)
+	serialized = binary.LittleEndian.AppendUint32(serialized, h.Nonce)
+	return serialized, nil
+}
+
+func (h *BlockHeader) Hash() (string, error) {
+	serialized, err := h.Serialize()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(utils.ReverseBytes(utils.Hash256(serialized))), nil
+}
+
+const (
+	zeroHash = "0000000000000000000000000000000000000000000000000000000000000000"
+	// NOTE: "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks" のcoinbase
+	genesisMerkleRoot = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+)
+
+var MainNetParams = Params{
+	Name:        "main",
+	Magic:       [4]byte{0xf9, 0xbe, 0xb4, 0xd9},
+	DefaultPort: "8333",
+	RPCPort:     "8332",
+	GenesisBlock: BlockHeader{
+		Version:    1,
+		PrevBlock:  zeroHash,
+		MerkleRoot: genesisMerkleRoot,
+		Timestamp:  1231006505,
+		Bits:       0x1d00ffff,
+		Nonce:      2083236893,
+	},
+	GenesisHash:      "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+	PubKeyHashAddrID: 0x00,
+	ScriptHashAddrID: 0x05,
+	PrivateKeyID:     0x80,
+	Bech32HRP:        "bc",
+	ExplorerURL:      "https://blockstream.info/api",
+}
+
+var TestNet3Params = Params{
+	Name:        "test",
+	Magic:       [4]byte{0x0b, 0x11, 0x09, 0x07},
+	DefaultPort: "18333",
+	RPCPort:     "18332",
+	GenesisBlock: BlockHeader{
+		Version:    1,
+		PrevBlock:  zeroHash,
+		MerkleRoot: genesisMerkleRoot,
+		Timestamp:  1296688602,
+		Bits:       0x1d00ffff,
+		Nonce:      414098458,
+	},
+	GenesisHash:      "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	PrivateKeyID:     0xef,
+	Bech32HRP:        "tb",
+	ExplorerURL:      "https://blockstream.info/testnet/api",
+}
+
+// NOTE: BIP94 testnet4。ジェネシスブロックのcoinbaseはtestnet3までと異なる
+var TestNet4Params = Params{
+	Name:        "testnet4",
+	Magic:       [4]byte{0x1c, 0x16, 0x3f, 0x28},
+	DefaultPort: "48333",
+	RPCPort:     "48332",
+	GenesisBlock: BlockHeader{
+		Version:    1,
+		PrevBlock:  zeroHash,
+		MerkleRoot: "7aa0a7ae1e223414cb807e40cd57e667b718e42aaf9306db9102fe28912b7b4e",
+		Timestamp:  1714777860,
+		Bits:       0x1d00ffff,
+		Nonce:      393743547,
+	},
+	GenesisHash:      "00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0da8bf043",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	PrivateKeyID:     0xef,
+	Bech32HRP:        "tb",
+	ExplorerURL:      "https://mempool.space/testnet4/api",
+}
+
+// NOTE: BIP325 デフォルトのsignet。マジックバイトはchallengeから決まる
+var SigNetParams = Params{
+	Name:        "signet",
+	Magic:       [4]byte{0x0a, 0x03, 0xcf, 0x40},
+	DefaultPort: "38333",
+	RPCPort:     "38332",
+	GenesisBlock: BlockHeader{
+		Version:    1,
+		PrevBlock:  zeroHash,
+		MerkleRoot: genesisMerkleRoot,
+		Timestamp:  1598918400,
+		Bits:       0x1e0377ae,
+		Nonce:      52613770,
+	},
+	GenesisHash:      "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	PrivateKeyID:     0xef,
+	Bech32HRP:        "tb",
+	ExplorerURL:      "https://mempool.space/signet/api",
+}
+
+// NOTE: ローカルのregtestには公開されたエクスプローラがないので、フェッチャーにはWithBaseURLで指定する
+var RegressionNetParams = Params{
+	Name:        "regtest",
+	Magic:       [4]byte{0xfa, 0xbf, 0xb5, 0xda},
+	DefaultPort: "18444",
+	RPCPort:     "18443",
+	GenesisBlock: BlockHeader{
+		Version:    1,
+		PrevBlock:  zeroHash,
+		MerkleRoot: genesisMerkleRoot,
+		Timestamp:  1296688602,
+		Bits:       0x207fffff,
+		Nonce:      2,
+	},
+	GenesisHash:      "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	PrivateKeyID:     0xef,
+	Bech32HRP:        "bcrt",
+	ExplorerURL:      "",
+}
+
+// NOTE: アドレスやWIFからネットワークを推定するときに順に試す
+var Networks = []*Params{&MainNetParams, &TestNet3Params, &TestNet4Params, &SigNetParams, &RegressionNetParams}
+
+// NOTE: Bitcoin Core の -chain と同じ名前で探す。testnet3も受け付ける
+func ParamsByName(name string) (*Params, error) {
+	if name == "testnet3" {
+		return &TestNet3Params, nil
+	}
+	for _, params := range Networks {
+		if params.Name == name {
+			return params, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown network: %s", name)
+}
@@ -0,0 +1,65 @@
+package chaincfg
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// NOTE: ジェネシスブロックのヘッダから計算したハッシュがGenesisHashと一致する
+func TestBlockHeader_Hash(t *testing.T) {
+	for _, params := range Networks {
+		t.Run(params.Name, func(t *testing.T) {
+			got, err := params.GenesisBlock.Hash()
+			if err != nil {
+				t.Fatalf("BlockHeader.Hash() error = %v", err)
+			}
+			if got != params.GenesisHash {
+				t.Errorf("BlockHeader.Hash() = %v, want %v", got, params.GenesisHash)
+			}
+		})
+	}
+}
+
+func TestBlockHeader_Serialize(t *testing.T) {
+	want := "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c"
+	got, err := MainNetParams.GenesisBlock.Serialize()
+	if err != nil {
+		t.Fatalf("BlockHeader.Serialize() error = %v", err)
+	}
+	if hex.EncodeToString(got) != want {
+		t.Errorf("BlockHeader.Serialize() = %x, want %v", got, want)
+	}
+
+	invalid := MainNetParams.GenesisBlock
+	invalid.MerkleRoot = "4a5e1e"
+	if _, err := invalid.Serialize(); err == nil {
+		t.Errorf("BlockHeader.Serialize() with short merkle root error = nil, want error")
+	}
+}
+
+func TestParamsByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    *Params
+		wantErr bool
+	}{
+		{name: "main", want: &MainNetParams},
+		{name: "test", want: &TestNet3Params},
+		{name: "testnet3", want: &TestNet3Params},
+		{name: "testnet4", want: &TestNet4Params},
+		{name: "signet", want: &SigNetParams},
+		{name: "regtest", want: &RegressionNetParams},
+		{name: "mainnet", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParamsByName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParamsByName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParamsByName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"golang-bitcoin/pkg/chaincfg"
+	"golang-bitcoin/pkg/privkey"
+	"golang-bitcoin/pkg/script"
+	"golang-bitcoin/pkg/secp256k1"
+	"golang-bitcoin/pkg/signature"
+	"golang-bitcoin/pkg/transaction"
+	"golang-bitcoin/pkg/utils"
+	"io"
+	"math/big"
+)
+
+const (
+	messageMagic = "Bitcoin Signed Message:\n"
+	bip322Tag    = "BIP0322-signed-message"
+)
+
+// NOTE: signmessage で署名するハッシュ dsha256(varstr(magic) || varstr(message))
+func MagicHash(message string) ([]byte, error) {
+	serialized := make([]byte, 0)
+	for _, s := range []string{messageMagic, message} {
+		length, err := utils.SerializeVarInt(uint64(len(s)))
+		if err != nil {
+			return nil, err
+		}
+		serialized = append(serialized, length...)
+		serialized = append(serialized, s...)
+	}
+	return utils.Hash256(serialized), nil
+}
+
+// NOTE: Bitcoin Coreの signmessage と同じ形式で署名する
+// NOTE: 65バイトのコンパクトな署名をbase64で返す。compressedはアドレスの導出に使う公開鍵の形式
+func SignMessage(key privkey.PrivKey, message string, compressed bool) (string, error) {
+	hash, err := MagicHash(message)
+	if err != nil {
+		return "", err
+	}
+	sig, recid := key.SignRecoverable(new(big.Int).SetBytes(hash))
+	return base64.StdEncoding.EncodeToString(sig.SerializeCompact(recid, compressed)), nil
+}
+
+// NOTE: 署名から公開鍵を復元し、P2PKHアドレスと一致するかを確かめる (verifymessage)
+// NOTE: 署名やアドレスの形式が不正な場合や、paramsのP2PKHアドレスでない場合はエラー、鍵が一致しない場合はfalseを返す
+func VerifyMessage(address string, sig string, message string, params *chaincfg.Params) (bool, error) {
+	version, hash160, err := secp256k1.DecodeBase58Address(address)
+	if err != nil {
+		return false, err
+	}
+	if version != params.PubKeyHashAddrID {
+		return false, fmt.Errorf("address is not %s p2pkh: %s", params.Name, address)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return false, fmt.Errorf("malformed base64 signature: %w", err)
+	}
+	parsed, recid, compressed, err := signature.ParseCompactSignature(decoded)
+	if err != nil {
+		return false, err
+	}
+	hash, err := MagicHash(message)
+	if err != nil {
+		return false, err
+	}
+	pubkey, err := secp256k1.RecoverPubKey(new(big.Int).SetBytes(hash), *parsed, recid)
+	if err != nil {
+		// NOTE: 復元できない署名はどの鍵にも一致しない
+		return false, nil
+	}
+	return utils.CompareBytes(utils.Hash160(pubkey.Serialize(compressed)), hash160), nil
+}
+
+// NOTE: BIP322 メッセージのハッシュ
+func BIP322Hash(message string) []byte {
+	return utils.TaggedHash(bip322Tag, []byte(message))
+}
+
+// NOTE: BIP322 メッセージにコミットし、署名するscriptPubKeyへ支払う仮想的なトランザクション
+func newToSpend(scriptPubKey *script.Script, message string) *transaction.Transaction {
+	scriptSig := script.NewScript()
+	scriptSig.Instructions = append(scriptSig.Instructions, script.NewOpInstruction(script.OP_0), script.NewPushInstruction(BIP322Hash(message)))
+	txIn := transaction.NewInput(make([]byte, 32), 0xffffffff, scriptSig, 0)
+	txOut := transaction.NewOutput(0, scriptPubKey)
+	return transaction.NewTransaction(0, []*transaction.Input{txIn}, []*transaction.Output{txOut}, 0, false)
+}
+
+// NOTE: BIP322 to_spendを使い、OP_RETURNに出力する仮想的なトランザクション。witnessが署名になる
+func newToSign(toSpend *transaction.Transaction, witness [][]byte) (*transaction.Transaction, error) {
+	txid, err := toSpend.ID()
+	if err != nil {
+		return nil, err
+	}
+	prevOutputHash, err := hex.DecodeString(txid)
+	if err != nil {
+		return nil, err
+	}
+	txIn := transaction.NewInput(prevOutputHash, 0, script.NewScript(), 0)
+	txIn.Witness = witness
+	scriptPubKey := script.NewScript()
+	scriptPubKey.Instructions = append(scriptPubKey.Instructions, script.NewOpInstruction(script.OP_RETURN))
+	txOut := transaction.NewOutput(0, scriptPubKey)
+	return transaction.NewTransaction(0, []*transaction.Input{txIn}, []*transaction.Output{txOut}, 0, len(witness) > 0), nil
+}
+
+func newBIP322Transactions(scriptPubKey *script.Script, message string, witness [][]byte) (*transaction.Transaction, *transaction.MemoryOutputFetcher, error) {
+	toSpend := newToSpend(scriptPubKey, message)
+	toSign, err := newToSign(toSpend, witness)
+	if err != nil {
+		return nil, nil, err
+	}
+	fetcher := transaction.NewMemoryOutputFetcher()
+	if err := fetcher.AddTransaction(toSpend); err != nil {
+		return nil, nil, err
+	}
+	return toSign, fetcher, nil
+}
+
+// NOTE: BIP322 simple形式はwitnessのシリアライズをbase64にしたもの
+func encodeSimple(witness [][]byte) string {
+	input := &transaction.Input{Witness: witness}
+	return base64.StdEncoding.EncodeToString(input.SerializeWitness())
+}
+
+// NOTE: 外部から渡された署名なので、要素数や長さが残りのバイト数を超える場合は確保する前にエラーにする
+func decodeSimple(serialized []byte) ([][]byte, error) {
+	reader := bytes.NewReader(serialized)
+	numItems, err := utils.ParseVarInt(reader)
+	if err != nil {
+		return nil, fmt.Errorf("malformed witness: %w", err)
+	}
+	if numItems > uint64(reader.Len()) {
+		return nil, fmt.Errorf("malformed witness: too many items")
+	}
+	witness := make([][]byte, numItems)
+	for i := range witness {
+		itemLen, err := utils.ParseVarInt(reader)
+		if err != nil {
+			return nil, fmt.Errorf("malformed witness: %w", err)
+		}
+		if itemLen > uint64(reader.Len()) {
+			return nil, fmt.Errorf("malformed witness: item is too long")
+		}
+		witness[i] = make([]byte, itemLen)
+		if _, err := io.ReadFull(reader, witness[i]); err != nil {
+			return nil, fmt.Errorf("malformed witness: %w", err)
+		}
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("witness has trailing bytes")
+	}
+	return witness, nil
+}
+
+// NOTE: BIP322 P2WPKHアドレスの鍵でSIGHASH_ALLのECDSA署名をする
+func SignBIP322P2WPKH(key privkey.PrivKey, message string) (string, error) {
+	pubkey := key.PubKey()
+	serializedPubKey := pubkey.Serialize(true)
+	scriptPubKey := script.NewP2WPKHScriptFromHash160(utils.Hash160(serializedPubKey))
+	toSign, fetcher, err := newBIP322Transactions(scriptPubKey, message, nil)
+	if err != nil {
+		return "", err
+	}
+	hash, err := toSign.SigHashBIP143(0, script.SIGHASH_ALL, nil, nil, fetcher)
+	if err != nil {
+		return "", err
+	}
+	sig := key.Sign(new(big.Int).SetBytes(hash))
+	serializedSig := append(sig.Serialize(), script.SIGHASH_ALL)
+	return encodeSimple([][]byte{serializedSig, serializedPubKey}), nil
+}
+
+// NOTE: BIP322 スクリプトパスを持たない (BIP86) P2TRアドレスの鍵でkey path spendのSchnorr署名をする
+func SignBIP322P2TR(key privkey.PrivKey, message string) (string, error) {
+	tweaked, err := key.TaprootTweak(nil)
+	if err != nil {
+		return "", err
+	}
+	defer tweaked.Zero()
+	outputKey := tweaked.PubKey()
+	scriptPubKey := script.NewP2TRScriptFromOutputKey(outputKey.SerializeXOnly())
+	toSign, fetcher, err := newBIP322Transactions(scriptPubKey, message, nil)
+	if err != nil {
+		return "", err
+	}
+	// NOTE: SIGHASH_DEFAULT
+	hash, err := toSign.SigHashTaproot(0, 0x00, nil, 0xffffffff, fetcher)
+	if err != nil {
+		return "", err
+	}
+	sig := tweaked.SignSchnorr(hash)
+	return encodeSimple([][]byte{sig.Serialize()}), nil
+}
+
+// NOTE: BIP322 simple形式の署名を、to_signのinputとしてscriptPubKeyを満たすかどうかで検証する
+// NOTE: 署名の形式が不正な場合はエラー、スクリプトの検証に失敗した場合はfalseを返す
+func VerifyBIP322(scriptPubKey *script.Script, sig string, message string) (bool, error) {
+	decoded, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return false, fmt.Errorf("malformed base64 signature: %w", err)
+	}
+	witness, err := decodeSimple(decoded)
+	if err != nil {
+		return false, err
+	}
+	toSign, fetcher, err := newBIP322Transactions(scriptPubKey, message, witness)
+	if err != nil {
+		return false, err
+	}
+	if err := toSign.VerifyInputWithFlags(0, fetcher, script.DEFAULT_VERIFY_FLAGS); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,319 @@
+package message
+
+import (
+	"encoding/hex"
+	"golang-bitcoin/pkg/chaincfg"
+	"golang-bitcoin/pkg/privkey"
+	"golang-bitcoin/pkg/script"
+	"golang-bitcoin/pkg/utils"
+	"math/big"
+	"testing"
+)
+
+// NOTE: Bitcoin Core の test/functional/rpc_signmessage.py のテストベクタ
+const (
+	coreSecretHex = "d2b8a0116d641fe7d3036f8464628fb595b480414c13a301b3d4038c811c28b0"
+	coreAddress   = "mpLQjfK79b7CCV4VMJWEWAj5Mpx8Up5zxB"
+	coreMessage   = "This is just a test message"
+	coreSignature = "INbVnW4e6PeRmsv2Qgu8NuopvrVjkcxob+sX8OcZG0SALhWybUjzMLPdAsXI46YZGb0KQTRii+wWIQzRpG/U+S0="
+)
+
+// NOTE: BIP322 のテストベクタ (L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k)
+const (
+	bip322SecretHex = "bb051cd0dda0246f33c5a9e133ebd8e7bc02a92af6c41adc131ccd7826c5b004"
+	// NOTE: bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l
+	bip322P2WPKHProgram = "2b05d564e6a7a33c087f16e0f730d1440123799d"
+	// NOTE: bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3
+	bip322P2TRProgram = "0b34f2cc6f60d54e3fdc2d1dd053fcc393bd2db9acc8de4a7c3cc28a83d4d8e9"
+)
+
+func newTestPrivKey(secretHex string) privkey.PrivKey {
+	secret, _ := new(big.Int).SetString(secretHex, 16)
+	return privkey.NewPrivKey(secret)
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestSignMessage(t *testing.T) {
+	key := newTestPrivKey(coreSecretHex)
+	got, err := SignMessage(key, coreMessage, true)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	// NOTE: RFC6979で決定的に署名するので、Bitcoin Coreと同じ署名になる
+	if got != coreSignature {
+		t.Errorf("SignMessage() = %v, want %v", got, coreSignature)
+	}
+
+	pubkey := key.PubKey()
+	uncompressedAddress := pubkey.Address(false, &chaincfg.TestNet3Params)
+	uncompressed, err := SignMessage(key, coreMessage, false)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	if ok, err := VerifyMessage(uncompressedAddress, uncompressed, coreMessage, &chaincfg.TestNet3Params); err != nil || !ok {
+		t.Errorf("VerifyMessage() = %v, %v, want true", ok, err)
+	}
+	if ok, err := VerifyMessage(coreAddress, uncompressed, coreMessage, &chaincfg.TestNet3Params); err != nil || ok {
+		t.Errorf("VerifyMessage() with compressed address = %v, %v, want false", ok, err)
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		params  *chaincfg.Params
+		sig     string
+		message string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			address: coreAddress,
+			params:  &chaincfg.TestNet3Params,
+			sig:     coreSignature,
+			message: coreMessage,
+			want:    true,
+		},
+		{
+			name:    "wrong message",
+			address: coreAddress,
+			params:  &chaincfg.TestNet3Params,
+			sig:     coreSignature,
+			message: "This is just a test message.",
+			want:    false,
+		},
+		{
+			name:    "wrong address",
+			address: "mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm",
+			params:  &chaincfg.TestNet3Params,
+			sig:     coreSignature,
+			message: coreMessage,
+			want:    false,
+		},
+		{
+			name:    "malformed base64",
+			address: coreAddress,
+			params:  &chaincfg.TestNet3Params,
+			sig:     "INbVnW4e6PeRmsv2Qgu8Nuop!",
+			message: coreMessage,
+			wantErr: true,
+		},
+		{
+			name:    "invalid header",
+			address: coreAddress,
+			params:  &chaincfg.TestNet3Params,
+			sig:     "AdbVnW4e6PeRmsv2Qgu8NuopvrVjkcxob+sX8OcZG0SALhWybUjzMLPdAsXI46YZGb0KQTRii+wWIQzRpG/U+S0=",
+			message: coreMessage,
+			wantErr: true,
+		},
+		{
+			name:    "testnet address on mainnet",
+			address: coreAddress,
+			params:  &chaincfg.MainNetParams,
+			sig:     coreSignature,
+			message: coreMessage,
+			wantErr: true,
+		},
+		{
+			name:    "p2sh address",
+			address: "2N2JD6wb56AfK4tfmM6PwdVmoYk2dCKf4Br",
+			params:  &chaincfg.TestNet3Params,
+			sig:     coreSignature,
+			message: coreMessage,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyMessage(tt.address, tt.sig, tt.message, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBIP322Transactions(t *testing.T) {
+	scriptPubKey := script.NewP2WPKHScriptFromHash160(mustDecodeHex(bip322P2WPKHProgram))
+	tests := []struct {
+		name        string
+		message     string
+		wantHash    string
+		wantToSpend string
+		wantToSign  string
+	}{
+		{
+			name:        "empty message",
+			message:     "",
+			wantHash:    "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1",
+			wantToSpend: "c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7",
+			wantToSign:  "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6",
+		},
+		{
+			name:        "Hello World",
+			message:     "Hello World",
+			wantHash:    "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a",
+			wantToSpend: "b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b",
+			wantToSign:  "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(BIP322Hash(tt.message)); got != tt.wantHash {
+				t.Errorf("BIP322Hash() = %v, want %v", got, tt.wantHash)
+			}
+			toSpend := newToSpend(scriptPubKey, tt.message)
+			toSpendID, err := toSpend.ID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if toSpendID != tt.wantToSpend {
+				t.Errorf("to_spend ID = %v, want %v", toSpendID, tt.wantToSpend)
+			}
+			toSign, err := newToSign(toSpend, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			toSignID, err := toSign.ID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if toSignID != tt.wantToSign {
+				t.Errorf("to_sign ID = %v, want %v", toSignID, tt.wantToSign)
+			}
+		})
+	}
+}
+
+func TestVerifyBIP322(t *testing.T) {
+	p2wpkh := script.NewP2WPKHScriptFromHash160(mustDecodeHex(bip322P2WPKHProgram))
+	p2tr := script.NewP2TRScriptFromOutputKey(mustDecodeHex(bip322P2TRProgram))
+	tests := []struct {
+		name         string
+		scriptPubKey *script.Script
+		sig          string
+		message      string
+		want         bool
+		wantErr      bool
+	}{
+		{
+			name:         "p2wpkh empty message",
+			scriptPubKey: p2wpkh,
+			sig:          "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+			message:      "",
+			want:         true,
+		},
+		{
+			name:         "p2wpkh Hello World",
+			scriptPubKey: p2wpkh,
+			sig:          "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+			message:      "Hello World",
+			want:         true,
+		},
+		{
+			name:         "p2wpkh signature for another message",
+			scriptPubKey: p2wpkh,
+			sig:          "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+			message:      "Hello World",
+			want:         false,
+		},
+		{
+			name:         "p2tr Hello World",
+			scriptPubKey: p2tr,
+			sig:          "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ==",
+			message:      "Hello World",
+			want:         true,
+		},
+		{
+			name:         "p2tr signature for another key",
+			scriptPubKey: p2wpkh,
+			sig:          "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ==",
+			message:      "Hello World",
+			want:         false,
+		},
+		{
+			name:         "malformed base64",
+			scriptPubKey: p2wpkh,
+			sig:          "AkcwRAIg!",
+			message:      "",
+			wantErr:      true,
+		},
+		{
+			name:         "too many witness items",
+			scriptPubKey: p2wpkh,
+			sig:          "/f//AA==",
+			message:      "",
+			wantErr:      true,
+		},
+		{
+			name:         "trailing bytes",
+			scriptPubKey: p2wpkh,
+			sig:          "AQEAAA==",
+			message:      "",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyBIP322(tt.scriptPubKey, tt.sig, tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyBIP322() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyBIP322() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignBIP322(t *testing.T) {
+	key := newTestPrivKey(bip322SecretHex)
+	pubkey := key.PubKey()
+	if got := hex.EncodeToString(utils.Hash160(pubkey.Serialize(true))); got != bip322P2WPKHProgram {
+		t.Fatalf("p2wpkh program = %v, want %v", got, bip322P2WPKHProgram)
+	}
+	tests := []struct {
+		name         string
+		sign         func(key privkey.PrivKey, message string) (string, error)
+		scriptPubKey *script.Script
+	}{
+		{
+			name:         "p2wpkh",
+			sign:         SignBIP322P2WPKH,
+			scriptPubKey: script.NewP2WPKHScriptFromHash160(mustDecodeHex(bip322P2WPKHProgram)),
+		},
+		{
+			name:         "p2tr",
+			sign:         SignBIP322P2TR,
+			scriptPubKey: script.NewP2TRScriptFromOutputKey(mustDecodeHex(bip322P2TRProgram)),
+		},
+	}
+	for _, tt := range tests {
+		for _, message := range []string{"", "Hello World"} {
+			t.Run(tt.name+"/"+message, func(t *testing.T) {
+				sig, err := tt.sign(key, message)
+				if err != nil {
+					t.Fatalf("sign error = %v", err)
+				}
+				if ok, err := VerifyBIP322(tt.scriptPubKey, sig, message); err != nil || !ok {
+					t.Errorf("VerifyBIP322() = %v, %v, want true", ok, err)
+				}
+				if ok, err := VerifyBIP322(tt.scriptPubKey, sig, message+"!"); err != nil || ok {
+					t.Errorf("VerifyBIP322() with another message = %v, %v, want false", ok, err)
+				}
+			})
+		}
+	}
+}
@@ -2,6 +2,8 @@ package privkey
 
 import (
 	"crypto/rand"
+	"fmt"
+	"golang-bitcoin/pkg/chaincfg"
 	"golang-bitcoin/pkg/secp256k1"
 	"golang-bitcoin/pkg/signature"
 	"golang-bitcoin/pkg/utils"
@@ -14,8 +16,14 @@ type PrivKey struct {
 	secret *big.Int
 }
 
+// NOTE: 呼び出し側の big.Int を Zero で消去しないようにコピーを保持する
 func NewPrivKey(secret *big.Int) PrivKey {
-	return PrivKey{secret}
+	return PrivKey{new(big.Int).Set(secret)}
+}
+
+// NOTE: 秘密鍵を使い終わったらメモリ上から消去する。消去後は使えない
+func (p PrivKey) Zero() {
+	utils.ZeroBigInt(p.secret)
 }
 
 func (p PrivKey) Secret() *big.Int {
@@ -26,40 +34,67 @@ func (p PrivKey) Equals(other PrivKey) bool {
 	return p.secret.Cmp(other.secret) == 0
 }
 
+// NOTE: RFC6979 で決定的に生成したnonceで署名する
 func (p PrivKey) Sign(z *big.Int) *signature.Signature {
-	var r *big.Int
-	var k *big.Int
-	var err error
-	for r == nil || r.Sign() == 0 {
-		k, err = rand.Int(rand.Reader, secp256k1.NewSecp256k1n())
-		if err != nil {
-			panic(err)
-		}
-		R := secp256k1.NewSecp256k1G().Multiply(k)
-		r = R.X()
-		r.Mod(r, secp256k1.NewSecp256k1n())
-	}
+	return p.SignWithEntropy(z, nil)
+}
 
-	invK := new(big.Int).ModInverse(k, secp256k1.NewSecp256k1n())
+// NOTE: extraEntropyを渡すとnonceの導出に混ぜ込む(nilならRFC6979と同じ)
+func (p PrivKey) SignWithEntropy(z *big.Int, extraEntropy []byte) *signature.Signature {
+	sig, _ := p.signRecoverable(z, extraEntropy)
+	return sig
+}
 
-	re := new(big.Int).Mul(p.secret, r)
-	re.Mod(re, secp256k1.NewSecp256k1n())
+// NOTE: 署名と、公開鍵の復元に使うrecovery idを返す
+func (p PrivKey) SignRecoverable(z *big.Int) (*signature.Signature, byte) {
+	return p.signRecoverable(z, nil)
+}
 
-	rez := new(big.Int).Add(re, z)
-	rez.Mod(rez, secp256k1.NewSecp256k1n())
+func (p PrivKey) signRecoverable(z *big.Int, extraEntropy []byte) (*signature.Signature, byte) {
+	s256n := secp256k1.NewSecp256k1n()
+	nonce := newRFC6979(p.secret, z, extraEntropy)
+	defer nonce.clear()
+	for {
+		k := nonce.next()
+		R := secp256k1.NewSecp256k1G().MultiplyConstantTime(k)
+		r := new(big.Int).Mod(R.X(), s256n)
+		if r.Sign() == 0 {
+			utils.ZeroBigInt(k)
+			continue
+		}
 
-	s := new(big.Int).Mul(rez, invK)
-	s.Mod(s, secp256k1.NewSecp256k1n())
+		// NOTE: s = k^-1 (z + r*d) mod n。nonceと秘密鍵は固定長のスカラーで定数時間に計算する
+		invK := secp256k1.ScalarInverseConstantTime(k)
+		rez := secp256k1.ScalarMulAddConstantTime(r, p.secret, z)
+		s := secp256k1.ScalarMulAddConstantTime(rez, invK, big.NewInt(0))
+		utils.ZeroBigInt(k)
+		utils.ZeroBigInt(invK)
+		utils.ZeroBigInt(rez)
+		if s.Sign() == 0 {
+			continue
+		}
 
-	if s.Cmp(secp256k1.NewSecp256k1nHalf()) == 1 {
-		s = new(big.Int).Sub(secp256k1.NewSecp256k1n(), s)
+		// NOTE: recidの下位ビットはRのyの偶奇、上位ビットはRのx座標がn以上か
+		var recid byte
+		if R.Y().Bit(0) == 1 {
+			recid |= 1
+		}
+		if R.X().Cmp(s256n) >= 0 {
+			recid |= 2
+		}
+		sig := signature.NewSignature(r, s)
+		// NOTE: sを n - s にするとRが -R に対応するのでyの偶奇が反転する
+		if !sig.IsLowS() {
+			sig = sig.NormalizeS()
+			recid ^= 1
+		}
+		return sig, recid
 	}
-
-	return signature.NewSignature(r, s)
 }
 
+// NOTE: kを外から与える署名。同じkを使い回すと秘密鍵が漏れるのでテスト以外では Sign を使う
 func (p PrivKey) SignWithK(z *big.Int, k *big.Int) *signature.Signature {
-	R := secp256k1.NewSecp256k1G().Multiply(k)
+	R := secp256k1.NewSecp256k1G().MultiplyConstantTime(k)
 	r := R.X()
 
 	// rが0の場合は例外処理が必要
@@ -68,33 +103,103 @@ func (p PrivKey) SignWithK(z *big.Int, k *big.Int) *signature.Signature {
 	}
 
 	// kの逆数を計算
-	invK := new(big.Int).ModInverse(k, secp256k1.NewSecp256k1n())
+	// NOTE: kと秘密鍵は固定長のスカラーで定数時間に計算する
+	invK := secp256k1.ScalarInverseConstantTime(k)
+	defer utils.ZeroBigInt(invK)
 
 	// rez = (p.secret * r + z) mod p
-	rez := new(big.Int).Add(new(big.Int).Mul(p.secret, r), z)
-	rez.Mod(rez, secp256k1.NewSecp256k1n())
+	rez := secp256k1.ScalarMulAddConstantTime(p.secret, r, z)
+	defer utils.ZeroBigInt(rez)
 
 	// s = rez * invK mod p
-	s := new(big.Int).Mul(rez, invK)
-	s.Mod(s, secp256k1.NewSecp256k1n())
+	s := secp256k1.ScalarMulAddConstantTime(rez, invK, big.NewInt(0))
+
+	// 署名を生成
+	// NOTE: BIP62 Sign と同じくsは n/2 以下に正規化する
+	return signature.NewSignature(r, s).NormalizeS()
+}
 
-	if s.Cmp(secp256k1.NewSecp256k1nHalf()) == 1 {
-		s = new(big.Int).Sub(secp256k1.NewSecp256k1n(), s)
+// NOTE: BIP340 補助乱数を使ってSchnorr署名を生成する
+func (p PrivKey) SignSchnorr(msg []byte) *signature.SchnorrSignature {
+	auxRand := make([]byte, 32)
+	if _, err := rand.Read(auxRand); err != nil {
+		panic(err)
 	}
+	return p.SignSchnorrWithAux(msg, auxRand)
+}
 
-	// 署名を生成
-	return signature.NewSignature(r, s)
+func (p PrivKey) SignSchnorrWithAux(msg []byte, auxRand []byte) *signature.SchnorrSignature {
+	s256n := secp256k1.NewSecp256k1n()
+
+	// NOTE: 公開鍵のyが偶数になるように秘密鍵を正規化する
+	P := secp256k1.NewSecp256k1G().MultiplyConstantTime(p.secret)
+	d := new(big.Int).Set(p.secret)
+	defer utils.ZeroBigInt(d)
+	if P.Y().Bit(0) == 1 {
+		d.Sub(s256n, d)
+	}
+	pubkeyX := utils.PadTo32Bytes(P.X().Bytes())
+
+	// NOTE: 補助乱数で秘密鍵をマスクしてからnonceを導出する
+	auxHash := utils.TaggedHash("BIP0340/aux", auxRand)
+	masked := d.FillBytes(make([]byte, 32))
+	defer clear(masked)
+	t := make([]byte, 32)
+	defer clear(t)
+	for i := range t {
+		t[i] = masked[i] ^ auxHash[i]
+	}
+	k := new(big.Int).SetBytes(utils.TaggedHash("BIP0340/nonce", t, pubkeyX, msg))
+	defer utils.ZeroBigInt(k)
+	k.Mod(k, s256n)
+	if k.Sign() == 0 {
+		panic("k is 0, invalid signature")
+	}
+
+	// NOTE: Rのyが偶数になるようにnonceを正規化する
+	R := secp256k1.NewSecp256k1G().MultiplyConstantTime(k)
+	if R.Y().Bit(0) == 1 {
+		k.Sub(s256n, k)
+	}
+	r := R.X()
+
+	e := new(big.Int).SetBytes(utils.TaggedHash("BIP0340/challenge", utils.PadTo32Bytes(r.Bytes()), pubkeyX, msg))
+	e.Mod(e, s256n)
+
+	// NOTE: s = k + ed mod n。nonceと秘密鍵を含むので定数時間に計算する
+	s := secp256k1.ScalarMulAddConstantTime(e, d, k)
+
+	return signature.NewSchnorrSignature(r, s)
 }
 
-func (p PrivKey) WIF(compressed bool, testnet bool) string {
-	secretBytes := utils.PadTo32Bytes(p.secret.Bytes())
-	var prefix []byte
-	if testnet {
-		prefix = []byte{0xef}
-	} else {
-		prefix = []byte{0x80}
+// NOTE: BIP341 key path spendで署名するため、出力鍵に対応する秘密鍵を返す
+func (p PrivKey) TaprootTweak(merkleRoot []byte) (PrivKey, error) {
+	s256n := secp256k1.NewSecp256k1n()
+	P := secp256k1.NewSecp256k1G().MultiplyConstantTime(p.secret)
+	d := new(big.Int).Set(p.secret)
+	defer utils.ZeroBigInt(d)
+	if P.Y().Bit(0) == 1 {
+		d.Sub(s256n, d)
+	}
+	tweak := new(big.Int).SetBytes(utils.TaggedHash("TapTweak", utils.PadTo32Bytes(P.X().Bytes()), merkleRoot))
+	if tweak.Cmp(s256n) >= 0 {
+		return PrivKey{}, fmt.Errorf("tweak is out of range")
 	}
-	secretBytes = append(prefix, secretBytes...)
+	d.Add(d, tweak)
+	d.Mod(d, s256n)
+	if d.Sign() == 0 {
+		return PrivKey{}, fmt.Errorf("tweaked key is zero")
+	}
+	return NewPrivKey(d), nil
+}
+
+func (p PrivKey) WIF(compressed bool, params *chaincfg.Params) string {
+	// NOTE: 秘密鍵を含むバッファは再確保されないように容量を確保しておき、最後に消去する
+	secretBytes := make([]byte, 0, 1+32+1+4)
+	defer clear(secretBytes[:cap(secretBytes)])
+	secretBytes = append(secretBytes, params.PrivateKeyID)
+	secretBytes = secretBytes[:1+32]
+	p.secret.FillBytes(secretBytes[1:])
 	if compressed {
 		secretBytes = append(secretBytes, 0x01)
 	}
@@ -104,7 +209,52 @@ func (p PrivKey) WIF(compressed bool, testnet bool) string {
 	return base58.Encode(secretBytes)
 }
 
+// NOTE: WIFから読み取った秘密鍵以外の情報
+type WIFMetadata struct {
+	// NOTE: testnet3, testnet4, signet, regtest はプレフィックスが同じなので区別できず、testnet3のparamsになる
+	Params     *chaincfg.Params
+	Compressed bool
+}
+
+// NOTE: WIFを読み、チェックサム、ネットワークのプレフィックス、圧縮フラグと鍵の範囲を確かめる
+func ParseWIF(wif string) (PrivKey, *WIFMetadata, error) {
+	decoded := base58.Decode(wif)
+	defer clear(decoded)
+	// NOTE: プレフィックス1バイト、秘密鍵32バイト、圧縮フラグ0か1バイト、チェックサム4バイト
+	if len(decoded) != 1+32+4 && len(decoded) != 1+32+1+4 {
+		return PrivKey{}, nil, fmt.Errorf("invalid WIF length: %d", len(decoded))
+	}
+	payload := decoded[:len(decoded)-4]
+	checksum := utils.Hash256(payload)[:4]
+	if !utils.CompareBytes(decoded[len(decoded)-4:], checksum) {
+		return PrivKey{}, nil, fmt.Errorf("invalid WIF checksum")
+	}
+
+	var params *chaincfg.Params
+	for _, network := range chaincfg.Networks {
+		if network.PrivateKeyID == payload[0] {
+			params = network
+			break
+		}
+	}
+	if params == nil {
+		return PrivKey{}, nil, fmt.Errorf("unknown WIF prefix: 0x%02x", payload[0])
+	}
+
+	compressed := len(payload) == 1+32+1
+	if compressed && payload[1+32] != 0x01 {
+		return PrivKey{}, nil, fmt.Errorf("invalid WIF compression flag: 0x%02x", payload[1+32])
+	}
+
+	secret := new(big.Int).SetBytes(payload[1 : 1+32])
+	if secret.Sign() == 0 || secret.Cmp(secp256k1.NewSecp256k1n()) >= 0 {
+		utils.ZeroBigInt(secret)
+		return PrivKey{}, nil, fmt.Errorf("WIF private key is out of range")
+	}
+	return PrivKey{secret}, &WIFMetadata{Params: params, Compressed: compressed}, nil
+}
+
 func (p *PrivKey) PubKey() secp256k1.Secp256k1Point {
-	P := secp256k1.NewSecp256k1G().Multiply(p.secret)
+	P := secp256k1.NewSecp256k1G().MultiplyConstantTime(p.secret)
 	return secp256k1.NewSecp256k1Point(P.X(), P.Y())
 }
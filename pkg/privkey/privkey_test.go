@@ -1,7 +1,10 @@
 package privkey
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"golang-bitcoin/pkg/chaincfg"
 	"golang-bitcoin/pkg/secp256k1"
 	"golang-bitcoin/pkg/signature"
 	"golang-bitcoin/pkg/utils"
@@ -15,7 +18,7 @@ func TestPrivKey_WIF(t *testing.T) {
 	}
 	type args struct {
 		compressed bool
-		testnet    bool
+		params     *chaincfg.Params
 	}
 	tests := []struct {
 		name   string
@@ -30,23 +33,145 @@ func TestPrivKey_WIF(t *testing.T) {
 			},
 			args: args{
 				compressed: true,
-				testnet:    true,
+				params:     &chaincfg.TestNet3Params,
 			},
 			want: "cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN8rFTv2sfUK",
 		},
+		{
+			name: "mainnet compressed",
+			fields: fields{
+				secret: big.NewInt(0x54321deadbeef),
+			},
+			args: args{
+				compressed: true,
+				params:     &chaincfg.MainNetParams,
+			},
+			want: "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgiuQJv1h8Ytr2S53a",
+		},
+		{
+			name: "testnet uncompressed",
+			fields: fields{
+				secret: big.NewInt(2021 * 2021 * 2021 * 2021 * 2021),
+			},
+			args: args{
+				compressed: false,
+				params:     &chaincfg.TestNet3Params,
+			},
+			want: "91avARGdfge8E4tZfYLoxeJ5sGBdNJQH4kvjpWAxgzczjbCwxic",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := PrivKey{
 				secret: tt.fields.secret,
 			}
-			if got := p.WIF(tt.args.compressed, tt.args.testnet); got != tt.want {
+			if got := p.WIF(tt.args.compressed, tt.args.params); got != tt.want {
 				t.Errorf("PrivKey.WIF() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestParseWIF(t *testing.T) {
+	wikiSecret, _ := new(big.Int).SetString("0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d", 16)
+	tests := []struct {
+		name           string
+		wif            string
+		wantSecret     *big.Int
+		wantParams     *chaincfg.Params
+		wantCompressed bool
+		wantErr        bool
+	}{
+		{
+			name:           "mainnet uncompressed",
+			wif:            "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ",
+			wantSecret:     wikiSecret,
+			wantParams:     &chaincfg.MainNetParams,
+			wantCompressed: false,
+		},
+		{
+			name:           "mainnet compressed",
+			wif:            "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617",
+			wantSecret:     wikiSecret,
+			wantParams:     &chaincfg.MainNetParams,
+			wantCompressed: true,
+		},
+		{
+			name:           "testnet compressed",
+			wif:            "cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN8rFTv2sfUK",
+			wantSecret:     big.NewInt(5003),
+			wantParams:     &chaincfg.TestNet3Params,
+			wantCompressed: true,
+		},
+		{
+			name:           "testnet uncompressed",
+			wif:            "91avARGdfge8E4tZfYLoxeJ5sGBdNJQH4kvjpWAxgzczjbCwxic",
+			wantSecret:     big.NewInt(2021 * 2021 * 2021 * 2021 * 2021),
+			wantParams:     &chaincfg.TestNet3Params,
+			wantCompressed: false,
+		},
+		{
+			name:    "invalid checksum",
+			wif:     "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98618",
+			wantErr: true,
+		},
+		{
+			name:    "invalid base58 character",
+			wif:     "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP9861O",
+			wantErr: true,
+		},
+		{
+			name:    "unknown prefix",
+			wif:     "L6Cyjtdfq1TimFfgG9qBEHQF2FmaL96H5ceTrip74S4eqFapbcDJ",
+			wantErr: true,
+		},
+		{
+			name:    "invalid compression flag",
+			wif:     "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvWxyf5d",
+			wantErr: true,
+		},
+		{
+			name:    "short key",
+			wif:     "yPoVP5njSzmEVK4VJGRWWAwqnwCyLPRcMm5XyrKgY1DE64xhu",
+			wantErr: true,
+		},
+		{
+			name:    "zero key",
+			wif:     "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73Nd2Mcv1",
+			wantErr: true,
+		},
+		{
+			name:    "key equal to n",
+			wif:     "L5oLkpV3aqBjhki6LmvChTCV6odsp4SXM6FfU2Gppt5kFqRzExJJ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, metadata, err := ParseWIF(tt.wif)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWIF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Secret().Cmp(tt.wantSecret) != 0 {
+				t.Errorf("ParseWIF() secret = %x, want %x", got.Secret(), tt.wantSecret)
+			}
+			if metadata.Params != tt.wantParams {
+				t.Errorf("ParseWIF() params = %v, want %v", metadata.Params.Name, tt.wantParams.Name)
+			}
+			if metadata.Compressed != tt.wantCompressed {
+				t.Errorf("ParseWIF() compressed = %v, want %v", metadata.Compressed, tt.wantCompressed)
+			}
+			// NOTE: 同じ圧縮フラグとネットワークで書き出すと元のWIFに戻る
+			if wif := got.WIF(metadata.Compressed, metadata.Params); wif != tt.wif {
+				t.Errorf("PrivKey.WIF() = %v, want %v", wif, tt.wif)
+			}
+		})
+	}
+}
+
 func TestPrivKey_SignWithK(t *testing.T) {
 	type fields struct {
 		secret *big.Int
@@ -87,7 +212,8 @@ func TestPrivKey_SignWithK(t *testing.T) {
 			wantGenerator: func() *signature.Signature {
 				rHex := "2b698a0f0a4041b77e63488ad48c23e8e8838dd1fb7520408b121697b782ef22"
 				r, _ := new(big.Int).SetString(rHex, 16)
-				sHex := "bb14e602ef9e3f872e25fad328466b34e6734b7a0fcd58b1eb635447ffae8cb9"
+				// NOTE: 教科書の値 bb14e602...8cb9 は n/2 より大きいので n - s に正規化される
+				sHex := "44eb19fd1061c078d1da052cd7b994c9d43b916c9f7b4789d46f0a44d087b488"
 				s, _ := new(big.Int).SetString(sHex, 16)
 				return signature.NewSignature(r, s)
 			},
@@ -111,3 +237,226 @@ func TestPrivKey_SignWithK(t *testing.T) {
 		})
 	}
 }
+
+// NOTE: secp256k1 + SHA256 のRFC6979のベクタ
+func TestPrivKey_Sign(t *testing.T) {
+	tests := []struct {
+		name         string
+		secret       string
+		msg          string
+		extraEntropy string
+		wantR        string
+		wantS        string
+	}{
+		{
+			name:   "secret 1",
+			secret: "1",
+			msg:    "Satoshi Nakamoto",
+			wantR:  "934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8",
+			wantS:  "2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5",
+		},
+		{
+			name:   "secret n-1",
+			secret: "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140",
+			msg:    "Satoshi Nakamoto",
+			wantR:  "fd567d121db66e382991534ada77a6bd3106f0a1098c231e47993447cd6af2d0",
+			wantS:  "6b39cd0eb1bc8603e159ef5c20a5c8ad685a45b06ce9bebed3f153d10d93bed5",
+		},
+		{
+			name:   "long message",
+			secret: "1",
+			msg:    "All those moments will be lost in time, like tears in rain. Time to die...",
+			wantR:  "8600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b",
+			wantS:  "547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21",
+		},
+		{
+			name:   "random secret",
+			secret: "f8b8af8ce3c7cca5e300d33939540c10d45ce001b8f252bfbc57ba0342904181",
+			msg:    "Alan Turing",
+			wantR:  "7063ae83e7f62bbb171798131b4a0564b956930092b33b07b395615d9ec7e15c",
+			wantS:  "58dfcc1e00a35e1572f366ffe34ba0fc47db1e7189759b9fb233c5b05ab388ea",
+		},
+		{
+			name:         "extra entropy",
+			secret:       "1",
+			msg:          "Satoshi Nakamoto",
+			extraEntropy: "0000000000000000000000000000000000000000000000000000000000000001",
+			wantR:        "3f882c5314da77baae73e6f22f58b9a9c7bb8d6a04da09742f30dc0a61abcf4d",
+			wantS:        "280d5a6c657f20d426c53a8eb4dd56c33358527c5b3bf7aa0ce8169501ee7435",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, _ := new(big.Int).SetString(tt.secret, 16)
+			msgHash := sha256.Sum256([]byte(tt.msg))
+			z := new(big.Int).SetBytes(msgHash[:])
+			extraEntropy, _ := hex.DecodeString(tt.extraEntropy)
+			wantR, _ := new(big.Int).SetString(tt.wantR, 16)
+			wantS, _ := new(big.Int).SetString(tt.wantS, 16)
+			want := signature.NewSignature(wantR, wantS)
+
+			p := NewPrivKey(secret)
+			var got *signature.Signature
+			if tt.extraEntropy == "" {
+				got = p.Sign(z)
+			} else {
+				got = p.SignWithEntropy(z, extraEntropy)
+			}
+			if !got.Equals(want) {
+				t.Errorf("PrivKey.Sign() = (%x, %x), want (%x, %x)", got.R(), got.S(), wantR, wantS)
+			}
+			pubkey := p.PubKey()
+			if !pubkey.Verify(z, *got) {
+				t.Errorf("Secp256k1Point.Verify() = false, want true")
+			}
+		})
+	}
+}
+
+// NOTE: BIP340 test-vectors.csv の署名のベクタ
+func TestPrivKey_SignSchnorrWithAux(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		pubkey  string
+		auxRand string
+		msg     string
+		want    string
+	}{
+		{
+			name:    "vector 0",
+			secret:  "0000000000000000000000000000000000000000000000000000000000000003",
+			pubkey:  "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+			auxRand: "0000000000000000000000000000000000000000000000000000000000000000",
+			msg:     "0000000000000000000000000000000000000000000000000000000000000000",
+			want:    "e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca821525f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0",
+		},
+		{
+			name:    "vector 1",
+			secret:  "b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef",
+			pubkey:  "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			auxRand: "0000000000000000000000000000000000000000000000000000000000000001",
+			msg:     "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			want:    "6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de33418906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a",
+		},
+		{
+			name:    "vector 2",
+			secret:  "c90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74020bbea63b14e5c9",
+			pubkey:  "dd308afec5777e13121fa72b9cc1b7cc0139715309b086c960e18fd969774eb8",
+			auxRand: "c87aa53824b4d7ae2eb035a2b5bbbccc080e76cdc6d1692c4b0b62d798e6d906",
+			msg:     "7e2d58d8b3bcdf1abadec7829054f90dda9805aab56c77333024b9d0a508b75c",
+			want:    "5831aaeed7b44bb74e5eab94ba9d4294c49bcf2a60728d8b4c200f50dd313c1bab745879a5ad954a72c45a91c3a51d3c7adea98d82f8481e0e1e03674a6f3fb7",
+		},
+		{
+			name:    "vector 3",
+			secret:  "0b432b2677937381aef05bb02a66ecd012773062cf3fa2549e44f58ed2401710",
+			pubkey:  "25d1dff95105f5253c4022f628a996ad3a0d95fbf21d468a1b33f8c160d8f517",
+			auxRand: "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			msg:     "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			want:    "7eb0509757e246f19449885651611cb965ecc1a187dd51b64fda1edc9637d5ec97582b9cb13db3933705b32ba982af5af25fd78881ebb32771fc5922efc66ea3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, _ := new(big.Int).SetString(tt.secret, 16)
+			auxRand, _ := hex.DecodeString(tt.auxRand)
+			msg, _ := hex.DecodeString(tt.msg)
+			p := NewPrivKey(secret)
+			if got := hex.EncodeToString(p.PubKey().SerializeXOnly()); got != tt.pubkey {
+				t.Errorf("PrivKey.PubKey().SerializeXOnly() = %v, want %v", got, tt.pubkey)
+			}
+			sig := p.SignSchnorrWithAux(msg, auxRand)
+			if got := hex.EncodeToString(sig.Serialize()); got != tt.want {
+				t.Errorf("PrivKey.SignSchnorrWithAux() = %v, want %v", got, tt.want)
+			}
+			if !p.PubKey().VerifySchnorr(msg, *sig) {
+				t.Errorf("Secp256k1Point.VerifySchnorr() = false, want true")
+			}
+		})
+	}
+}
+
+func TestPrivKey_SignSchnorr(t *testing.T) {
+	msg := utils.Hash256([]byte("schnorr"))
+	p := NewPrivKey(big.NewInt(0x1111))
+	sig1 := p.SignSchnorr(msg)
+	sig2 := p.SignSchnorr(msg)
+	if sig1.Equals(sig2) {
+		t.Errorf("PrivKey.SignSchnorr() returned the same signature twice")
+	}
+	for _, sig := range []*signature.SchnorrSignature{sig1, sig2} {
+		if !p.PubKey().VerifySchnorr(msg, *sig) {
+			t.Errorf("Secp256k1Point.VerifySchnorr() = false, want true")
+		}
+	}
+}
+
+func TestPrivKey_TaprootTweak(t *testing.T) {
+	p := NewPrivKey(big.NewInt(0x1111))
+	pubkey := p.PubKey()
+	for _, merkleRoot := range [][]byte{nil, utils.Hash256([]byte("leaf"))} {
+		tweaked, err := p.TaprootTweak(merkleRoot)
+		if err != nil {
+			t.Fatalf("PrivKey.TaprootTweak() error = %v", err)
+		}
+		want, err := pubkey.TaprootTweak(merkleRoot)
+		if err != nil {
+			t.Fatalf("Secp256k1Point.TaprootTweak() error = %v", err)
+		}
+		// NOTE: 出力鍵はx座標のみで比較する
+		if got := tweaked.PubKey(); got.X().Cmp(want.X()) != 0 {
+			t.Errorf("PrivKey.TaprootTweak().PubKey() = %x, want %x", got.SerializeXOnly(), want.SerializeXOnly())
+		}
+		msg := utils.Hash256([]byte("key path"))
+		if !want.VerifySchnorr(msg, *tweaked.SignSchnorr(msg)) {
+			t.Errorf("Secp256k1Point.VerifySchnorr() = false, want true")
+		}
+	}
+}
+
+func TestPrivKey_Zero(t *testing.T) {
+	secret := big.NewInt(5003)
+	p := NewPrivKey(secret)
+	words := p.Secret().Bits()
+	p.Zero()
+	if p.Secret().Sign() != 0 {
+		t.Errorf("PrivKey.Secret() = %v, want 0", p.Secret())
+	}
+	for i, w := range words[:cap(words)] {
+		if w != 0 {
+			t.Errorf("word %d = %x, want 0", i, w)
+		}
+	}
+	// NOTE: NewPrivKeyに渡した値は消去されない
+	if secret.Cmp(big.NewInt(5003)) != 0 {
+		t.Errorf("secret = %v, want 5003", secret)
+	}
+}
+
+func TestPrivKey_SignRecoverable(t *testing.T) {
+	for i := 0; i < 8; i++ {
+		p := NewPrivKey(new(big.Int).SetBytes(utils.Hash256([]byte(fmt.Sprintf("recoverable secret %d", i)))))
+		z := new(big.Int).SetBytes(utils.Hash256([]byte(fmt.Sprintf("recoverable message %d", i))))
+		t.Run(fmt.Sprintf("key %d", i), func(t *testing.T) {
+			sig, recid := p.SignRecoverable(z)
+			if !sig.Equals(p.Sign(z)) {
+				t.Errorf("PrivKey.SignRecoverable() = (%x, %x), want the same signature as PrivKey.Sign()", sig.R(), sig.S())
+			}
+			got, err := secp256k1.RecoverPubKey(z, *sig, recid)
+			if err != nil {
+				t.Fatalf("RecoverPubKey() error = %v", err)
+			}
+			if want := p.PubKey(); !got.Equals(want.Point) {
+				t.Errorf("RecoverPubKey() = %x, want %x", got.Serialize(true), want.Serialize(true))
+			}
+
+			parsed, parsedRecid, compressed, err := signature.ParseCompactSignature(sig.SerializeCompact(recid, true))
+			if err != nil {
+				t.Fatalf("ParseCompactSignature() error = %v", err)
+			}
+			if !parsed.Equals(sig) || parsedRecid != recid || !compressed {
+				t.Errorf("ParseCompactSignature() = (%x, %x), %d, %v, want (%x, %x), %d, true", parsed.R(), parsed.S(), parsedRecid, compressed, sig.R(), sig.S(), recid)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package privkey
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"golang-bitcoin/pkg/secp256k1"
+	"golang-bitcoin/pkg/utils"
+	"math/big"
+)
+
+// NOTE: RFC6979 3.2 のHMAC_DRBGでnonceを決定的に生成する
+// NOTE: extraEntropyはlibsecp256k1と同じく秘密鍵とメッセージの後ろに連結する
+type rfc6979 struct {
+	k []byte
+	v []byte
+}
+
+func newRFC6979(secret, z *big.Int, extraEntropy []byte) *rfc6979 {
+	s256n := secp256k1.NewSecp256k1n()
+	x := utils.PadTo32Bytes(secret.Bytes())
+	// NOTE: qlen = hlen = 256 なので bits2octets(h1) は h1 mod n
+	h := utils.PadTo32Bytes(new(big.Int).Mod(z, s256n).Bytes())
+
+	g := &rfc6979{
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	g.k = g.mac(g.v, []byte{0x00}, x, h, extraEntropy)
+	g.v = g.mac(g.v)
+	g.k = g.mac(g.v, []byte{0x01}, x, h, extraEntropy)
+	g.v = g.mac(g.v)
+	return g
+}
+
+func (g *rfc6979) mac(data ...[]byte) []byte {
+	h := hmac.New(sha256.New, g.k)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// NOTE: [1, n-1] の範囲のnonceを返す
+// NOTE: 呼び出すたびに次の候補を返すので、rやsが0になった場合は再度呼び出す
+func (g *rfc6979) next() *big.Int {
+	s256n := secp256k1.NewSecp256k1n()
+	for {
+		g.v = g.mac(g.v)
+		k := new(big.Int).SetBytes(g.v)
+		// NOTE: 次の呼び出しに備えて状態を更新しておく
+		g.k = g.mac(g.v, []byte{0x00})
+		g.v = g.mac(g.v)
+		if k.Sign() > 0 && k.Cmp(s256n) < 0 {
+			return k
+		}
+	}
+}
+
+func (g *rfc6979) clear() {
+	clear(g.k)
+	clear(g.v)
+}
@@ -0,0 +1,245 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang-bitcoin/pkg/script"
+	"golang-bitcoin/pkg/transaction"
+	"golang-bitcoin/pkg/utils"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// NOTE: bitcoindのJSON-RPCクライアント
+type Client struct {
+	url        string
+	user       string
+	password   string
+	cookiePath string
+	client     *http.Client
+	id         uint64
+}
+
+type ClientOption func(*Client)
+
+func WithUserPass(user, password string) ClientOption {
+	return func(c *Client) {
+		c.user = user
+		c.password = password
+	}
+}
+
+// NOTE: bitcoindの再起動でcookieは変わるため、リクエストごとに読み込む
+func WithCookieFile(path string) ClientOption {
+	return func(c *Client) {
+		c.cookiePath = path
+	}
+}
+
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+func NewClient(url string, options ...ClientOption) *Client {
+	c := &Client{url: url, client: http.DefaultClient}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+	ID     uint64          `json:"id"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+func (c *Client) Call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(request{"1.0", atomic.AddUint64(&c.id, 1), method, params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	user, password, err := c.credentials()
+	if err != nil {
+		return err
+	}
+	if user != "" || password != "" {
+		req.SetBasicAuth(user, password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// NOTE: bitcoindはRPCエラーでもHTTPエラーのステータスと共にJSONを返すことがある
+	var rpcResp response
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("error calling %s: %s", method, resp.Status)
+		}
+		return err
+	}
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(rpcResp.Result, result)
+}
+
+func (c *Client) credentials() (string, string, error) {
+	if c.cookiePath == "" {
+		return c.user, c.password, nil
+	}
+	data, err := os.ReadFile(c.cookiePath)
+	if err != nil {
+		return "", "", err
+	}
+	user, password, ok := strings.Cut(strings.TrimSpace(string(data)), ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid cookie file")
+	}
+	return user, password, nil
+}
+
+func (c *Client) GetRawTransaction(ctx context.Context, txid string) (*transaction.Transaction, error) {
+	var rawHex string
+	if err := c.Call(ctx, "getrawtransaction", []interface{}{txid, false}, &rawHex); err != nil {
+		return nil, err
+	}
+	tx, err := transaction.ParseTransaction(hex.NewDecoder(strings.NewReader(rawHex)))
+	if err != nil {
+		return nil, err
+	}
+	actualTxid, err := tx.ID()
+	if err != nil {
+		return nil, err
+	}
+	if actualTxid != txid {
+		return nil, fmt.Errorf("fetched transaction id does not match expected: %s != %s", actualTxid, txid)
+	}
+	return tx, nil
+}
+
+type TxOut struct {
+	BestBlock     string
+	Confirmations int64
+	Value         uint64
+	ScriptPubKey  *script.Script
+	Coinbase      bool
+}
+
+// NOTE: 未使用のoutputのみ取得できる。使用済みまたは存在しない場合はエラーを返す
+func (c *Client) GetTxOut(ctx context.Context, txid string, index uint32, includeMempool bool) (*TxOut, error) {
+	var result *struct {
+		BestBlock     string      `json:"bestblock"`
+		Confirmations int64       `json:"confirmations"`
+		Value         json.Number `json:"value"`
+		ScriptPubKey  struct {
+			Hex string `json:"hex"`
+		} `json:"scriptPubKey"`
+		Coinbase bool `json:"coinbase"`
+	}
+	if err := c.Call(ctx, "gettxout", []interface{}{txid, index, includeMempool}, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("txout not found: %s:%d", txid, index)
+	}
+	value, err := parseAmount(result.Value.String())
+	if err != nil {
+		return nil, err
+	}
+	scriptPubKey, err := parseScriptHex(result.ScriptPubKey.Hex)
+	if err != nil {
+		return nil, err
+	}
+	return &TxOut{result.BestBlock, result.Confirmations, value, scriptPubKey, result.Coinbase}, nil
+}
+
+func (c *Client) SendRawTransaction(ctx context.Context, tx *transaction.Transaction) (string, error) {
+	serialized, err := tx.Serialize()
+	if err != nil {
+		return "", err
+	}
+	var txid string
+	if err := c.Call(ctx, "sendrawtransaction", []interface{}{hex.EncodeToString(serialized)}, &txid); err != nil {
+		return "", err
+	}
+	return txid, nil
+}
+
+// NOTE: 使用済みのoutputも解決できるようにgetrawtransactionを使う(-txindexが必要)
+func (c *Client) FetchOutput(txid string, index uint32) (*transaction.Output, error) {
+	tx, err := c.GetRawTransaction(context.Background(), txid)
+	if err != nil {
+		return nil, err
+	}
+	if int(index) >= len(tx.Outputs) {
+		return nil, fmt.Errorf("output index out of range: %s:%d", txid, index)
+	}
+	return tx.Outputs[index], nil
+}
+
+// NOTE: 浮動小数点の誤差を避けるため、BTC単位の10進数文字列から直接satoshiに変換する
+func parseAmount(amount string) (uint64, error) {
+	whole, frac, _ := strings.Cut(amount, ".")
+	if len(frac) > 8 || whole == "" || strings.HasPrefix(whole, "-") {
+		return 0, fmt.Errorf("invalid amount: %s", amount)
+	}
+	frac += strings.Repeat("0", 8-len(frac))
+	var sats uint64
+	for _, c := range whole + frac {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid amount: %s", amount)
+		}
+		sats = sats*10 + uint64(c-'0')
+	}
+	return sats, nil
+}
+
+func parseScriptHex(scriptHex string) (*script.Script, error) {
+	raw, err := hex.DecodeString(scriptHex)
+	if err != nil {
+		return nil, err
+	}
+	length, err := utils.SerializeVarInt(uint64(len(raw)))
+	if err != nil {
+		return nil, err
+	}
+	return script.ParseScript(bytes.NewReader(append(length, raw...)))
+}
@@ -0,0 +1,250 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"golang-bitcoin/pkg/transaction"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const rawTxHex = "0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600"
+const rawTxid = "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+
+// NOTE: 1バイトのpushと最短でないpushを含むトランザクション
+const nonMinimalPushTxHex = "0100000001111111111111111111111111111111111111111111111111111111111111111100000000090164010001514c0101ffffffff01e803000000000000015100000000"
+const nonMinimalPushTxid = "9eafbc534bde344db9d9a46b9e006d9ea696241086e1afe245b4ab193725e7ee"
+
+var _ transaction.OutputFetcher = (*Client)(nil)
+
+// NOTE: bitcoindの代わりに、method名に応じて固定のresultを返すサーバー
+func newTestServer(t *testing.T, user, password string, results map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, gotPassword, ok := r.BasicAuth()
+		if !ok || gotUser != user || gotPassword != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return
+		}
+		result, ok := results[req.Method]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": nil,
+				"error":  Error{-32601, "Method not found"},
+				"id":     req.ID,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result": result,
+			"error":  nil,
+			"id":     req.ID,
+		})
+	}))
+}
+
+func TestClient_GetRawTransaction(t *testing.T) {
+	cookiePath := filepath.Join(t.TempDir(), ".cookie")
+	os.WriteFile(cookiePath, []byte("__cookie__:secret\n"), 0600)
+
+	tests := []struct {
+		name    string
+		options []ClientOption
+		rawTx   string
+		txid    string
+		wantErr bool
+	}{
+		{
+			name:    "user and password",
+			options: []ClientOption{WithUserPass("__cookie__", "secret")},
+			txid:    rawTxid,
+			wantErr: false,
+		},
+		{
+			name:    "cookie file",
+			options: []ClientOption{WithCookieFile(cookiePath)},
+			txid:    rawTxid,
+			wantErr: false,
+		},
+		{
+			name:    "unauthorized",
+			options: []ClientOption{WithUserPass("__cookie__", "wrong")},
+			txid:    rawTxid,
+			wantErr: true,
+		},
+		{
+			name:    "transaction with non-minimal pushes",
+			options: []ClientOption{WithCookieFile(cookiePath)},
+			rawTx:   nonMinimalPushTxHex,
+			txid:    nonMinimalPushTxid,
+			wantErr: false,
+		},
+		{
+			name:    "mismatched txid",
+			options: []ClientOption{WithCookieFile(cookiePath)},
+			txid:    "0000000000000000000000000000000000000000000000000000000000000000",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawTx := tt.rawTx
+			if rawTx == "" {
+				rawTx = rawTxHex
+			}
+			server := newTestServer(t, "__cookie__", "secret", map[string]interface{}{
+				"getrawtransaction": rawTx,
+			})
+			defer server.Close()
+
+			c := NewClient(server.URL, tt.options...)
+			got, err := c.GetRawTransaction(context.Background(), tt.txid)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.GetRawTransaction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if gotTxid, _ := got.ID(); gotTxid != tt.txid {
+					t.Errorf("Client.GetRawTransaction() txid = %v, want %v", gotTxid, tt.txid)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_GetTxOut(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    interface{}
+		wantValue uint64
+		wantErr   bool
+	}{
+		{
+			name: "unspent output",
+			result: json.RawMessage(`{
+				"bestblock": "000000000000000000026d2f9e8a8bd2a2f7b2cb0c32b1a8d3b8e3a4f7e1c2b3",
+				"confirmations": 10,
+				"value": 0.10011545,
+				"scriptPubKey": {"hex": "76a9141c4bc762dd5423e332166702cb75f40df79fea1288ac"},
+				"coinbase": false
+			}`),
+			wantValue: 10011545,
+			wantErr:   false,
+		},
+		{
+			name:    "spent output",
+			result:  nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, "user", "pass", map[string]interface{}{
+				"gettxout": tt.result,
+			})
+			defer server.Close()
+
+			c := NewClient(server.URL, WithUserPass("user", "pass"))
+			got, err := c.GetTxOut(context.Background(), rawTxid, 1, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.GetTxOut() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got.Value != tt.wantValue {
+					t.Errorf("Client.GetTxOut().Value = %v, want %v", got.Value, tt.wantValue)
+				}
+				serialized, _ := got.ScriptPubKey.Serialize()
+				if hex.EncodeToString(serialized) != "76a9141c4bc762dd5423e332166702cb75f40df79fea1288ac" {
+					t.Errorf("Client.GetTxOut().ScriptPubKey = %x", serialized)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_SendRawTransaction(t *testing.T) {
+	server := newTestServer(t, "user", "pass", map[string]interface{}{
+		"sendrawtransaction": rawTxid,
+	})
+	defer server.Close()
+
+	raw, _ := hex.DecodeString(rawTxHex)
+	tx, _ := transaction.ParseTransaction(bytes.NewReader(raw))
+	c := NewClient(server.URL, WithUserPass("user", "pass"))
+	got, err := c.SendRawTransaction(context.Background(), tx)
+	if err != nil {
+		t.Fatalf("Client.SendRawTransaction() error = %v", err)
+	}
+	if got != rawTxid {
+		t.Errorf("Client.SendRawTransaction() = %v, want %v", got, rawTxid)
+	}
+}
+
+func TestClient_Call(t *testing.T) {
+	server := newTestServer(t, "user", "pass", map[string]interface{}{})
+	defer server.Close()
+
+	c := NewClient(server.URL, WithUserPass("user", "pass"))
+	err := c.Call(context.Background(), "unknownmethod", nil, nil)
+	rpcErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Client.Call() error = %v, want *Error", err)
+	}
+	if rpcErr.Code != -32601 {
+		t.Errorf("Client.Call() error code = %v, want %v", rpcErr.Code, -32601)
+	}
+}
+
+func TestClient_FetchOutput(t *testing.T) {
+	server := newTestServer(t, "user", "pass", map[string]interface{}{
+		"getrawtransaction": rawTxHex,
+	})
+	defer server.Close()
+
+	c := NewClient(server.URL, WithUserPass("user", "pass"))
+	got, err := c.FetchOutput(rawTxid, 0)
+	if err != nil {
+		t.Fatalf("Client.FetchOutput() error = %v", err)
+	}
+	if got.Value != 32454049 {
+		t.Errorf("Client.FetchOutput().Value = %v, want %v", got.Value, 32454049)
+	}
+	if _, err := c.FetchOutput(rawTxid, 2); err == nil {
+		t.Errorf("Client.FetchOutput() expected error for out of range index")
+	}
+}
+
+func Test_parseAmount(t *testing.T) {
+	tests := []struct {
+		amount  string
+		want    uint64
+		wantErr bool
+	}{
+		{"0.00015627", 15627, false},
+		{"21000000", 2100000000000000, false},
+		{"1.1", 110000000, false},
+		{"0.000000001", 0, true},
+		{"-1", 0, true},
+		{"1e-8", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			got, err := parseAmount(tt.amount)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAmount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAmount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
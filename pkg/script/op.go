@@ -1,31 +1,143 @@
 package script
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
 	"fmt"
 	"golang-bitcoin/pkg/secp256k1"
 	"golang-bitcoin/pkg/signature"
 	"golang-bitcoin/pkg/utils"
-	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
 )
 
 const (
-	OP_DUP                 = 0x76
-	OP_HASH160             = 0xa9
-	OP_HASH256             = 0xaa
+	OP_0                   = 0x00
 	OP_PUSHDATA1           = 0x4c
 	OP_PUSHDATA2           = 0x4d
+	OP_PUSHDATA4           = 0x4e
+	OP_1NEGATE             = 0x4f
+	OP_RESERVED            = 0x50
+	OP_1                   = 0x51
+	OP_2                   = 0x52
+	OP_3                   = 0x53
+	OP_4                   = 0x54
+	OP_5                   = 0x55
+	OP_6                   = 0x56
+	OP_7                   = 0x57
+	OP_8                   = 0x58
+	OP_9                   = 0x59
+	OP_10                  = 0x5a
+	OP_11                  = 0x5b
+	OP_12                  = 0x5c
+	OP_13                  = 0x5d
+	OP_14                  = 0x5e
+	OP_15                  = 0x5f
+	OP_16                  = 0x60
+	OP_NOP                 = 0x61
+	OP_VER                 = 0x62
 	OP_IF                  = 0x63
 	OP_NOTIF               = 0x64
+	OP_VERIF               = 0x65
+	OP_VERNOTIF            = 0x66
 	OP_ELSE                = 0x67
 	OP_ENDIF               = 0x68
+	OP_VERIFY              = 0x69
+	OP_RETURN              = 0x6a
 	OP_TOALTSTACK          = 0x6b
 	OP_FROMALTSTACK        = 0x6c
+	OP_2DROP               = 0x6d
+	OP_2DUP                = 0x6e
+	OP_3DUP                = 0x6f
+	OP_2OVER               = 0x70
+	OP_2ROT                = 0x71
+	OP_2SWAP               = 0x72
+	OP_IFDUP               = 0x73
+	OP_DEPTH               = 0x74
+	OP_DROP                = 0x75
+	OP_DUP                 = 0x76
+	OP_NIP                 = 0x77
+	OP_OVER                = 0x78
+	OP_PICK                = 0x79
+	OP_ROLL                = 0x7a
+	OP_ROT                 = 0x7b
+	OP_SWAP                = 0x7c
+	OP_TUCK                = 0x7d
+	OP_CAT                 = 0x7e
+	OP_SUBSTR              = 0x7f
+	OP_LEFT                = 0x80
+	OP_RIGHT               = 0x81
+	OP_SIZE                = 0x82
+	OP_INVERT              = 0x83
+	OP_AND                 = 0x84
+	OP_OR                  = 0x85
+	OP_XOR                 = 0x86
+	OP_EQUAL               = 0x87
+	OP_EQUALVERIFY         = 0x88
+	OP_RESERVED1           = 0x89
+	OP_RESERVED2           = 0x8a
+	OP_1ADD                = 0x8b
+	OP_1SUB                = 0x8c
+	OP_2MUL                = 0x8d
+	OP_2DIV                = 0x8e
+	OP_NEGATE              = 0x8f
+	OP_ABS                 = 0x90
+	OP_NOT                 = 0x91
+	OP_0NOTEQUAL           = 0x92
+	OP_ADD                 = 0x93
+	OP_SUB                 = 0x94
+	OP_MUL                 = 0x95
+	OP_DIV                 = 0x96
+	OP_MOD                 = 0x97
+	OP_LSHIFT              = 0x98
+	OP_RSHIFT              = 0x99
+	OP_BOOLAND             = 0x9a
+	OP_BOOLOR              = 0x9b
+	OP_NUMEQUAL            = 0x9c
+	OP_NUMEQUALVERIFY      = 0x9d
+	OP_NUMNOTEQUAL         = 0x9e
+	OP_LESSTHAN            = 0x9f
+	OP_GREATERTHAN         = 0xa0
+	OP_LESSTHANOREQUAL     = 0xa1
+	OP_GREATERTHANOREQUAL  = 0xa2
+	OP_MIN                 = 0xa3
+	OP_MAX                 = 0xa4
+	OP_WITHIN              = 0xa5
+	OP_RIPEMD160           = 0xa6
+	OP_SHA1                = 0xa7
+	OP_SHA256              = 0xa8
+	OP_HASH160             = 0xa9
+	OP_HASH256             = 0xaa
+	OP_CODESEPARATOR       = 0xab
 	OP_CHECKSIG            = 0xac
 	OP_CHECKSIGVERIFY      = 0xad
 	OP_CHECKMULTISIG       = 0xae
 	OP_CHECKMULTISIGVERIFY = 0xaf
-	OP_EQUAL               = 0x87
-	OP_EQUALVERIFY         = 0x88
+	OP_NOP1                = 0xb0
+	OP_CHECKLOCKTIMEVERIFY = 0xb1
+	OP_CHECKSEQUENCEVERIFY = 0xb2
+	OP_NOP4                = 0xb3
+	OP_NOP5                = 0xb4
+	OP_NOP6                = 0xb5
+	OP_NOP7                = 0xb6
+	OP_NOP8                = 0xb7
+	OP_NOP9                = 0xb8
+	OP_NOP10               = 0xb9
+	OP_CHECKSIGADD         = 0xba
+	OP_INVALIDOPCODE       = 0xff
+)
+
+const (
+	// NOTE: 算術演算の入力として受け付ける数値の最大バイト数
+	maxNumSize = 4
+	// NOTE: OP_CHECKLOCKTIMEVERIFY, OP_CHECKSEQUENCEVERIFYは5バイトまで受け付ける
+	maxLockTimeNumSize = 5
+	// NOTE: locktimeがこの値未満の場合はブロック高、以上の場合はUNIX時間
+	lockTimeThreshold = 500000000
+
+	sequenceLockTimeDisableFlag = 1 << 31
+	sequenceLockTimeTypeFlag    = 1 << 22
+	sequenceLockTimeMask        = 0x0000ffff
 )
 
 // NOTE: Op呼び出時のInstructionsはOp自体を含まない
@@ -35,6 +147,14 @@ func (s *Script) OpNumber(num int64) error {
 	return nil
 }
 
+func (s *Script) OpNop() error {
+	return nil
+}
+
+func (s *Script) OpReturn() error {
+	return fmt.Errorf("op_return encountered")
+}
+
 func (s *Script) OpDup() error {
 	if len(s.Stack) < 1 {
 		return fmt.Errorf("stack is empty")
@@ -43,6 +163,193 @@ func (s *Script) OpDup() error {
 	return nil
 }
 
+func (s *Script) Op2Dup() error {
+	if len(s.Stack) < 2 {
+		return fmt.Errorf("stack is empty")
+	}
+	s.Stack = append(s.Stack, s.Stack[len(s.Stack)-2:]...)
+	return nil
+}
+
+func (s *Script) Op3Dup() error {
+	if len(s.Stack) < 3 {
+		return fmt.Errorf("stack is empty")
+	}
+	s.Stack = append(s.Stack, s.Stack[len(s.Stack)-3:]...)
+	return nil
+}
+
+func (s *Script) OpIfDup() error {
+	if len(s.Stack) < 1 {
+		return fmt.Errorf("stack is empty")
+	}
+	element := s.Stack[len(s.Stack)-1]
+	if castToBool(element) {
+		s.Stack = append(s.Stack, element)
+	}
+	return nil
+}
+
+func (s *Script) OpDepth() error {
+	s.Stack = append(s.Stack, encodeNum(int64(len(s.Stack))))
+	return nil
+}
+
+func (s *Script) OpDrop() error {
+	_, err := s.PopStack()
+	return err
+}
+
+func (s *Script) Op2Drop() error {
+	if len(s.Stack) < 2 {
+		return fmt.Errorf("stack is empty")
+	}
+	s.Stack = s.Stack[:len(s.Stack)-2]
+	return nil
+}
+
+func (s *Script) OpNip() error {
+	if len(s.Stack) < 2 {
+		return fmt.Errorf("stack is empty")
+	}
+	s.Stack = append(s.Stack[:len(s.Stack)-2], s.Stack[len(s.Stack)-1])
+	return nil
+}
+
+func (s *Script) OpOver() error {
+	if len(s.Stack) < 2 {
+		return fmt.Errorf("stack is empty")
+	}
+	s.Stack = append(s.Stack, s.Stack[len(s.Stack)-2])
+	return nil
+}
+
+func (s *Script) Op2Over() error {
+	if len(s.Stack) < 4 {
+		return fmt.Errorf("stack is empty")
+	}
+	s.Stack = append(s.Stack, s.Stack[len(s.Stack)-4:len(s.Stack)-2]...)
+	return nil
+}
+
+func (s *Script) OpPick() error {
+	n, err := s.popNum(maxNumSize)
+	if err != nil {
+		return err
+	}
+	if n < 0 || n >= int64(len(s.Stack)) {
+		return fmt.Errorf("invalid stack index")
+	}
+	s.Stack = append(s.Stack, s.Stack[len(s.Stack)-1-int(n)])
+	return nil
+}
+
+func (s *Script) OpRoll() error {
+	n, err := s.popNum(maxNumSize)
+	if err != nil {
+		return err
+	}
+	if n < 0 || n >= int64(len(s.Stack)) {
+		return fmt.Errorf("invalid stack index")
+	}
+	index := len(s.Stack) - 1 - int(n)
+	element := s.Stack[index]
+	s.Stack = append(s.Stack[:index], s.Stack[index+1:]...)
+	s.Stack = append(s.Stack, element)
+	return nil
+}
+
+func (s *Script) OpRot() error {
+	if len(s.Stack) < 3 {
+		return fmt.Errorf("stack is empty")
+	}
+	// NOTE: x1 x2 x3 -> x2 x3 x1
+	n := len(s.Stack)
+	s.Stack[n-3], s.Stack[n-2], s.Stack[n-1] = s.Stack[n-2], s.Stack[n-1], s.Stack[n-3]
+	return nil
+}
+
+func (s *Script) Op2Rot() error {
+	if len(s.Stack) < 6 {
+		return fmt.Errorf("stack is empty")
+	}
+	// NOTE: x1 x2 x3 x4 x5 x6 -> x3 x4 x5 x6 x1 x2
+	n := len(s.Stack)
+	x1, x2 := s.Stack[n-6], s.Stack[n-5]
+	copy(s.Stack[n-6:], s.Stack[n-4:])
+	s.Stack[n-2], s.Stack[n-1] = x1, x2
+	return nil
+}
+
+func (s *Script) OpSwap() error {
+	if len(s.Stack) < 2 {
+		return fmt.Errorf("stack is empty")
+	}
+	n := len(s.Stack)
+	s.Stack[n-2], s.Stack[n-1] = s.Stack[n-1], s.Stack[n-2]
+	return nil
+}
+
+func (s *Script) Op2Swap() error {
+	if len(s.Stack) < 4 {
+		return fmt.Errorf("stack is empty")
+	}
+	// NOTE: x1 x2 x3 x4 -> x3 x4 x1 x2
+	n := len(s.Stack)
+	s.Stack[n-4], s.Stack[n-3], s.Stack[n-2], s.Stack[n-1] = s.Stack[n-2], s.Stack[n-1], s.Stack[n-4], s.Stack[n-3]
+	return nil
+}
+
+func (s *Script) OpTuck() error {
+	if len(s.Stack) < 2 {
+		return fmt.Errorf("stack is empty")
+	}
+	// NOTE: x1 x2 -> x2 x1 x2
+	n := len(s.Stack)
+	x2 := s.Stack[n-1]
+	s.Stack = append(s.Stack[:n-2], x2, s.Stack[n-2], x2)
+	return nil
+}
+
+func (s *Script) OpSize() error {
+	if len(s.Stack) < 1 {
+		return fmt.Errorf("stack is empty")
+	}
+	s.Stack = append(s.Stack, encodeNum(int64(len(s.Stack[len(s.Stack)-1]))))
+	return nil
+}
+
+func (s *Script) OpRipemd160() error {
+	element, err := s.PopStack()
+	if err != nil {
+		return err
+	}
+	h := ripemd160.New()
+	h.Write(element)
+	s.Stack = append(s.Stack, h.Sum(nil))
+	return nil
+}
+
+func (s *Script) OpSha1() error {
+	element, err := s.PopStack()
+	if err != nil {
+		return err
+	}
+	hash := sha1.Sum(element)
+	s.Stack = append(s.Stack, hash[:])
+	return nil
+}
+
+func (s *Script) OpSha256() error {
+	element, err := s.PopStack()
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(element)
+	s.Stack = append(s.Stack, hash[:])
+	return nil
+}
+
 func (s *Script) OpHash160() error {
 	if len(s.Stack) < 1 {
 		return fmt.Errorf("stack is empty")
@@ -101,7 +408,7 @@ func (s *Script) OpVerify() error {
 		return err
 	}
 
-	if decodeNum(element) == 0 {
+	if !castToBool(element) {
 		return fmt.Errorf("invalid element")
 	}
 	return nil
@@ -114,128 +421,194 @@ func (s *Script) OpEqualVerify() error {
 	return s.OpVerify()
 }
 
-func (s *Script) OpIf() error {
-	if len(s.Stack) < 1 {
-		return fmt.Errorf("stack is empty")
+// NOTE: 数値を1つ取り出して演算結果をpushする
+func (s *Script) unaryNumOp(op func(a int64) int64) error {
+	a, err := s.popNum(maxNumSize)
+	if err != nil {
+		return err
 	}
-	true_instructions := make([][]byte, 0)
-	false_instructions := make([][]byte, 0)
-	new_instructions := &true_instructions
-	numEndIf := 1
-	valid := false
+	s.Stack = append(s.Stack, encodeNum(op(a)))
+	return nil
+}
 
-	for len(s.Instructions) > 0 {
-		instruction, err := s.PopInstruction()
-		if err != nil {
-			return err
-		}
-		switch instruction[0] {
-		case OP_IF:
-			numEndIf += 1
-			*new_instructions = append(*new_instructions, instruction)
-		case OP_NOTIF:
-			numEndIf += 1
-			*new_instructions = append(*new_instructions, instruction)
-		case OP_ELSE:
-			if numEndIf == 1 {
-				// NOTE: このときのみ負の分岐に入る
-				new_instructions = &false_instructions
-			} else {
-				*new_instructions = append(*new_instructions, instruction)
-			}
-		case OP_ENDIF:
-			numEndIf -= 1
-			if numEndIf == 0 {
-				valid = true
-				break
-			} else {
-				*new_instructions = append(*new_instructions, instruction)
-			}
-		default:
-			*new_instructions = append(*new_instructions, instruction)
-		}
+// NOTE: 数値を2つ取り出して演算結果をpushする。aが先にpushされた値
+func (s *Script) binaryNumOp(op func(a, b int64) int64) error {
+	b, err := s.popNum(maxNumSize)
+	if err != nil {
+		return err
 	}
+	a, err := s.popNum(maxNumSize)
+	if err != nil {
+		return err
+	}
+	s.Stack = append(s.Stack, encodeNum(op(a, b)))
+	return nil
+}
+
+func (s *Script) Op1Add() error {
+	return s.unaryNumOp(func(a int64) int64 { return a + 1 })
+}
+
+func (s *Script) Op1Sub() error {
+	return s.unaryNumOp(func(a int64) int64 { return a - 1 })
+}
+
+func (s *Script) OpNegate() error {
+	return s.unaryNumOp(func(a int64) int64 { return -a })
+}
+
+func (s *Script) OpAbs() error {
+	return s.unaryNumOp(func(a int64) int64 {
+		if a < 0 {
+			return -a
+		}
+		return a
+	})
+}
+
+func (s *Script) OpNot() error {
+	return s.unaryNumOp(func(a int64) int64 { return boolToNum(a == 0) })
+}
+
+func (s *Script) Op0NotEqual() error {
+	return s.unaryNumOp(func(a int64) int64 { return boolToNum(a != 0) })
+}
+
+func (s *Script) OpAdd() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return a + b })
+}
+
+func (s *Script) OpSub() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return a - b })
+}
+
+func (s *Script) OpBoolAnd() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return boolToNum(a != 0 && b != 0) })
+}
+
+func (s *Script) OpBoolOr() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return boolToNum(a != 0 || b != 0) })
+}
 
-	if !valid {
-		return fmt.Errorf("invalid if-else-endif block")
+func (s *Script) OpNumEqual() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return boolToNum(a == b) })
+}
+
+func (s *Script) OpNumEqualVerify() error {
+	if err := s.OpNumEqual(); err != nil {
+		return err
 	}
+	return s.OpVerify()
+}
 
-	// NOTE: Stackの先頭要素に基づき実行する分岐を決定
-	element, err := s.PopStack()
+func (s *Script) OpNumNotEqual() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return boolToNum(a != b) })
+}
+
+func (s *Script) OpLessThan() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return boolToNum(a < b) })
+}
+
+func (s *Script) OpGreaterThan() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return boolToNum(a > b) })
+}
+
+func (s *Script) OpLessThanOrEqual() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return boolToNum(a <= b) })
+}
+
+func (s *Script) OpGreaterThanOrEqual() error {
+	return s.binaryNumOp(func(a, b int64) int64 { return boolToNum(a >= b) })
+}
+
+func (s *Script) OpMin() error {
+	return s.binaryNumOp(func(a, b int64) int64 {
+		if a < b {
+			return a
+		}
+		return b
+	})
+}
+
+func (s *Script) OpMax() error {
+	return s.binaryNumOp(func(a, b int64) int64 {
+		if a > b {
+			return a
+		}
+		return b
+	})
+}
+
+func (s *Script) OpWithin() error {
+	// NOTE: x min max -> min <= x < max
+	max, err := s.popNum(maxNumSize)
 	if err != nil {
 		return err
 	}
-	decodedElement := decodeNum(element)
-	if decodedElement != 0 {
-		s.Instructions = append(s.Instructions, true_instructions...)
-	} else {
-		s.Instructions = append(s.Instructions, false_instructions...)
+	min, err := s.popNum(maxNumSize)
+	if err != nil {
+		return err
 	}
-
+	x, err := s.popNum(maxNumSize)
+	if err != nil {
+		return err
+	}
+	s.Stack = append(s.Stack, encodeNum(boolToNum(min <= x && x < max)))
 	return nil
 }
 
-func (s *Script) OpNotIf() error {
-	if len(s.Stack) < 1 {
-		return fmt.Errorf("stack is empty")
-	}
-	true_instructions := make([][]byte, 0)
-	false_instructions := make([][]byte, 0)
-	new_instructions := &true_instructions
-	numEndIf := 1
-	valid := false
+func (s *Script) OpIf(ctx *EvalContext) error {
+	return s.opConditional(ctx, false)
+}
 
-	for len(s.Instructions) > 0 {
-		instruction, err := s.PopInstruction()
+func (s *Script) OpNotIf(ctx *EvalContext) error {
+	return s.opConditional(ctx, true)
+}
+
+// NOTE: 実行中の分岐であればStackの先頭要素に基づき、以降の命令を実行するかどうかを積む
+func (s *Script) opConditional(ctx *EvalContext, negate bool) error {
+	value := false
+	if s.isExecuting() {
+		element, err := s.PopStack()
 		if err != nil {
 			return err
 		}
-		switch instruction[0] {
-		case OP_IF:
-			numEndIf += 1
-			*new_instructions = append(*new_instructions, instruction)
-		case OP_NOTIF:
-			numEndIf += 1
-			*new_instructions = append(*new_instructions, instruction)
-		case OP_ELSE:
-			if numEndIf == 1 {
-				// NOTE: このときのみ負の分岐に入る
-				new_instructions = &false_instructions
-			} else {
-				*new_instructions = append(*new_instructions, instruction)
-			}
-		case OP_ENDIF:
-			numEndIf -= 1
-			if numEndIf == 0 {
-				valid = true
-				break
-			} else {
-				*new_instructions = append(*new_instructions, instruction)
-			}
-		default:
-			*new_instructions = append(*new_instructions, instruction)
+		// NOTE: tapscriptでは条件は空か0x01でなければならない
+		if ctx.tapscript && (len(element) > 1 || len(element) == 1 && element[0] != 1) {
+			return fmt.Errorf("conditional argument must be minimal")
 		}
+		value = castToBool(element) != negate
 	}
+	s.condStack = append(s.condStack, value)
+	return nil
+}
 
-	if !valid {
-		return fmt.Errorf("invalid if-else-endif block")
+func (s *Script) OpElse() error {
+	if len(s.condStack) == 0 {
+		return fmt.Errorf("unbalanced conditional")
 	}
+	s.condStack[len(s.condStack)-1] = !s.condStack[len(s.condStack)-1]
+	return nil
+}
 
-	// NOTE: Stackの先頭要素に基づき実行する分岐を決定
-	element, err := s.PopStack()
-	if err != nil {
-		return err
-	}
-	decodedElement := decodeNum(element)
-	if decodedElement == 0 {
-		s.Instructions = append(s.Instructions, true_instructions...)
-	} else {
-		s.Instructions = append(s.Instructions, false_instructions...)
+func (s *Script) OpEndIf() error {
+	if len(s.condStack) == 0 {
+		return fmt.Errorf("unbalanced conditional")
 	}
-
+	s.condStack = s.condStack[:len(s.condStack)-1]
 	return nil
 }
 
+// NOTE: 全ての条件が真の場合のみ命令を実行する
+func (s *Script) isExecuting() bool {
+	for _, value := range s.condStack {
+		if !value {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Script) OpToAltStack() error {
 	if len(s.Stack) < 1 {
 		return fmt.Errorf("stack is empty")
@@ -260,7 +633,7 @@ func (s *Script) OpFromAltStack() error {
 	return nil
 }
 
-func (s *Script) OpCheckSig(z *big.Int) error {
+func (s *Script) OpCheckSig(ctx *EvalContext) error {
 	if len(s.Stack) < 2 {
 		return fmt.Errorf("stack is empty")
 	}
@@ -268,17 +641,159 @@ func (s *Script) OpCheckSig(z *big.Int) error {
 	if err != nil {
 		return err
 	}
-	derSig, err := s.PopStack()
+	sigWithHashType, err := s.PopStack()
+	if err != nil {
+		return err
+	}
+	var valid bool
+	if ctx.tapscript {
+		valid, err = checkSchnorrSig(ctx, sigWithHashType, secPubkey)
+	} else {
+		valid, err = checkSig(ctx, sigScriptCode(ctx, sigWithHashType), sigWithHashType, secPubkey)
+	}
 	if err != nil {
 		return err
 	}
+	if valid {
+		s.Stack = append(s.Stack, encodeNum(1))
+	} else {
+		s.Stack = append(s.Stack, encodeNum(0))
+	}
+
+	return nil
+}
+
+// NOTE: legacyでは署名ハッシュを計算する前に、検証する全ての署名のpushをscriptCodeから取り除く
+func sigScriptCode(ctx *EvalContext, sigs ...[]byte) *Script {
+	scriptCode := ctx.scriptCode
+	if ctx.witnessV0 || scriptCode == nil {
+		return scriptCode
+	}
+	for _, sig := range sigs {
+		scriptCode = scriptCode.FindAndDelete(sig)
+	}
+	return scriptCode
+}
+
+func checkSig(ctx *EvalContext, scriptCode *Script, sigWithHashType, secPubkey []byte) (bool, error) {
+	// NOTE: 空の署名は検証失敗として扱う
+	if len(sigWithHashType) == 0 {
+		return false, nil
+	}
+
+	// NOTE: 署名の末尾1バイトはハッシュタイプ
+	derSig := sigWithHashType[:len(sigWithHashType)-1]
+	hashType := uint32(sigWithHashType[len(sigWithHashType)-1])
+
+	var sig *signature.Signature
+	var err error
+	if ctx.Flags&SCRIPT_VERIFY_DERSIG != 0 {
+		sig, err = signature.ParseSignature(derSig)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		// NOTE: BIP66 以前はパースできない署名も検証失敗として扱う
+		sig, err = signature.ParseSignatureLax(derSig)
+		if err != nil {
+			return false, nil
+		}
+	}
+	if ctx.Flags&SCRIPT_VERIFY_LOW_S != 0 && !sig.IsLowS() {
+		return false, fmt.Errorf("signature s value is too high")
+	}
+
+	z, err := ctx.SigHash(hashType, scriptCode)
+	if err != nil {
+		return false, err
+	}
+	// NOTE: 不正な公開鍵はエラーではなく検証失敗として扱う
+	pubkey, err := secp256k1.ParseSecp256k1Point(secPubkey)
+	if err != nil {
+		return false, nil
+	}
+	return pubkey.Verify(z, *sig), nil
+}
+
+func (s *Script) OpCheckSigVerify(ctx *EvalContext) error {
+	if err := s.OpCheckSig(ctx); err != nil {
+		return err
+	}
+	return s.OpVerify()
+}
 
-	pubkey := secp256k1.ParseSecp256k1Point(secPubkey)
-	sig, err := signature.ParseSignature(derSig)
+func (s *Script) OpCheckMultiSig(ctx *EvalContext) error {
+	// NOTE: tapscriptではOP_CHECKSIGADDに置き換えられている
+	if ctx.tapscript {
+		return fmt.Errorf("checkmultisig is disabled in tapscript")
+	}
+	n, err := s.popNum(4)
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > maxPubKeysPerMultiSig {
+		return fmt.Errorf("invalid number of pubkeys: %d", n)
+	}
+	// NOTE: 実行されたOP_CHECKMULTISIGの公開鍵の数もopcodeの数に含める
+	s.numOps += int(n)
+	if s.numOps > maxOpsPerScript {
+		return fmt.Errorf("too many opcodes")
+	}
+	if int64(len(s.Stack)) < n+1 {
+		return fmt.Errorf("stack is too short")
+	}
+	secPubkeys := make([][]byte, n)
+	for i := range secPubkeys {
+		secPubkeys[i], err = s.PopStack()
+		if err != nil {
+			return err
+		}
+	}
+	m, err := s.popNum(4)
+	if err != nil {
+		return err
+	}
+	if m < 0 || m > n {
+		return fmt.Errorf("invalid number of signatures: %d", m)
+	}
+	// NOTE: 署名に加えて、オリジナル実装のoff-by-oneバグで余分に1つ取り出される要素がある
+	if int64(len(s.Stack)) < m+1 {
+		return fmt.Errorf("stack is too short")
+	}
+	sigs := make([][]byte, m)
+	for i := range sigs {
+		sigs[i], err = s.PopStack()
+		if err != nil {
+			return err
+		}
+	}
+	dummy, err := s.PopStack()
 	if err != nil {
 		return err
 	}
-	valid := pubkey.Verify(z, *sig)
+	if ctx.Flags&SCRIPT_VERIFY_NULLDUMMY != 0 && len(dummy) != 0 {
+		return fmt.Errorf("dummy element must be empty")
+	}
+
+	// NOTE: 公開鍵と署名はどちらもstackの上から(scriptでは記述順に)取り出しているため、
+	//       署名は公開鍵と同じ順序で並んでいなければならない
+	scriptCode := sigScriptCode(ctx, sigs...)
+	valid := true
+	for len(sigs) > 0 {
+		// NOTE: 残りの公開鍵が残りの署名より少なければ、もう成功しない
+		if len(secPubkeys) < len(sigs) {
+			valid = false
+			break
+		}
+		ok, err := checkSig(ctx, scriptCode, sigs[0], secPubkeys[0])
+		if err != nil {
+			return err
+		}
+		if ok {
+			sigs = sigs[1:]
+		}
+		secPubkeys = secPubkeys[1:]
+	}
 	if valid {
 		s.Stack = append(s.Stack, encodeNum(1))
 	} else {
@@ -288,13 +803,129 @@ func (s *Script) OpCheckSig(z *big.Int) error {
 	return nil
 }
 
-func IsOp(inst []byte) bool {
-	if len(inst) != 1 {
-		return false
+func (s *Script) OpCheckMultiSigVerify(ctx *EvalContext) error {
+	if err := s.OpCheckMultiSig(ctx); err != nil {
+		return err
+	}
+	return s.OpVerify()
+}
+
+// NOTE: BIP342 sig n pubkey -> n + (署名が有効なら1)
+func (s *Script) OpCheckSigAdd(ctx *EvalContext) error {
+	if !ctx.tapscript {
+		return fmt.Errorf("checksigadd is only available in tapscript")
+	}
+	if len(s.Stack) < 3 {
+		return fmt.Errorf("stack is too short")
+	}
+	secPubkey, err := s.PopStack()
+	if err != nil {
+		return err
+	}
+	n, err := s.popNum(maxNumSize)
+	if err != nil {
+		return err
+	}
+	sig, err := s.PopStack()
+	if err != nil {
+		return err
+	}
+	valid, err := checkSchnorrSig(ctx, sig, secPubkey)
+	if err != nil {
+		return err
+	}
+	s.Stack = append(s.Stack, encodeNum(n+boolToNum(valid)))
+	return nil
+}
+
+// NOTE: BIP65
+func (s *Script) OpCheckLockTimeVerify(ctx *EvalContext) error {
+	if len(s.Stack) < 1 {
+		return fmt.Errorf("stack is empty")
+	}
+	// NOTE: 値はstackに残す
+	element := s.Stack[len(s.Stack)-1]
+	if len(element) > maxLockTimeNumSize {
+		return fmt.Errorf("number is too long")
+	}
+	lockTime := decodeNum(element)
+	if lockTime < 0 {
+		return fmt.Errorf("negative locktime")
+	}
+	// NOTE: ブロック高とUNIX時間は比較できない
+	if (lockTime < lockTimeThreshold) != (int64(ctx.LockTime) < lockTimeThreshold) {
+		return fmt.Errorf("locktime type mismatch")
+	}
+	if lockTime > int64(ctx.LockTime) {
+		return fmt.Errorf("locktime requirement not satisfied")
 	}
-	op := inst[0]
+	// NOTE: sequenceが最大値の場合はlocktimeが無視されるため、失敗とする
+	if ctx.Sequence == 0xffffffff {
+		return fmt.Errorf("input is final")
+	}
+	return nil
+}
+
+// NOTE: BIP112
+func (s *Script) OpCheckSequenceVerify(ctx *EvalContext) error {
+	if len(s.Stack) < 1 {
+		return fmt.Errorf("stack is empty")
+	}
+	element := s.Stack[len(s.Stack)-1]
+	if len(element) > maxLockTimeNumSize {
+		return fmt.Errorf("number is too long")
+	}
+	sequence := decodeNum(element)
+	if sequence < 0 {
+		return fmt.Errorf("negative sequence")
+	}
+	// NOTE: disable flagが立っている場合はOP_NOPとして扱う
+	if sequence&sequenceLockTimeDisableFlag != 0 {
+		return nil
+	}
+	if ctx.Version < 2 {
+		return fmt.Errorf("transaction version does not support relative locktime")
+	}
+	if ctx.Sequence&sequenceLockTimeDisableFlag != 0 {
+		return fmt.Errorf("input sequence has disable flag")
+	}
+	mask := int64(sequenceLockTimeTypeFlag | sequenceLockTimeMask)
+	required := sequence & mask
+	actual := int64(ctx.Sequence) & mask
+	if (required < sequenceLockTimeTypeFlag) != (actual < sequenceLockTimeTypeFlag) {
+		return fmt.Errorf("sequence type mismatch")
+	}
+	if required > actual {
+		return fmt.Errorf("sequence requirement not satisfied")
+	}
+	return nil
+}
+
+func (s *Script) popNum(maxSize int) (int64, error) {
+	element, err := s.PopStack()
+	if err != nil {
+		return 0, err
+	}
+	if len(element) > maxSize {
+		return 0, fmt.Errorf("number is too long")
+	}
+	return decodeNum(element), nil
+}
+
+func boolToNum(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// NOTE: 分岐の内側で実行されない場合でもスクリプトを失敗させるopcode
+func isDisabledOp(op byte) bool {
 	switch op {
-	case OP_DUP, OP_HASH160, OP_HASH256, OP_PUSHDATA1, OP_PUSHDATA2, OP_IF, OP_NOTIF, OP_ELSE, OP_ENDIF, OP_TOALTSTACK, OP_FROMALTSTACK, OP_CHECKSIG, OP_CHECKSIGVERIFY, OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY, OP_EQUAL, OP_EQUALVERIFY:
+	case OP_VERIF, OP_VERNOTIF,
+		OP_CAT, OP_SUBSTR, OP_LEFT, OP_RIGHT,
+		OP_INVERT, OP_AND, OP_OR, OP_XOR,
+		OP_2MUL, OP_2DIV, OP_MUL, OP_DIV, OP_MOD, OP_LSHIFT, OP_RSHIFT:
 		return true
 	default:
 		return false
@@ -0,0 +1,647 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"golang-bitcoin/pkg/privkey"
+	"golang-bitcoin/pkg/secp256k1"
+	"golang-bitcoin/pkg/signature"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func op(code byte) Instruction {
+	return NewOpInstruction(code)
+}
+
+func push(element []byte) Instruction {
+	return NewPushInstruction(element)
+}
+
+func data(dataHex string) []byte {
+	element, _ := hex.DecodeString(dataHex)
+	return element
+}
+
+func TestScript_Evaluate(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		ctx          *EvalContext
+		wantErr      bool
+	}{
+		{
+			name:         "small integers",
+			instructions: []Instruction{op(OP_2), op(OP_3), op(OP_ADD), op(OP_5), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "negative numbers",
+			instructions: []Instruction{op(OP_1NEGATE), op(OP_ABS), op(OP_1), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "sub and negate",
+			instructions: []Instruction{op(OP_3), op(OP_5), op(OP_SUB), op(OP_NEGATE), op(OP_2), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "1add 1sub not 0notequal",
+			instructions: []Instruction{op(OP_0), op(OP_1ADD), op(OP_1SUB), op(OP_NOT), op(OP_0NOTEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "within",
+			instructions: []Instruction{op(OP_5), op(OP_2), op(OP_6), op(OP_WITHIN)},
+			wantErr:      false,
+		},
+		{
+			name:         "within upper bound is exclusive",
+			instructions: []Instruction{op(OP_6), op(OP_2), op(OP_6), op(OP_WITHIN)},
+			wantErr:      true,
+		},
+		{
+			name:         "min max comparisons",
+			instructions: []Instruction{op(OP_7), op(OP_9), op(OP_MIN), op(OP_7), op(OP_NUMEQUALVERIFY), op(OP_7), op(OP_9), op(OP_MAX), op(OP_9), op(OP_GREATERTHANOREQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "boolean ops",
+			instructions: []Instruction{op(OP_1), op(OP_0), op(OP_BOOLOR), op(OP_1), op(OP_BOOLAND)},
+			wantErr:      false,
+		},
+		{
+			name:         "number longer than 4 bytes",
+			instructions: []Instruction{push(data("0000000001")), op(OP_1ADD)},
+			wantErr:      true,
+		},
+		{
+			name:         "swap rot tuck",
+			instructions: []Instruction{op(OP_1), op(OP_2), op(OP_3), op(OP_ROT), op(OP_1), op(OP_NUMEQUALVERIFY), op(OP_SWAP), op(OP_2), op(OP_NUMEQUALVERIFY), op(OP_3), op(OP_TUCK), op(OP_2DROP), op(OP_3), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "pick and roll",
+			instructions: []Instruction{op(OP_4), op(OP_5), op(OP_6), op(OP_2), op(OP_PICK), op(OP_4), op(OP_NUMEQUALVERIFY), op(OP_2), op(OP_ROLL), op(OP_4), op(OP_NUMEQUALVERIFY), op(OP_2DROP), op(OP_DEPTH), op(OP_0), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "pick out of range",
+			instructions: []Instruction{op(OP_1), op(OP_5), op(OP_PICK)},
+			wantErr:      true,
+		},
+		{
+			name:         "2dup 3dup 2over 2swap 2rot",
+			instructions: []Instruction{op(OP_1), op(OP_2), op(OP_2DUP), op(OP_3DUP), op(OP_2OVER), op(OP_2SWAP), op(OP_2ROT), op(OP_DEPTH), op(OP_9), op(OP_NUMEQUALVERIFY), op(OP_2DROP), op(OP_2DROP), op(OP_2DROP), op(OP_2DROP)},
+			wantErr:      false,
+		},
+		{
+			name:         "2rot order",
+			instructions: []Instruction{op(OP_1), op(OP_2), op(OP_3), op(OP_4), op(OP_5), op(OP_6), op(OP_2ROT), op(OP_2), op(OP_NUMEQUALVERIFY), op(OP_1), op(OP_NUMEQUALVERIFY), op(OP_6), op(OP_NUMEQUALVERIFY), op(OP_5), op(OP_NUMEQUALVERIFY), op(OP_4), op(OP_NUMEQUALVERIFY), op(OP_3), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "2swap order",
+			instructions: []Instruction{op(OP_1), op(OP_2), op(OP_3), op(OP_4), op(OP_2SWAP), op(OP_2), op(OP_NUMEQUALVERIFY), op(OP_1), op(OP_NUMEQUALVERIFY), op(OP_4), op(OP_NUMEQUALVERIFY), op(OP_3), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "ifdup nip over size",
+			instructions: []Instruction{op(OP_0), op(OP_IFDUP), op(OP_DEPTH), op(OP_1), op(OP_NUMEQUALVERIFY), push(data("aabbcc")), op(OP_NIP), op(OP_SIZE), op(OP_3), op(OP_NUMEQUALVERIFY), push(data("aabbcc")), op(OP_OVER), op(OP_EQUALVERIFY)},
+			wantErr:      false,
+		},
+		{
+			name:         "sha256",
+			instructions: []Instruction{op(OP_0), op(OP_SHA256), push(data("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")), op(OP_EQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "sha1",
+			instructions: []Instruction{op(OP_0), op(OP_SHA1), push(data("da39a3ee5e6b4b0d3255bfef95601890afd80709")), op(OP_EQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "ripemd160",
+			instructions: []Instruction{op(OP_0), op(OP_RIPEMD160), push(data("9c1185a5c5e9fc54612808977ee8f548b2258d31")), op(OP_EQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "op_return",
+			instructions: []Instruction{op(OP_1), op(OP_RETURN)},
+			wantErr:      true,
+		},
+		{
+			name:         "nops",
+			instructions: []Instruction{op(OP_1), op(OP_NOP), op(OP_NOP1), op(OP_NOP10)},
+			wantErr:      false,
+		},
+		{
+			name:         "if else endif followed by instructions",
+			instructions: []Instruction{op(OP_1), op(OP_IF), op(OP_2), op(OP_ELSE), op(OP_3), op(OP_ENDIF), op(OP_2), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "notif takes else branch",
+			instructions: []Instruction{op(OP_1), op(OP_NOTIF), op(OP_2), op(OP_ELSE), op(OP_3), op(OP_ENDIF), op(OP_3), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "element starting with endif byte inside if",
+			instructions: []Instruction{op(OP_1), op(OP_IF), push(data("6868")), op(OP_ELSE), op(OP_0), op(OP_ENDIF), push(data("6868")), op(OP_EQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "unbalanced endif",
+			instructions: []Instruction{op(OP_1), op(OP_ENDIF)},
+			wantErr:      true,
+		},
+		{
+			name:         "reserved opcode in unexecuted branch",
+			instructions: []Instruction{op(OP_1), op(OP_IF), op(OP_1), op(OP_ELSE), op(OP_RESERVED), op(OP_ENDIF)},
+			wantErr:      false,
+		},
+		{
+			name:         "disabled opcode in unexecuted branch",
+			instructions: []Instruction{op(OP_1), op(OP_IF), op(OP_1), op(OP_ELSE), op(OP_CAT), op(OP_ENDIF)},
+			wantErr:      true,
+		},
+		{
+			name:         "negative zero is false",
+			instructions: []Instruction{push(data("80"))},
+			wantErr:      true,
+		},
+		{
+			name:         "multiple else toggles branch",
+			instructions: []Instruction{op(OP_0), op(OP_IF), op(OP_2), op(OP_ELSE), op(OP_3), op(OP_ELSE), op(OP_4), op(OP_ENDIF), op(OP_3), op(OP_NUMEQUAL)},
+			wantErr:      false,
+		},
+		{
+			name:         "nested if in unexecuted branch",
+			instructions: []Instruction{op(OP_0), op(OP_IF), op(OP_1), op(OP_IF), op(OP_RETURN), op(OP_ENDIF), op(OP_ELSE), op(OP_1), op(OP_ENDIF)},
+			wantErr:      false,
+		},
+		{
+			name:         "unterminated if",
+			instructions: []Instruction{op(OP_1), op(OP_IF), op(OP_1)},
+			wantErr:      true,
+		},
+		{
+			name:         "non-minimal if argument outside tapscript",
+			instructions: []Instruction{push(data("02")), op(OP_IF), op(OP_1), op(OP_ELSE), op(OP_0), op(OP_ENDIF)},
+			wantErr:      false,
+		},
+		{
+			name:         "checksigadd outside tapscript",
+			instructions: []Instruction{push(data("01")), op(OP_0), push(data("02")), op(OP_CHECKSIGADD)},
+			wantErr:      true,
+		},
+		{
+			name:         "checklocktimeverify satisfied",
+			instructions: []Instruction{push(data("a08601")), op(OP_CHECKLOCKTIMEVERIFY)},
+			ctx:          &EvalContext{LockTime: 100000, Sequence: 0xfffffffe},
+			wantErr:      false,
+		},
+		{
+			name:         "checklocktimeverify not yet",
+			instructions: []Instruction{push(data("a18601")), op(OP_CHECKLOCKTIMEVERIFY)},
+			ctx:          &EvalContext{LockTime: 100000, Sequence: 0xfffffffe},
+			wantErr:      true,
+		},
+		{
+			name:         "checklocktimeverify final input",
+			instructions: []Instruction{push(data("a08601")), op(OP_CHECKLOCKTIMEVERIFY)},
+			ctx:          &EvalContext{LockTime: 100000, Sequence: 0xffffffff},
+			wantErr:      true,
+		},
+		{
+			name:         "checksequenceverify satisfied",
+			instructions: []Instruction{push(data("0a")), op(OP_CHECKSEQUENCEVERIFY)},
+			ctx:          &EvalContext{Version: 2, Sequence: 10},
+			wantErr:      false,
+		},
+		{
+			name:         "checksequenceverify version 1",
+			instructions: []Instruction{push(data("0a")), op(OP_CHECKSEQUENCEVERIFY)},
+			ctx:          &EvalContext{Version: 1, Sequence: 10},
+			wantErr:      true,
+		},
+		{
+			name:         "checksequenceverify type mismatch",
+			instructions: []Instruction{push(data("0a")), op(OP_CHECKSEQUENCEVERIFY)},
+			ctx:          &EvalContext{Version: 2, Sequence: sequenceLockTimeTypeFlag | 10},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = &EvalContext{}
+			}
+			s := NewScript()
+			s.Instructions = tt.instructions
+			if err := s.Evaluate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Script.Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// NOTE: pushしたデータがopcodeと同じバイトでも、データとして扱われることを生のスクリプトから確かめる
+func TestScript_EvaluatePushedData(t *testing.T) {
+	type testCase struct {
+		name      string
+		rawScript string
+		ctx       *EvalContext
+		wantErr   bool
+	}
+	tests := []testCase{
+		{
+			name:      "push 100 then checksequenceverify",
+			rawScript: "0164b2",
+			ctx:       &EvalContext{Version: 2, Sequence: 100},
+			wantErr:   false,
+		},
+		{
+			name:      "push 100 then checksequenceverify not yet",
+			rawScript: "0164b2",
+			ctx:       &EvalContext{Version: 2, Sequence: 99},
+			wantErr:   true,
+		},
+		{
+			name:      "push 0x51 is not op_1",
+			rawScript: "015151" + "87",
+			wantErr:   true,
+		},
+		{
+			name:      "push 0x00 has size 1",
+			rawScript: "0100" + "82" + "51" + "87" + "77",
+			wantErr:   false,
+		},
+		{
+			name:      "push 0x00 is not empty",
+			rawScript: "0100" + "00" + "87",
+			wantErr:   true,
+		},
+		{
+			name:      "push 0x00 is false",
+			rawScript: "0100",
+			wantErr:   true,
+		},
+		{
+			name:      "non-minimal pushdata1 of 0x4f",
+			rawScript: "4c014f" + "014f" + "87",
+			wantErr:   false,
+		},
+	}
+	// NOTE: 0x4f から 0xff のどのバイトもデータとしてpushされ、サイズ1で同じ値と等しい
+	for b := 0x4f; b <= 0xff; b++ {
+		element := fmt.Sprintf("01%02x", b)
+		tests = append(tests, testCase{
+			name:      fmt.Sprintf("push 0x%02x as data", b),
+			rawScript: element + "82" + "51" + "88" + element + "87",
+			wantErr:   false,
+		})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = &EvalContext{}
+			}
+			s, err := ParseRawScript(data(tt.rawScript))
+			if err != nil {
+				t.Fatalf("ParseRawScript() error = %v", err)
+			}
+			if err := s.Evaluate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Script.Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScript_EvaluateCheckMultiSig(t *testing.T) {
+	z := big.NewInt(0x1234567890)
+	sigHash := func(hashType uint32, scriptCode *Script) (*big.Int, error) {
+		return z, nil
+	}
+	keys := make([]privkey.PrivKey, 3)
+	pubkeys := make([][]byte, 3)
+	sigs := make([][]byte, 3)
+	for i := range keys {
+		keys[i] = privkey.NewPrivKey(big.NewInt(int64(1000 + i)))
+		pubkeys[i] = keys[i].PubKey().Serialize(true)
+		sigs[i] = append(keys[i].Sign(z).Serialize(), SIGHASH_ALL)
+	}
+	// NOTE: 公開鍵として解釈できないデータ(データ埋め込み用の偽の公開鍵)
+	junkPubkey := append([]byte{0x02}, make([]byte, 32)...)
+	junkPubkey[32] = 0x05
+	// NOTE: numNops個のOP_NOPに続く 0-of-20 multisig。opcodeの数は numNops + 1 + 20
+	nopMultiSig := func(numNops int) []Instruction {
+		instructions := make([]Instruction, 0)
+		for i := 0; i < numNops; i++ {
+			instructions = append(instructions, op(OP_NOP))
+		}
+		instructions = append(instructions, op(OP_0), op(OP_0))
+		for i := 0; i < 20; i++ {
+			instructions = append(instructions, push(pubkeys[0]))
+		}
+		return append(instructions, push([]byte{20}), op(OP_CHECKMULTISIG))
+	}
+
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		flags        VerifyFlags
+		wantErr      bool
+	}{
+		{
+			name:         "1 of 2",
+			instructions: []Instruction{op(OP_0), push(sigs[1]), op(OP_1), push(pubkeys[0]), push(pubkeys[1]), op(OP_2), op(OP_CHECKMULTISIG)},
+			wantErr:      false,
+		},
+		{
+			name:         "2 of 3",
+			instructions: []Instruction{op(OP_0), push(sigs[0]), push(sigs[2]), op(OP_2), push(pubkeys[0]), push(pubkeys[1]), push(pubkeys[2]), op(OP_3), op(OP_CHECKMULTISIG)},
+			wantErr:      false,
+		},
+		{
+			name:         "2 of 3 signatures out of order",
+			instructions: []Instruction{op(OP_0), push(sigs[2]), push(sigs[0]), op(OP_2), push(pubkeys[0]), push(pubkeys[1]), push(pubkeys[2]), op(OP_3), op(OP_CHECKMULTISIG)},
+			wantErr:      true,
+		},
+		{
+			name:         "2 of 3 same signature twice",
+			instructions: []Instruction{op(OP_0), push(sigs[0]), push(sigs[0]), op(OP_2), push(pubkeys[0]), push(pubkeys[1]), push(pubkeys[2]), op(OP_3), op(OP_CHECKMULTISIG)},
+			wantErr:      true,
+		},
+		{
+			name:         "1 of 2 with junk pubkey",
+			instructions: []Instruction{op(OP_0), push(sigs[1]), op(OP_1), push(junkPubkey), push(pubkeys[1]), op(OP_2), op(OP_CHECKMULTISIG)},
+			wantErr:      false,
+		},
+		{
+			name:         "0 of 0",
+			instructions: []Instruction{op(OP_0), op(OP_0), op(OP_0), op(OP_CHECKMULTISIG)},
+			wantErr:      false,
+		},
+		{
+			name:         "missing dummy",
+			instructions: []Instruction{push(sigs[1]), op(OP_1), push(pubkeys[0]), push(pubkeys[1]), op(OP_2), op(OP_CHECKMULTISIG)},
+			wantErr:      true,
+		},
+		{
+			name:         "non-null dummy without nulldummy",
+			instructions: []Instruction{op(OP_1), push(sigs[1]), op(OP_1), push(pubkeys[0]), push(pubkeys[1]), op(OP_2), op(OP_CHECKMULTISIG)},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      false,
+		},
+		{
+			name:         "non-null dummy with nulldummy",
+			instructions: []Instruction{op(OP_1), push(sigs[1]), op(OP_1), push(pubkeys[0]), push(pubkeys[1]), op(OP_2), op(OP_CHECKMULTISIG)},
+			flags:        SCRIPT_VERIFY_NULLDUMMY,
+			wantErr:      true,
+		},
+		{
+			name:         "more signatures than pubkeys",
+			instructions: []Instruction{op(OP_0), push(sigs[0]), push(sigs[1]), op(OP_2), push(pubkeys[0]), op(OP_1), op(OP_CHECKMULTISIG)},
+			wantErr:      true,
+		},
+		{
+			name:         "pubkeys count toward op limit",
+			instructions: nopMultiSig(180),
+			wantErr:      false,
+		},
+		{
+			name:         "pubkeys exceed op limit",
+			instructions: nopMultiSig(181),
+			wantErr:      true,
+		},
+		{
+			name:         "checkmultisigverify",
+			instructions: []Instruction{op(OP_0), push(sigs[0]), op(OP_1), push(pubkeys[0]), op(OP_1), op(OP_CHECKMULTISIGVERIFY), op(OP_1)},
+			wantErr:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScript()
+			s.Instructions = tt.instructions
+			if err := s.Evaluate(&EvalContext{SigHash: sigHash, Flags: tt.flags}); (err != nil) != tt.wantErr {
+				t.Errorf("Script.Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScript_EvaluateCheckSigEncoding(t *testing.T) {
+	z := big.NewInt(0x1234567890)
+	sigHash := func(hashType uint32, scriptCode *Script) (*big.Int, error) {
+		return z, nil
+	}
+	key := privkey.NewPrivKey(big.NewInt(1000))
+	pubkey := key.PubKey().Serialize(true)
+	sig := key.Sign(z)
+	der := sig.Serialize()
+	valid := append(der, SIGHASH_ALL)
+	// NOTE: 末尾にゴミがあるDERはBIP66以前のみ有効
+	trailing := append(append([]byte{}, der...), 0x00, SIGHASH_ALL)
+	// NOTE: BIP66以前でもパースできない署名は検証失敗として扱う
+	unparsable := []byte{0x30, 0x01, SIGHASH_ALL}
+	highS := append(signature.NewSignature(sig.R(), new(big.Int).Sub(secp256k1.NewSecp256k1n(), sig.S())).Serialize(), SIGHASH_ALL)
+
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		flags        VerifyFlags
+		wantErr      bool
+	}{
+		{
+			name:         "strict der",
+			instructions: []Instruction{push(valid), push(pubkey), op(OP_CHECKSIG)},
+			flags:        SCRIPT_VERIFY_DERSIG,
+			wantErr:      false,
+		},
+		{
+			name:         "trailing garbage without dersig",
+			instructions: []Instruction{push(trailing), push(pubkey), op(OP_CHECKSIG)},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      false,
+		},
+		{
+			name:         "trailing garbage with dersig",
+			instructions: []Instruction{push(trailing), push(pubkey), op(OP_CHECKSIG)},
+			flags:        SCRIPT_VERIFY_DERSIG,
+			wantErr:      true,
+		},
+		{
+			name:         "unparsable without dersig",
+			instructions: []Instruction{push(unparsable), push(pubkey), op(OP_CHECKSIG), op(OP_NOT)},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      false,
+		},
+		{
+			name:         "unparsable with dersig",
+			instructions: []Instruction{push(unparsable), push(pubkey), op(OP_CHECKSIG), op(OP_NOT)},
+			flags:        SCRIPT_VERIFY_DERSIG,
+			wantErr:      true,
+		},
+		{
+			name:         "high s without low_s",
+			instructions: []Instruction{push(highS), push(pubkey), op(OP_CHECKSIG)},
+			flags:        SCRIPT_VERIFY_DERSIG,
+			wantErr:      false,
+		},
+		{
+			name:         "high s with low_s",
+			instructions: []Instruction{push(highS), push(pubkey), op(OP_CHECKSIG)},
+			flags:        SCRIPT_VERIFY_DERSIG | SCRIPT_VERIFY_LOW_S,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScript()
+			s.Instructions = tt.instructions
+			if err := s.Evaluate(&EvalContext{SigHash: sigHash, Flags: tt.flags}); (err != nil) != tt.wantErr {
+				t.Errorf("Script.Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// NOTE: 署名ハッシュには、最後に実行されたOP_CODESEPARATORより後ろの部分をscriptCodeとして渡す
+func TestScript_ExecuteScriptCode(t *testing.T) {
+	// NOTE: scriptCodeを記録するだけなので、署名はパースできれば検証に失敗してよい
+	sig := data("300602010102010101")
+	pubkey := data("02")
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{name: "no code separator", script: "61ac", want: "61ac"},
+		{name: "executed code separator", script: "61ab5175ac", want: "5175ac"},
+		{name: "last executed code separator", script: "ab61abac", want: "ac"},
+		{name: "code separator in unexecuted branch", script: "61ab0063ab68ac", want: "0063ab68ac"},
+		{name: "code separator after checksig", script: "61ac75abac", want: "ac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			sigHash := func(hashType uint32, scriptCode *Script) (*big.Int, error) {
+				serialized, err := scriptCode.Serialize()
+				if err != nil {
+					return nil, err
+				}
+				got = append(got, hex.EncodeToString(serialized))
+				return big.NewInt(1), nil
+			}
+			script, err := ParseRawScript(data(tt.script))
+			if err != nil {
+				t.Fatalf("ParseRawScript() error = %v", err)
+			}
+			s := NewScript()
+			s.Stack = [][]byte{sig, pubkey, sig, pubkey}
+			s.Add(script)
+			if err := s.Execute(&EvalContext{SigHash: sigHash}); err != nil {
+				t.Fatalf("Script.Execute() error = %v", err)
+			}
+			if len(got) == 0 || got[len(got)-1] != tt.want {
+				t.Errorf("scriptCode = %v, want last %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScript_EvaluateTapscript(t *testing.T) {
+	// NOTE: 32バイト以外の公開鍵は未定義の種類として、空でない署名は常に成功する
+	unknownPubkey := data("02" + strings.Repeat("11", 32))
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		sigOpsBudget int
+		wantErr      bool
+	}{
+		{
+			name:         "checksigadd with unknown pubkey type",
+			instructions: []Instruction{push(data("01")), op(OP_0), push(unknownPubkey), op(OP_CHECKSIGADD), push(data("01")), op(OP_SWAP), push(unknownPubkey), op(OP_CHECKSIGADD), op(OP_2), op(OP_NUMEQUAL)},
+			sigOpsBudget: 100,
+			wantErr:      false,
+		},
+		{
+			name:         "checksigadd with empty signature",
+			instructions: []Instruction{op(OP_0), op(OP_0), push(unknownPubkey), op(OP_CHECKSIGADD), op(OP_0), op(OP_NUMEQUAL)},
+			sigOpsBudget: 100,
+			wantErr:      false,
+		},
+		{
+			name:         "checksig with empty pubkey",
+			instructions: []Instruction{push(data("01")), op(OP_0), op(OP_CHECKSIG)},
+			sigOpsBudget: 100,
+			wantErr:      true,
+		},
+		{
+			name:         "sigops budget exceeded",
+			instructions: []Instruction{push(data("01")), push(unknownPubkey), op(OP_CHECKSIGVERIFY), push(data("01")), push(unknownPubkey), op(OP_CHECKSIG)},
+			sigOpsBudget: 50,
+			wantErr:      true,
+		},
+		{
+			name:         "non-minimal if argument",
+			instructions: []Instruction{push(data("02")), op(OP_IF), op(OP_1), op(OP_ELSE), op(OP_0), op(OP_ENDIF)},
+			sigOpsBudget: 100,
+			wantErr:      true,
+		},
+		{
+			name:         "minimal if argument",
+			instructions: []Instruction{push(data("01")), op(OP_IF), op(OP_1), op(OP_ELSE), op(OP_0), op(OP_ENDIF)},
+			sigOpsBudget: 100,
+			wantErr:      false,
+		},
+		{
+			name:         "checkmultisig is disabled",
+			instructions: []Instruction{op(OP_0), op(OP_0), op(OP_0), op(OP_CHECKMULTISIG)},
+			sigOpsBudget: 100,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScript()
+			s.Instructions = tt.instructions
+			ctx := &EvalContext{tapscript: true, codeSepPos: 0xffffffff, sigOpsBudget: tt.sigOpsBudget}
+			if err := s.Evaluate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Script.Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_containsOpSuccess(t *testing.T) {
+	tests := []struct {
+		name      string
+		rawScript string
+		want      bool
+		wantErr   bool
+	}{
+		{"op_success80", "5150", true, false},
+		{"op_cat", "7e", true, false},
+		{"success byte inside push", "0150", false, false},
+		{"success byte inside pushdata1", "4c0250507551", false, false},
+		{"checksigadd is not success", "ba", false, false},
+		{"truncated push", "02aa", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := containsOpSuccess(data(tt.rawScript))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("containsOpSuccess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("containsOpSuccess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,33 +1,141 @@
 package script
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"golang-bitcoin/pkg/bech32"
+	"golang-bitcoin/pkg/chaincfg"
 	"golang-bitcoin/pkg/secp256k1"
 	"golang-bitcoin/pkg/utils"
 	"io"
 	"math/big"
 )
 
+const (
+	SIGHASH_ALL          = 0x01
+	SIGHASH_NONE         = 0x02
+	SIGHASH_SINGLE       = 0x03
+	SIGHASH_ANYONECANPAY = 0x80
+)
+
+const (
+	maxOpsPerScript       = 201
+	maxStackSize          = 1000
+	maxElementSize        = 520
+	maxPubKeysPerMultiSig = 20
+	maxScriptSize         = 10000
+)
+
+type VerifyFlags uint32
+
+const (
+	SCRIPT_VERIFY_NONE VerifyFlags = 0
+	// NOTE: BIP147 OP_CHECKMULTISIGのダミー要素は空でなければならない
+	SCRIPT_VERIFY_NULLDUMMY VerifyFlags = 1 << 0
+	// NOTE: BIP16 P2SHのredeem scriptを評価する
+	SCRIPT_VERIFY_P2SH VerifyFlags = 1 << 1
+	// NOTE: BIP141 witness programを評価する。SCRIPT_VERIFY_P2SHと併用する
+	SCRIPT_VERIFY_WITNESS VerifyFlags = 1 << 2
+	// NOTE: BIP341, BIP342 witness version 1のtaprootを評価する
+	SCRIPT_VERIFY_TAPROOT VerifyFlags = 1 << 3
+	// NOTE: BIP66 署名は厳格なDERでなければならない。指定しない場合はOpenSSL互換の緩いDERとしてパースする
+	SCRIPT_VERIFY_DERSIG VerifyFlags = 1 << 4
+	// NOTE: BIP62 sがn/2より大きい署名を拒否する。コンセンサスではなくポリシー
+	SCRIPT_VERIFY_LOW_S VerifyFlags = 1 << 5
+	// NOTE: BIP62 P2SHに限らずScriptSigはpushのみでなければならない。コンセンサスではなくポリシー
+	SCRIPT_VERIFY_SIGPUSHONLY VerifyFlags = 1 << 6
+)
+
+// NOTE: トランザクションの検証で使う標準のフラグ
+const DEFAULT_VERIFY_FLAGS = SCRIPT_VERIFY_NULLDUMMY | SCRIPT_VERIFY_P2SH | SCRIPT_VERIFY_WITNESS | SCRIPT_VERIFY_TAPROOT | SCRIPT_VERIFY_DERSIG
+
+// NOTE: 署名のハッシュタイプに応じて署名対象のzを計算する関数
+// NOTE: scriptCodeは実行中のscriptのうち、最後に実行されたOP_CODESEPARATORより後ろの部分
+type SigHashFunc func(hashType uint32, scriptCode *Script) (*big.Int, error)
+
+// NOTE: BIP341の署名対象のハッシュを計算する関数。leafHashがnilの場合はkey path spend
+type TaprootSigHashFunc func(hashType uint32, leafHash []byte, codeSepPos uint32) ([]byte, error)
+
+// NOTE: スクリプトの評価に必要な、検証対象のトランザクション側の情報
+type EvalContext struct {
+	SigHash        SigHashFunc
+	TaprootSigHash TaprootSigHashFunc
+	Version        uint32
+	LockTime       uint32
+	Sequence       uint32
+	Flags          VerifyFlags
+
+	// NOTE: 実行中のscriptの状態。最後に実行されたOP_CODESEPARATORの位置と、それより後ろのscript
+	codeSepPos uint32
+	scriptCode *Script
+	// NOTE: BIP143 witness version 0のscriptを実行中か。署名ハッシュの計算方法が異なる
+	witnessV0 bool
+
+	// NOTE: tapscriptの実行中のみ使う状態
+	tapscript    bool
+	leafHash     []byte
+	sigOpsBudget int
+}
+
+// NOTE: scriptの1命令。pushの場合、Opはpushに使ったopcode (OP_0, 0x01-0x4b, OP_PUSHDATA1/2/4) でDataがpushするデータ
+// NOTE: 元のopcodeを保持するので、最短でないpushや1バイトのpushもparseしたとおりにシリアライズできる
+type Instruction struct {
+	Op   byte
+	Data []byte
+}
+
+func NewOpInstruction(op byte) Instruction {
+	return Instruction{Op: op}
+}
+
+// NOTE: データの長さに応じて最短のpushのopcodeを選ぶ。空のデータはOP_0でpushする
+func NewPushInstruction(data []byte) Instruction {
+	length := len(data)
+	switch {
+	case length == 0:
+		return Instruction{Op: OP_0, Data: []byte{}}
+	case length <= 75:
+		return Instruction{Op: byte(length), Data: data}
+	case length <= 0xff:
+		return Instruction{Op: OP_PUSHDATA1, Data: data}
+	case length <= 0xffff:
+		return Instruction{Op: OP_PUSHDATA2, Data: data}
+	default:
+		return Instruction{Op: OP_PUSHDATA4, Data: data}
+	}
+}
+
+// NOTE: OP_1NEGATEやOP_1-OP_16はpushではなくopcodeとして実行する
+func (i Instruction) IsPush() bool {
+	return i.Op <= OP_PUSHDATA4
+}
+
 type Script struct {
-	Instructions [][]byte
+	Instructions []Instruction
 	Stack        [][]byte
 	AltStack     [][]byte
+	condStack    []bool
+	// NOTE: 実行中のscriptのopcodeの数。OP_CHECKMULTISIGでは公開鍵の数も加える
+	numOps int
+	// NOTE: 途中で切れたpushなど、命令としてparseできなかった末尾のバイト列
+	unparsed []byte
 }
 
 func NewScript() *Script {
 	return &Script{
-		Instructions: make([][]byte, 0),
+		Instructions: make([]Instruction, 0),
 	}
 }
 
-func (s *Script) PopInstruction() ([]byte, error) {
+func (s *Script) PopInstruction() (Instruction, error) {
 	if len(s.Instructions) == 0 {
-		return nil, fmt.Errorf("no instructions to pop")
+		return Instruction{}, fmt.Errorf("no instructions to pop")
 	}
-	element := s.Instructions[0]
+	inst := s.Instructions[0]
 	s.Instructions = s.Instructions[1:]
-	return element, nil
+	return inst, nil
 }
 
 func (s *Script) PopStack() ([]byte, error) {
@@ -48,172 +156,690 @@ func (s *Script) PopAltStack() ([]byte, error) {
 	return element, nil
 }
 
+// NOTE: 長さのprefixに続くscriptをparseする。長さは確保する前に実際に読めたバイト数で確かめる
 func ParseScript(reader io.Reader) (*Script, error) {
-	script := NewScript()
-	len, err := utils.ParseVarInt(reader)
+	length, err := utils.ParseVarInt(reader)
 	if err != nil {
 		return nil, err
 	}
-	var i uint64
-	for i < len {
-		buf := make([]byte, 1)
-		if _, err := io.ReadFull(reader, buf); err != nil {
-			return nil, err
-		}
-		i += 1
-
-		if buf[0] >= 1 && buf[0] <= 75 {
-			// NOTE: element
-			elementLen := int(buf[0])
-			buf = make([]byte, elementLen)
-			if _, err := io.ReadFull(reader, buf); err != nil {
-				return nil, err
-			}
-			i += uint64(elementLen)
-
-			script.Instructions = append(script.Instructions, buf)
-		} else if buf[0] == OP_PUSHDATA1 {
-			buf = make([]byte, 1)
-			if _, err := io.ReadFull(reader, buf); err != nil {
-				return nil, err
-			}
-			i += 1
-
-			elementLen := int(buf[0])
-			buf = make([]byte, elementLen)
-			if _, err := io.ReadFull(reader, buf); err != nil {
-				return nil, err
-			}
-			i += uint64(elementLen)
+	raw, err := utils.ReadBytes(reader, length)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: トランザクション中のscriptはparseできなくてもよく、評価したときに失敗する。txidが変わらないように末尾を保持する
+	script := NewScript()
+	script.Instructions, script.unparsed = parseInstructions(raw)
+	return script, nil
+}
 
-			script.Instructions = append(script.Instructions, buf)
-		} else if buf[0] == OP_PUSHDATA2 {
-			buf = make([]byte, 2)
-			if _, err := io.ReadFull(reader, buf); err != nil {
-				return nil, err
-			}
-			i += 2
+// NOTE: 長さのprefixを持たないscriptをparseする。parseできない命令があればエラーにする
+func ParseRawScript(raw []byte) (*Script, error) {
+	script := NewScript()
+	var unparsed []byte
+	script.Instructions, unparsed = parseInstructions(raw)
+	if len(unparsed) > 0 {
+		return nil, fmt.Errorf("script is truncated")
+	}
+	return script, nil
+}
 
-			elementLen := binary.LittleEndian.Uint16(buf)
-			buf = make([]byte, elementLen)
-			if _, err := io.ReadFull(reader, buf); err != nil {
-				return nil, err
-			}
-			i += uint64(elementLen)
+// NOTE: 先頭から読めるだけ命令を読み、parseできなかった位置以降のバイト列も返す
+func parseInstructions(raw []byte) ([]Instruction, []byte) {
+	instructions := make([]Instruction, 0)
+	for pos := 0; pos < len(raw); {
+		inst, next, err := readInstruction(raw, pos)
+		if err != nil {
+			return instructions, raw[pos:]
+		}
+		instructions = append(instructions, inst)
+		pos = next
+	}
+	return instructions, nil
+}
 
-			script.Instructions = append(script.Instructions, buf)
-		} else {
-			// NOTE: opcode
-			script.Instructions = append(script.Instructions, buf)
+// NOTE: raw[pos:]の先頭の命令と次の命令の位置を返す。pushの長さは残りのバイト数を超えてはならない
+func readInstruction(raw []byte, pos int) (Instruction, int, error) {
+	op := raw[pos]
+	pos += 1
+	var length int
+	switch {
+	case op > OP_PUSHDATA4:
+		return Instruction{Op: op}, pos, nil
+	case op <= 75:
+		length = int(op)
+	case op == OP_PUSHDATA1:
+		if len(raw)-pos < 1 {
+			return Instruction{}, 0, fmt.Errorf("script is truncated")
+		}
+		length = int(raw[pos])
+		pos += 1
+	case op == OP_PUSHDATA2:
+		if len(raw)-pos < 2 {
+			return Instruction{}, 0, fmt.Errorf("script is truncated")
+		}
+		length = int(binary.LittleEndian.Uint16(raw[pos:]))
+		pos += 2
+	default:
+		if len(raw)-pos < 4 {
+			return Instruction{}, 0, fmt.Errorf("script is truncated")
+		}
+		length64 := uint64(binary.LittleEndian.Uint32(raw[pos:]))
+		pos += 4
+		if length64 > uint64(len(raw)-pos) {
+			return Instruction{}, 0, fmt.Errorf("script is truncated")
 		}
+		length = int(length64)
 	}
-	return script, nil
+	if length > len(raw)-pos {
+		return Instruction{}, 0, fmt.Errorf("script is truncated")
+	}
+	data := make([]byte, length)
+	copy(data, raw[pos:pos+length])
+	return Instruction{Op: op, Data: data}, pos + length, nil
 }
 
 func (s *Script) Serialize() ([]byte, error) {
 	buf := make([]byte, 0)
 	for _, inst := range s.Instructions {
-		if IsOp(inst) {
-			// NOTE: opcode
-			buf = append(buf, inst...)
-		} else {
-			// NOTE: element
-			length := len(inst)
-			// NOTE: serialize length first
-			if length <= 75 {
-				buf = append(buf, byte(length))
-			} else if length > 75 && length < 0x100 {
-				buf = append(buf, OP_PUSHDATA1)
-				buf = append(buf, byte(length))
-			} else if length >= 0x100 && length < 520 {
-				buf = append(buf, OP_PUSHDATA2)
-				binary.LittleEndian.AppendUint16(buf, uint16(length))
-			} else {
-				return nil, fmt.Errorf("element is too long")
+		buf = append(buf, inst.Op)
+		if !inst.IsPush() {
+			continue
+		}
+		// NOTE: pushのopcodeで表せる長さかを確かめてから長さを書く
+		length := len(inst.Data)
+		switch {
+		case inst.Op <= 75:
+			if length != int(inst.Op) {
+				return nil, fmt.Errorf("push length mismatch: opcode %x, length %d", inst.Op, length)
+			}
+		case inst.Op == OP_PUSHDATA1:
+			if length > 0xff {
+				return nil, fmt.Errorf("element is too long for OP_PUSHDATA1")
+			}
+			buf = append(buf, byte(length))
+		case inst.Op == OP_PUSHDATA2:
+			if length > 0xffff {
+				return nil, fmt.Errorf("element is too long for OP_PUSHDATA2")
+			}
+			buf = binary.LittleEndian.AppendUint16(buf, uint16(length))
+		default:
+			if uint64(length) > 0xffffffff {
+				return nil, fmt.Errorf("element is too long for OP_PUSHDATA4")
 			}
-			buf = append(buf, inst...)
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(length))
 		}
+		buf = append(buf, inst.Data...)
 	}
+	buf = append(buf, s.unparsed...)
 	return buf, nil
 }
 
 func (s *Script) Add(other *Script) {
 	s.Instructions = append(s.Instructions, other.Instructions...)
+	// NOTE: sの末尾がparseできない場合は、何を足しても評価に失敗する
+	if len(s.unparsed) == 0 {
+		s.unparsed = other.unparsed
+	}
+}
+
+func (s *Script) Evaluate(ctx *EvalContext) error {
+	if err := s.Execute(ctx); err != nil {
+		return err
+	}
+	if len(s.Stack) == 0 {
+		return fmt.Errorf("stack is empty")
+	}
+	if len(s.Stack) > 1 {
+		return fmt.Errorf("stack has multiple elements")
+	}
+	if len(s.AltStack) > 0 {
+		return fmt.Errorf("alt stack is not empty")
+	}
+	element := s.Stack[0]
+	if !castToBool(element) {
+		return fmt.Errorf("stack top element is zero")
+	}
+
+	return nil
 }
 
-func (s *Script) Evaluate(z *big.Int) error {
-	for len(s.Instructions) > 0 {
+// NOTE: 現在のStackに対して命令を実行する。実行後のStackの検査は呼び出し側で行う
+func (s *Script) Execute(ctx *EvalContext) error {
+	if len(s.unparsed) > 0 {
+		return fmt.Errorf("script is truncated")
+	}
+	// NOTE: tapscriptを除き、scriptは実行する前に長さの上限を確かめる
+	if !ctx.tapscript {
+		raw, err := s.Serialize()
+		if err != nil {
+			return err
+		}
+		if len(raw) > maxScriptSize {
+			return fmt.Errorf("script is too long: %d bytes", len(raw))
+		}
+	}
+	// NOTE: 無効なopcodeは分岐の内側で実行されない場合でも失敗とする
+	s.numOps = 0
+	for _, inst := range s.Instructions {
+		if inst.IsPush() {
+			if len(inst.Data) > maxElementSize {
+				return fmt.Errorf("element is too long")
+			}
+			continue
+		}
+		if isDisabledOp(inst.Op) {
+			return fmt.Errorf("disabled opcode: %x", inst.Op)
+		}
+		if inst.Op > OP_16 {
+			s.numOps += 1
+		}
+	}
+	// NOTE: tapscriptにはopcode数の上限はない
+	if s.numOps > maxOpsPerScript && !ctx.tapscript {
+		return fmt.Errorf("too many opcodes")
+	}
+
+	s.condStack = nil
+	// NOTE: OP_CODESEPARATORを実行するまでは、署名ハッシュのscriptCodeは実行中のscript全体
+	ctx.scriptCode = &Script{Instructions: s.Instructions}
+	// NOTE: OP_CODESEPARATORの位置は、実行されたかどうかに関わらず先頭からの命令の数で数える
+	for pos := uint32(0); len(s.Instructions) > 0; pos++ {
 		inst, err := s.PopInstruction()
 		if err != nil {
 			return err
 		}
-		if IsOp(inst) {
+		// NOTE: 実行されない分岐の中でも、分岐の対応関係を追うために条件分岐のopcodeは処理する
+		if !s.isExecuting() && (inst.IsPush() || inst.Op < OP_IF || inst.Op > OP_ENDIF) {
+			continue
+		}
+		if inst.IsPush() {
+			// NOTE: element。OP_0は空のデータのpush
+			element := inst.Data
+			if element == nil {
+				element = []byte{}
+			}
+			s.Stack = append(s.Stack, element)
+		} else {
 			/// NOTE: opcode
-			intInst := decodeNum(inst)
-			switch intInst {
-			case OP_DUP:
-				s.OpDup()
-			case OP_HASH160:
-				s.OpHash160()
-			case OP_HASH256:
-				s.OpHash256()
-			case OP_EQUAL:
-				s.OpEqual()
-			case OP_EQUALVERIFY:
-				s.OpEqualVerify()
+			switch op := inst.Op; op {
+			case OP_1NEGATE:
+				err = s.OpNumber(-1)
+			case OP_1, OP_2, OP_3, OP_4, OP_5, OP_6, OP_7, OP_8, OP_9, OP_10, OP_11, OP_12, OP_13, OP_14, OP_15, OP_16:
+				err = s.OpNumber(int64(op) - OP_1 + 1)
+			case OP_NOP, OP_NOP1, OP_NOP4, OP_NOP5, OP_NOP6, OP_NOP7, OP_NOP8, OP_NOP9, OP_NOP10:
+				err = s.OpNop()
 			case OP_IF:
-				s.OpIf()
+				err = s.OpIf(ctx)
 			case OP_NOTIF:
-				s.OpNotIf()
+				err = s.OpNotIf(ctx)
+			case OP_ELSE:
+				err = s.OpElse()
+			case OP_ENDIF:
+				err = s.OpEndIf()
+			case OP_VERIFY:
+				err = s.OpVerify()
+			case OP_RETURN:
+				err = s.OpReturn()
 			case OP_TOALTSTACK:
-				s.OpToAltStack()
+				err = s.OpToAltStack()
 			case OP_FROMALTSTACK:
-				s.OpFromAltStack()
+				err = s.OpFromAltStack()
+			case OP_2DROP:
+				err = s.Op2Drop()
+			case OP_2DUP:
+				err = s.Op2Dup()
+			case OP_3DUP:
+				err = s.Op3Dup()
+			case OP_2OVER:
+				err = s.Op2Over()
+			case OP_2ROT:
+				err = s.Op2Rot()
+			case OP_2SWAP:
+				err = s.Op2Swap()
+			case OP_IFDUP:
+				err = s.OpIfDup()
+			case OP_DEPTH:
+				err = s.OpDepth()
+			case OP_DROP:
+				err = s.OpDrop()
+			case OP_DUP:
+				err = s.OpDup()
+			case OP_NIP:
+				err = s.OpNip()
+			case OP_OVER:
+				err = s.OpOver()
+			case OP_PICK:
+				err = s.OpPick()
+			case OP_ROLL:
+				err = s.OpRoll()
+			case OP_ROT:
+				err = s.OpRot()
+			case OP_SWAP:
+				err = s.OpSwap()
+			case OP_TUCK:
+				err = s.OpTuck()
+			case OP_SIZE:
+				err = s.OpSize()
+			case OP_EQUAL:
+				err = s.OpEqual()
+			case OP_EQUALVERIFY:
+				err = s.OpEqualVerify()
+			case OP_1ADD:
+				err = s.Op1Add()
+			case OP_1SUB:
+				err = s.Op1Sub()
+			case OP_NEGATE:
+				err = s.OpNegate()
+			case OP_ABS:
+				err = s.OpAbs()
+			case OP_NOT:
+				err = s.OpNot()
+			case OP_0NOTEQUAL:
+				err = s.Op0NotEqual()
+			case OP_ADD:
+				err = s.OpAdd()
+			case OP_SUB:
+				err = s.OpSub()
+			case OP_BOOLAND:
+				err = s.OpBoolAnd()
+			case OP_BOOLOR:
+				err = s.OpBoolOr()
+			case OP_NUMEQUAL:
+				err = s.OpNumEqual()
+			case OP_NUMEQUALVERIFY:
+				err = s.OpNumEqualVerify()
+			case OP_NUMNOTEQUAL:
+				err = s.OpNumNotEqual()
+			case OP_LESSTHAN:
+				err = s.OpLessThan()
+			case OP_GREATERTHAN:
+				err = s.OpGreaterThan()
+			case OP_LESSTHANOREQUAL:
+				err = s.OpLessThanOrEqual()
+			case OP_GREATERTHANOREQUAL:
+				err = s.OpGreaterThanOrEqual()
+			case OP_MIN:
+				err = s.OpMin()
+			case OP_MAX:
+				err = s.OpMax()
+			case OP_WITHIN:
+				err = s.OpWithin()
+			case OP_RIPEMD160:
+				err = s.OpRipemd160()
+			case OP_SHA1:
+				err = s.OpSha1()
+			case OP_SHA256:
+				err = s.OpSha256()
+			case OP_HASH160:
+				err = s.OpHash160()
+			case OP_HASH256:
+				err = s.OpHash256()
+			case OP_CODESEPARATOR:
+				// NOTE: 以降の署名はこのOP_CODESEPARATORより後ろの部分に対して計算する。
+				//       tapscriptでは代わりに位置を署名ハッシュに含める
+				ctx.codeSepPos = pos
+				ctx.scriptCode = &Script{Instructions: s.Instructions}
+				err = s.OpNop()
 			case OP_CHECKSIG:
-				s.OpCheckSig(z)
+				err = s.OpCheckSig(ctx)
+			case OP_CHECKSIGVERIFY:
+				err = s.OpCheckSigVerify(ctx)
+			case OP_CHECKMULTISIG:
+				err = s.OpCheckMultiSig(ctx)
+			case OP_CHECKMULTISIGVERIFY:
+				err = s.OpCheckMultiSigVerify(ctx)
+			case OP_CHECKSIGADD:
+				err = s.OpCheckSigAdd(ctx)
+			case OP_CHECKLOCKTIMEVERIFY:
+				err = s.OpCheckLockTimeVerify(ctx)
+			case OP_CHECKSEQUENCEVERIFY:
+				err = s.OpCheckSequenceVerify(ctx)
 			default:
-				return fmt.Errorf("unsupported opcode")
+				// NOTE: OP_RESERVED, OP_VER, OP_RESERVED1, OP_RESERVED2や未定義のopcode
+				return fmt.Errorf("unsupported opcode: %x", op)
+			}
+			if err != nil {
+				return err
 			}
-		} else {
-			// NOTE: element
-			s.Stack = append(s.Stack, inst)
+		}
+		if len(s.Stack)+len(s.AltStack) > maxStackSize {
+			return fmt.Errorf("stack size limit exceeded")
 		}
 	}
-	if len(s.Stack) == 0 {
-		return fmt.Errorf("stack is empty")
+	if len(s.condStack) != 0 {
+		return fmt.Errorf("unbalanced conditional")
 	}
-	if len(s.Stack) > 1 {
-		return fmt.Errorf("stack has multiple elements")
+
+	return nil
+}
+
+func VerifyScript(scriptSig, scriptPubkey *Script, witness [][]byte, ctx *EvalContext) error {
+	if ctx.Flags&SCRIPT_VERIFY_SIGPUSHONLY != 0 && !scriptSig.IsPushOnly() {
+		return fmt.Errorf("scriptSig is not push only")
 	}
-	if len(s.AltStack) > 0 {
-		return fmt.Errorf("alt stack is not empty")
+	witnessEnabled := ctx.Flags&SCRIPT_VERIFY_WITNESS != 0
+	if witnessEnabled {
+		if version, program, ok := scriptPubkey.WitnessProgram(); ok {
+			// NOTE: witness programを使う場合、ScriptSigは空でなければならない
+			if len(scriptSig.Instructions) != 0 {
+				return fmt.Errorf("witness program scriptSig must be empty")
+			}
+			// NOTE: OP_0 <program> の実行結果はprogramになる
+			if !castToBool(program) {
+				return fmt.Errorf("stack top element is zero")
+			}
+			return verifyWitnessProgram(version, program, witness, false, ctx)
+		}
 	}
-	element := s.Stack[0]
-	if decodeNum(element) == 0 {
+
+	isP2SH := ctx.Flags&SCRIPT_VERIFY_P2SH != 0 && scriptPubkey.IsP2SHScriptPubkey()
+	// NOTE: BIP16 ScriptSigはpushのみで構成され、最後にpushされた要素がredeem scriptとなる
+	if isP2SH && !scriptSig.IsPushOnly() {
+		return fmt.Errorf("p2sh scriptSig is not push only")
+	}
+
+	// NOTE: ScriptSigとscriptPubkeyは結合せずに別々に実行し、Stackだけを引き継ぐ
+	// NOTE: 結合するとScriptSigの閉じていないOP_IFなどがscriptPubkeyの実行に影響してしまう
+	stack, err := executeWithStack(scriptSig, nil, ctx)
+	if err != nil {
+		return err
+	}
+	if !isP2SH {
+		stack, err = executeWithStack(scriptPubkey, stack, ctx)
+		if err != nil {
+			return err
+		}
+		if err := checkStackTop(stack); err != nil {
+			return err
+		}
+		return checkUnexpectedWitness(witness, ctx)
+	}
+
+	if len(stack) == 0 {
+		return fmt.Errorf("p2sh scriptSig has no redeem script")
+	}
+	// NOTE: まずはredeem scriptのハッシュがscriptPubkeyと一致することを確認する
+	hashStack := make([][]byte, len(stack))
+	copy(hashStack, stack)
+	hashStack, err = executeWithStack(scriptPubkey, hashStack, ctx)
+	if err != nil {
+		return err
+	}
+	if len(hashStack) == 0 || !castToBool(hashStack[len(hashStack)-1]) {
+		return fmt.Errorf("p2sh redeem script hash mismatch")
+	}
+
+	redeemScript, err := scriptSig.RedeemScript()
+	if err != nil {
+		return err
+	}
+	if witnessEnabled {
+		if version, program, ok := redeemScript.WitnessProgram(); ok {
+			// NOTE: P2SH-P2WPKH, P2SH-P2WSHのScriptSigはredeem scriptのpushのみ
+			if len(scriptSig.Instructions) != 1 {
+				return fmt.Errorf("p2sh witness program scriptSig must be a single push")
+			}
+			return verifyWitnessProgram(version, program, witness, true, ctx)
+		}
+	}
+	stack, err = executeWithStack(redeemScript, stack[:len(stack)-1], ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkStackTop(stack); err != nil {
+		return err
+	}
+	return checkUnexpectedWitness(witness, ctx)
+}
+
+// NOTE: legacyとP2SHでは、Stackの一番上の要素が真であればよい。残りの要素やAltStackが残っていてもコンセンサス上は有効
+// NOTE: 要素が1つだけ残ることを求めるのはwitnessとtapscriptのみ
+func checkStackTop(stack [][]byte) error {
+	if len(stack) == 0 {
+		return fmt.Errorf("stack is empty")
+	}
+	if !castToBool(stack[len(stack)-1]) {
 		return fmt.Errorf("stack top element is zero")
 	}
+	return nil
+}
+
+// NOTE: 与えたStackの上でscriptを実行し、実行後のStackを返す。AltStackや分岐の状態は引き継がない
+func executeWithStack(script *Script, stack [][]byte, ctx *EvalContext) ([][]byte, error) {
+	s := NewScript()
+	s.Stack = stack
+	s.Add(script)
+	if err := s.Execute(ctx); err != nil {
+		return nil, err
+	}
+	return s.Stack, nil
+}
 
+// NOTE: witness programを使わないinputにwitnessがあってはならない
+func checkUnexpectedWitness(witness [][]byte, ctx *EvalContext) error {
+	if ctx.Flags&SCRIPT_VERIFY_WITNESS != 0 && len(witness) != 0 {
+		return fmt.Errorf("unexpected witness")
+	}
 	return nil
 }
 
-func NewP2PKHScriptPubkey(address string) (*Script, error) {
-	hash160, err := secp256k1.ExtractHash160(address)
+func verifyWitnessProgram(version int, program []byte, witness [][]byte, isP2SH bool, ctx *EvalContext) error {
+	// NOTE: taprootはP2SHでネストできない
+	if version == 1 && len(program) == 32 && !isP2SH && ctx.Flags&SCRIPT_VERIFY_TAPROOT != 0 {
+		return verifyTaproot(program, witness, ctx)
+	}
+	if version != 0 {
+		// NOTE: 未定義のバージョンは将来のソフトフォークのために常に成功とする
+		return nil
+	}
+
+	s := NewScript()
+	switch len(program) {
+	case 32:
+		// NOTE: P2WSH witnessの最後の要素がwitness scriptで、そのSHA256がprogramと一致する
+		if len(witness) == 0 {
+			return fmt.Errorf("witness is empty")
+		}
+		rawWitnessScript := witness[len(witness)-1]
+		hash := sha256.Sum256(rawWitnessScript)
+		if !utils.CompareBytes(hash[:], program) {
+			return fmt.Errorf("witness script hash mismatch")
+		}
+		witnessScript, err := ParseRawScript(rawWitnessScript)
+		if err != nil {
+			return err
+		}
+		s.Stack = append(s.Stack, witness[:len(witness)-1]...)
+		s.Add(witnessScript)
+	case 20:
+		// NOTE: P2WPKH witnessは署名と公開鍵で、P2PKHと同じscriptで評価する
+		if len(witness) != 2 {
+			return fmt.Errorf("p2wpkh witness must have 2 elements")
+		}
+		s.Stack = append(s.Stack, witness...)
+		s.Add(NewP2PKHScriptFromHash160(program))
+	default:
+		return fmt.Errorf("invalid witness program length: %d", len(program))
+	}
+	for _, element := range s.Stack {
+		if len(element) > maxElementSize {
+			return fmt.Errorf("witness element is too long")
+		}
+	}
+	// NOTE: 呼び出し元のcontextを変更しないようにコピーする
+	v0Ctx := *ctx
+	v0Ctx.witnessV0 = true
+	// NOTE: witnessの評価では、Stackに真となる要素が1つだけ残らなければならない
+	return s.Evaluate(&v0Ctx)
+}
+
+// NOTE: legacyの署名ハッシュではscriptCodeからOP_CODESEPARATORを取り除く。parseできない末尾はそのまま残す
+func (s *Script) RemoveCodeSeparators() *Script {
+	script := NewScript()
+	for _, inst := range s.Instructions {
+		if !inst.IsPush() && inst.Op == OP_CODESEPARATOR {
+			continue
+		}
+		script.Instructions = append(script.Instructions, inst)
+	}
+	script.unparsed = s.unparsed
+	return script
+}
+
+// NOTE: legacyの署名ハッシュではscriptCodeから署名のpushを取り除く。dataを最短のpushで表したものと一致する命令のみが対象
+func (s *Script) FindAndDelete(data []byte) *Script {
+	target := NewPushInstruction(data)
+	script := NewScript()
+	for _, inst := range s.Instructions {
+		if inst.IsPush() && inst.Op == target.Op && bytes.Equal(inst.Data, target.Data) {
+			continue
+		}
+		script.Instructions = append(script.Instructions, inst)
+	}
+	script.unparsed = s.unparsed
+	return script
+}
+
+// NOTE: P2SHのScriptSigで最後にpushされたredeem scriptをparseする
+func (s *Script) RedeemScript() (*Script, error) {
+	if len(s.Instructions) == 0 {
+		return nil, fmt.Errorf("scriptSig has no redeem script")
+	}
+	last := s.Instructions[len(s.Instructions)-1]
+	if !last.IsPush() {
+		return nil, fmt.Errorf("last instruction of scriptSig is not a push")
+	}
+	return ParseRawScript(last.Data)
+}
+
+// NOTE: BIP141 1バイトのバージョン(OP_0-OP_16)と2-40バイトのprogramのpush
+func (s *Script) WitnessProgram() (int, []byte, bool) {
+	if len(s.Instructions) != 2 || len(s.unparsed) > 0 {
+		return 0, nil, false
+	}
+	op := s.Instructions[0].Op
+	push := s.Instructions[1]
+	if op != OP_0 && (op < OP_1 || op > OP_16) {
+		return 0, nil, false
+	}
+	// NOTE: programは直接のpush (0x02-0x28) でなければならない
+	if push.Op < 2 || push.Op > 40 || len(push.Data) != int(push.Op) {
+		return 0, nil, false
+	}
+	if op == OP_0 {
+		return 0, push.Data, true
+	}
+	return int(op) - OP_1 + 1, push.Data, true
+}
+
+// NOTE: OP_DUP OP_HASH160 <20 bytes> OP_EQUALVERIFY OP_CHECKSIG
+func (s *Script) IsP2PKHScriptPubkey() bool {
+	return len(s.Instructions) == 5 && len(s.unparsed) == 0 &&
+		s.Instructions[0].Op == OP_DUP &&
+		s.Instructions[1].Op == OP_HASH160 &&
+		s.Instructions[2].Op == 20 && len(s.Instructions[2].Data) == 20 &&
+		s.Instructions[3].Op == OP_EQUALVERIFY &&
+		s.Instructions[4].Op == OP_CHECKSIG
+}
+
+// NOTE: OP_HASH160 <20 bytes> OP_EQUAL
+func (s *Script) IsP2SHScriptPubkey() bool {
+	return len(s.Instructions) == 3 && len(s.unparsed) == 0 &&
+		s.Instructions[0].Op == OP_HASH160 &&
+		s.Instructions[1].Op == 20 && len(s.Instructions[1].Data) == 20 &&
+		s.Instructions[2].Op == OP_EQUAL
+}
+
+// NOTE: OP_0やOP_1-OP_16、OP_1NEGATEも数値のpushとして扱う
+func (s *Script) IsPushOnly() bool {
+	if len(s.unparsed) > 0 {
+		return false
+	}
+	for _, inst := range s.Instructions {
+		if inst.Op > OP_16 {
+			return false
+		}
+	}
+	return true
+}
+
+// NOTE: paramsのP2PKHアドレスでなければエラーにする。種類を問わない場合は address.Decode を使う
+func NewP2PKHScriptPubkey(address string, params *chaincfg.Params) (*Script, error) {
+	version, hash160, err := secp256k1.DecodeBase58Address(address)
 	if err != nil {
 		return nil, err
 	}
+	if version != params.PubKeyHashAddrID {
+		return nil, fmt.Errorf("address is not %s p2pkh: %s", params.Name, address)
+	}
+	return NewP2PKHScriptFromHash160(hash160), nil
+}
+
+func NewP2PKHScriptFromHash160(hash160 []byte) *Script {
 	script := NewScript()
-	script.Instructions = append(script.Instructions, []byte{OP_DUP})
-	script.Instructions = append(script.Instructions, []byte{OP_HASH160})
-	script.Instructions = append(script.Instructions, hash160)
-	script.Instructions = append(script.Instructions, []byte{OP_EQUALVERIFY})
-	script.Instructions = append(script.Instructions, []byte{OP_CHECKSIG})
-	return script, nil
+	script.Instructions = append(script.Instructions, NewOpInstruction(OP_DUP))
+	script.Instructions = append(script.Instructions, NewOpInstruction(OP_HASH160))
+	script.Instructions = append(script.Instructions, NewPushInstruction(hash160))
+	script.Instructions = append(script.Instructions, NewOpInstruction(OP_EQUALVERIFY))
+	script.Instructions = append(script.Instructions, NewOpInstruction(OP_CHECKSIG))
+	return script
+}
+
+// NOTE: paramsのP2SHアドレスでなければエラーにする。種類を問わない場合は address.Decode を使う
+func NewP2SHScriptPubkey(address string, params *chaincfg.Params) (*Script, error) {
+	version, hash160, err := secp256k1.DecodeBase58Address(address)
+	if err != nil {
+		return nil, err
+	}
+	if version != params.ScriptHashAddrID {
+		return nil, fmt.Errorf("address is not %s p2sh: %s", params.Name, address)
+	}
+	return NewP2SHScriptFromHash160(hash160), nil
+}
+
+func NewP2SHScriptFromHash160(hash160 []byte) *Script {
+	script := NewScript()
+	script.Instructions = append(script.Instructions, NewOpInstruction(OP_HASH160))
+	script.Instructions = append(script.Instructions, NewPushInstruction(hash160))
+	script.Instructions = append(script.Instructions, NewOpInstruction(OP_EQUAL))
+	return script
+}
+
+// NOTE: bech32/bech32mのアドレスからwitness programのscriptPubKeyを作る。HRPでネットワークを確かめる
+func NewSegwitScriptPubkey(address string, params *chaincfg.Params) (*Script, error) {
+	version, program, err := bech32.DecodeSegwitAddress(params.Bech32HRP, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewWitnessScriptPubkey(version, program), nil
+}
+
+// NOTE: <OP_0 または OP_1-OP_16> <program>
+func NewWitnessScriptPubkey(version int, program []byte) *Script {
+	op := byte(OP_0)
+	if version > 0 {
+		op = byte(OP_1 + version - 1)
+	}
+	script := NewScript()
+	script.Instructions = append(script.Instructions, NewOpInstruction(op))
+	script.Instructions = append(script.Instructions, NewPushInstruction(program))
+	return script
+}
+
+// NOTE: OP_0 <20-byte hash>
+func NewP2WPKHScriptFromHash160(hash160 []byte) *Script {
+	return NewWitnessScriptPubkey(0, hash160)
+}
+
+// NOTE: OP_1 <32-byte x-only output key>
+func NewP2TRScriptFromOutputKey(outputKey []byte) *Script {
+	return NewWitnessScriptPubkey(1, outputKey)
 }
 
-func NewScriptSig(serializedSignature, serializedPubkey []byte) *Script {
+func NewScriptSig(serializedSignature, serializedPubkey []byte, hashType uint32) *Script {
 	script := NewScript()
-	serializedSignature = append(serializedSignature, byte(1))
-	script.Instructions = append(script.Instructions, serializedSignature)
-	script.Instructions = append(script.Instructions, serializedPubkey)
+	serializedSignature = append(serializedSignature, byte(hashType))
+	script.Instructions = append(script.Instructions, NewPushInstruction(serializedSignature))
+	script.Instructions = append(script.Instructions, NewPushInstruction(serializedPubkey))
 	return script
 }
@@ -0,0 +1,551 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang-bitcoin/pkg/chaincfg"
+	"golang-bitcoin/pkg/utils"
+	"strings"
+	"testing"
+)
+
+func TestVerifyScript(t *testing.T) {
+	// NOTE: redeem scriptは OP_2 OP_EQUAL
+	redeemScript := data("5287")
+	p2shScriptPubkey := NewP2SHScriptFromHash160(utils.Hash160(redeemScript))
+	// NOTE: 520バイトのpushとOP_DROPを繰り返し、最後にOP_1を残すちょうどsizeバイトのscript
+	sizedScript := func(size int) *Script {
+		s := NewScript()
+		for i := 0; i < 19; i++ {
+			s.Instructions = append(s.Instructions, push(make([]byte, maxElementSize)), op(OP_DROP))
+		}
+		s.Instructions = append(s.Instructions, push(make([]byte, size-19*524-3)), op(OP_DROP), op(OP_1))
+		return s
+	}
+	// NOTE: witness scriptは OP_2 OP_EQUAL
+	witnessScript := data("5287")
+	witnessScriptHash := sha256.Sum256(witnessScript)
+	oversizedWitnessScript, _ := sizedScript(maxScriptSize + 1).Serialize()
+	oversizedWitnessScriptHash := sha256.Sum256(oversizedWitnessScript)
+
+	tests := []struct {
+		name         string
+		scriptSig    []Instruction
+		scriptPubkey *Script
+		witness      [][]byte
+		flags        VerifyFlags
+		wantErr      bool
+	}{
+		{
+			name:         "redeem script succeeds",
+			scriptSig:    []Instruction{op(OP_2), push(redeemScript)},
+			scriptPubkey: p2shScriptPubkey,
+			flags:        SCRIPT_VERIFY_P2SH,
+			wantErr:      false,
+		},
+		{
+			name:         "redeem script fails",
+			scriptSig:    []Instruction{op(OP_3), push(redeemScript)},
+			scriptPubkey: p2shScriptPubkey,
+			flags:        SCRIPT_VERIFY_P2SH,
+			wantErr:      true,
+		},
+		{
+			// NOTE: ハッシュの一致のみを確認する。OP_3が残っていても一番上の要素が真なら有効
+			name:         "redeem script is not evaluated without p2sh flag",
+			scriptSig:    []Instruction{op(OP_3), push(redeemScript)},
+			scriptPubkey: p2shScriptPubkey,
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      false,
+		},
+		{
+			name:         "redeem script hash mismatch",
+			scriptSig:    []Instruction{op(OP_2), push(data("5387"))},
+			scriptPubkey: p2shScriptPubkey,
+			flags:        SCRIPT_VERIFY_P2SH,
+			wantErr:      true,
+		},
+		{
+			name:         "scriptSig is not push only",
+			scriptSig:    []Instruction{op(OP_1), op(OP_1ADD), push(redeemScript)},
+			scriptPubkey: p2shScriptPubkey,
+			flags:        SCRIPT_VERIFY_P2SH,
+			wantErr:      true,
+		},
+		{
+			name:         "empty scriptSig",
+			scriptSig:    []Instruction{},
+			scriptPubkey: p2shScriptPubkey,
+			flags:        SCRIPT_VERIFY_P2SH,
+			wantErr:      true,
+		},
+		{
+			name:         "non p2sh scriptPubkey",
+			scriptSig:    []Instruction{op(OP_2)},
+			scriptPubkey: &Script{Instructions: []Instruction{op(OP_2), op(OP_EQUAL)}},
+			flags:        SCRIPT_VERIFY_P2SH,
+			wantErr:      false,
+		},
+		{
+			name:         "if opened in scriptSig is not closed by scriptPubkey",
+			scriptSig:    []Instruction{op(OP_0), op(OP_IF)},
+			scriptPubkey: &Script{Instructions: []Instruction{op(OP_ENDIF), op(OP_1)}},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      true,
+		},
+		{
+			name:         "alt stack is not carried over to scriptPubkey",
+			scriptSig:    []Instruction{op(OP_1), op(OP_TOALTSTACK)},
+			scriptPubkey: &Script{Instructions: []Instruction{op(OP_FROMALTSTACK)}},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      true,
+		},
+		{
+			name:         "non push only scriptSig without sigpushonly",
+			scriptSig:    []Instruction{op(OP_1), op(OP_1ADD)},
+			scriptPubkey: &Script{Instructions: []Instruction{op(OP_2), op(OP_EQUAL)}},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      false,
+		},
+		{
+			name:         "non push only scriptSig with sigpushonly",
+			scriptSig:    []Instruction{op(OP_1), op(OP_1ADD)},
+			scriptPubkey: &Script{Instructions: []Instruction{op(OP_2), op(OP_EQUAL)}},
+			flags:        SCRIPT_VERIFY_SIGPUSHONLY,
+			wantErr:      true,
+		},
+		{
+			name:         "extra stack elements after scriptPubkey",
+			scriptSig:    []Instruction{op(OP_1), op(OP_2)},
+			scriptPubkey: &Script{Instructions: []Instruction{op(OP_2), op(OP_EQUAL)}},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      false,
+		},
+		{
+			name:         "alt stack left after scriptPubkey",
+			scriptSig:    []Instruction{},
+			scriptPubkey: &Script{Instructions: []Instruction{op(OP_1), op(OP_TOALTSTACK), op(OP_1)}},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      false,
+		},
+		{
+			name:         "extra stack elements below false top element",
+			scriptSig:    []Instruction{op(OP_1), op(OP_3)},
+			scriptPubkey: &Script{Instructions: []Instruction{op(OP_2), op(OP_EQUAL)}},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      true,
+		},
+		{
+			name:         "extra stack elements after redeem script",
+			scriptSig:    []Instruction{op(OP_1), op(OP_2), push(redeemScript)},
+			scriptPubkey: p2shScriptPubkey,
+			flags:        SCRIPT_VERIFY_P2SH,
+			wantErr:      false,
+		},
+		{
+			name:         "extra stack elements after witness script",
+			scriptSig:    []Instruction{},
+			scriptPubkey: NewWitnessScriptPubkey(0, witnessScriptHash[:]),
+			witness:      [][]byte{{0x01}, {0x02}, witnessScript},
+			flags:        SCRIPT_VERIFY_P2SH | SCRIPT_VERIFY_WITNESS,
+			wantErr:      true,
+		},
+		{
+			name:         "witness script leaves single element",
+			scriptSig:    []Instruction{},
+			scriptPubkey: NewWitnessScriptPubkey(0, witnessScriptHash[:]),
+			witness:      [][]byte{{0x02}, witnessScript},
+			flags:        SCRIPT_VERIFY_P2SH | SCRIPT_VERIFY_WITNESS,
+			wantErr:      false,
+		},
+		{
+			name:         "scriptPubkey at max script size",
+			scriptSig:    []Instruction{},
+			scriptPubkey: sizedScript(maxScriptSize),
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      false,
+		},
+		{
+			name:         "scriptPubkey over max script size",
+			scriptSig:    []Instruction{},
+			scriptPubkey: sizedScript(maxScriptSize + 1),
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      true,
+		},
+		{
+			name:         "scriptSig over max script size",
+			scriptSig:    sizedScript(maxScriptSize + 1).Instructions,
+			scriptPubkey: &Script{Instructions: []Instruction{}},
+			flags:        SCRIPT_VERIFY_NONE,
+			wantErr:      true,
+		},
+		{
+			name:         "witness script over max script size",
+			scriptSig:    []Instruction{},
+			scriptPubkey: NewWitnessScriptPubkey(0, oversizedWitnessScriptHash[:]),
+			witness:      [][]byte{oversizedWitnessScript},
+			flags:        SCRIPT_VERIFY_P2SH | SCRIPT_VERIFY_WITNESS,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scriptSig := NewScript()
+			scriptSig.Instructions = tt.scriptSig
+			if err := VerifyScript(scriptSig, tt.scriptPubkey, tt.witness, &EvalContext{Flags: tt.flags}); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// NOTE: pushのopcodeを保持するので、1バイトのpushや最短でないpushもparseしたとおりにシリアライズされる
+func TestParseRawScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		wantPush []byte
+		wantErr  bool
+	}{
+		{name: "push 0x64", script: "0164", wantPush: data("64")},
+		{name: "push 0x00", script: "0100", wantPush: data("00")},
+		{name: "push 0x51", script: "0151", wantPush: data("51")},
+		{name: "push 0xff", script: "01ff", wantPush: data("ff")},
+		{name: "non-minimal pushdata1", script: "4c0101", wantPush: data("01")},
+		{name: "non-minimal pushdata2", script: "4d0100ab", wantPush: data("ab")},
+		{name: "non-minimal pushdata4", script: "4e01000000ab", wantPush: data("ab")},
+		{name: "op_0", script: "00", wantPush: []byte{}},
+		{name: "p2pkh", script: "76a91499a4c61750789253f69fd750ac0d02126337330588ac"},
+		{name: "element longer than 520 bytes", script: "4d0902" + strings.Repeat("ab", 521)},
+		{name: "truncated push", script: "02ff", wantErr: true},
+		{name: "truncated pushdata1 length", script: "4c", wantErr: true},
+		{name: "truncated pushdata2 length", script: "4d01", wantErr: true},
+		{name: "pushdata4 length exceeds script", script: "4effffffff00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := data(tt.script)
+			got, err := ParseRawScript(raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRawScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantPush != nil {
+				if len(got.Instructions) != 1 || !got.Instructions[0].IsPush() || !bytes.Equal(got.Instructions[0].Data, tt.wantPush) {
+					t.Errorf("ParseRawScript() = %v, want push of %x", got.Instructions, tt.wantPush)
+				}
+			}
+			serialized, err := got.Serialize()
+			if err != nil {
+				t.Fatalf("Script.Serialize() error = %v", err)
+			}
+			if !bytes.Equal(serialized, raw) {
+				t.Errorf("Script.Serialize() = %x, want %x", serialized, raw)
+			}
+		})
+	}
+}
+
+func TestScript_Serialize(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		want         string
+		wantErr      bool
+	}{
+		{name: "minimal push", instructions: []Instruction{push(data("64")), op(OP_CHECKSEQUENCEVERIFY)}, want: "0164b2"},
+		{name: "empty push", instructions: []Instruction{push([]byte{})}, want: "00"},
+		{name: "pushdata1", instructions: []Instruction{push(bytes.Repeat([]byte{0xab}, 76))}, want: "4c4c" + strings.Repeat("ab", 76)},
+		{name: "push length mismatch", instructions: []Instruction{{Op: 0x02, Data: data("ab")}}, wantErr: true},
+		{name: "pushdata1 too long", instructions: []Instruction{{Op: OP_PUSHDATA1, Data: make([]byte, 0x100)}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScript()
+			s.Instructions = tt.instructions
+			got, err := s.Serialize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Script.Serialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && hex.EncodeToString(got) != tt.want {
+				t.Errorf("Script.Serialize() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	// NOTE: 長さのprefixが入力より長い場合は、その長さを確保する前にエラーになる
+	for _, raw := range []string{"ffffffffffffffff7f00", "feffffff0000"} {
+		if _, err := ParseScript(bytes.NewReader(data(raw))); err == nil {
+			t.Errorf("ParseScript(%v) error = nil, want error", raw)
+		}
+	}
+	got, err := ParseScript(bytes.NewReader(data("030164b2")))
+	if err != nil {
+		t.Fatalf("ParseScript() error = %v", err)
+	}
+	if len(got.Instructions) != 2 || got.Instructions[1].Op != OP_CHECKSEQUENCEVERIFY {
+		t.Errorf("ParseScript() = %v, want <0x64> OP_CHECKSEQUENCEVERIFY", got.Instructions)
+	}
+
+	// NOTE: 末尾のpushが途中で切れていても、トランザクションのscriptとしては受け入れて元のバイト列を保持する
+	truncated := "ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b"
+	got, err = ParseScript(bytes.NewReader(data("20" + truncated)))
+	if err != nil {
+		t.Fatalf("ParseScript() error = %v", err)
+	}
+	serialized, err := got.Serialize()
+	if err != nil {
+		t.Fatalf("Script.Serialize() error = %v", err)
+	}
+	if hex.EncodeToString(serialized) != truncated {
+		t.Errorf("Script.Serialize() = %x, want %v", serialized, truncated)
+	}
+	if got.IsPushOnly() {
+		t.Errorf("Script.IsPushOnly() = true, want false")
+	}
+	if err := got.Evaluate(&EvalContext{}); err == nil {
+		t.Errorf("Script.Evaluate() error = nil, want error")
+	}
+	if _, err := ParseRawScript(data(truncated)); err == nil {
+		t.Errorf("ParseRawScript() error = nil, want error")
+	}
+}
+
+func TestNewP2PKHScriptPubkey(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		params  *chaincfg.Params
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "testnet p2pkh",
+			address: "mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm",
+			params:  &chaincfg.TestNet3Params,
+			want:    "76a9147c78d7b2146fbd9200fcbc12e72a528c08b563e588ac",
+		},
+		{
+			name:    "signet p2pkh",
+			address: "mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm",
+			params:  &chaincfg.SigNetParams,
+			want:    "76a9147c78d7b2146fbd9200fcbc12e72a528c08b563e588ac",
+		},
+		{
+			name:    "testnet p2pkh on mainnet",
+			address: "mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "p2sh",
+			address: "3CLoMMyuoDQTPRD3XYZtCvgvkadrAdvdXh",
+			params:  &chaincfg.MainNetParams,
+			wantErr: true,
+		},
+		{
+			name:    "invalid checksum",
+			address: "mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dn",
+			params:  &chaincfg.TestNet3Params,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewP2PKHScriptPubkey(tt.address, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewP2PKHScriptPubkey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			serialized, _ := got.Serialize()
+			if hex.EncodeToString(serialized) != tt.want {
+				t.Errorf("NewP2PKHScriptPubkey() = %x, want %v", serialized, tt.want)
+			}
+			if !got.IsP2PKHScriptPubkey() {
+				t.Errorf("Script.IsP2PKHScriptPubkey() = false, want true")
+			}
+		})
+	}
+}
+
+func TestNewP2SHScriptPubkey(t *testing.T) {
+	got, err := NewP2SHScriptPubkey("3CLoMMyuoDQTPRD3XYZtCvgvkadrAdvdXh", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewP2SHScriptPubkey() error = %v", err)
+	}
+	serialized, _ := got.Serialize()
+	if want := "a91474d691da1574e6b3c192ecfb52cc8984ee7b6c5687"; hex.EncodeToString(serialized) != want {
+		t.Errorf("NewP2SHScriptPubkey() = %x, want %v", serialized, want)
+	}
+	if !got.IsP2SHScriptPubkey() {
+		t.Errorf("Script.IsP2SHScriptPubkey() = false, want true")
+	}
+	if _, err := NewP2SHScriptPubkey("mrs6r8TKaYZkXxrCw9kDg1C4XatTsss5Dm", &chaincfg.TestNet3Params); err == nil {
+		t.Errorf("NewP2SHScriptPubkey() with p2pkh address error = nil, want error")
+	}
+	if _, err := NewP2SHScriptPubkey("3CLoMMyuoDQTPRD3XYZtCvgvkadrAdvdXh", &chaincfg.TestNet3Params); err == nil {
+		t.Errorf("NewP2SHScriptPubkey() with mainnet address on testnet error = nil, want error")
+	}
+}
+
+func TestNewSegwitScriptPubkey(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		params  *chaincfg.Params
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "p2wpkh",
+			address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+			params:  &chaincfg.MainNetParams,
+			want:    "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+		},
+		{
+			name:    "p2tr",
+			address: "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c",
+			params:  &chaincfg.TestNet3Params,
+			want:    "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433",
+		},
+		{
+			name:    "wrong network",
+			address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+			params:  &chaincfg.TestNet3Params,
+			wantErr: true,
+		},
+		{
+			name:    "regtest p2wpkh",
+			address: "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080",
+			params:  &chaincfg.RegressionNetParams,
+			want:    "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSegwitScriptPubkey(tt.address, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSegwitScriptPubkey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			serialized, _ := got.Serialize()
+			if hex.EncodeToString(serialized) != tt.want {
+				t.Errorf("NewSegwitScriptPubkey() = %x, want %v", serialized, tt.want)
+			}
+		})
+	}
+}
+
+func TestScript_RemoveCodeSeparators(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{name: "code separators", script: "ab76ab01abac", want: "7601abac"},
+		{name: "code separator byte inside push", script: "02abab87", want: "02abab87"},
+		{name: "unparsable tail is kept", script: "ab5102ab", want: "5102ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := data(tt.script)
+			s, err := ParseScript(bytes.NewReader(append([]byte{byte(len(raw))}, raw...)))
+			if err != nil {
+				t.Fatalf("ParseScript() error = %v", err)
+			}
+			got, _ := s.RemoveCodeSeparators().Serialize()
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("Script.RemoveCodeSeparators() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScript_FindAndDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		data   string
+		want   string
+	}{
+		{name: "push of data", script: "02abcd7502abcdac", data: "abcd", want: "75ac"},
+		{name: "non-minimal push is kept", script: "4c02abcd7502abcdac", data: "abcd", want: "4c02abcd75ac"},
+		{name: "different data is kept", script: "02abce75ac", data: "abcd", want: "02abce75ac"},
+		{name: "data inside longer push is kept", script: "03abcdef75ac", data: "abcd", want: "03abcdef75ac"},
+		{name: "empty data removes OP_0", script: "00510087", data: "", want: "5187"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseRawScript(data(tt.script))
+			if err != nil {
+				t.Fatalf("ParseRawScript() error = %v", err)
+			}
+			got, _ := s.FindAndDelete(data(tt.data)).Serialize()
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("Script.FindAndDelete() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScript_WitnessProgram(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		wantVersion  int
+		wantProgram  string
+		wantOk       bool
+	}{
+		{
+			name:         "p2wpkh",
+			instructions: []Instruction{op(OP_0), push(data("1d0f172a0ecb48aee1be1f2687d2963ae33f71a1"))},
+			wantVersion:  0,
+			wantProgram:  "1d0f172a0ecb48aee1be1f2687d2963ae33f71a1",
+			wantOk:       true,
+		},
+		{
+			name:         "version 1",
+			instructions: []Instruction{op(OP_1), push(data("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"))},
+			wantVersion:  1,
+			wantProgram:  "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+			wantOk:       true,
+		},
+		{
+			name:         "program too long",
+			instructions: []Instruction{op(OP_0), push(make([]byte, 41))},
+			wantOk:       false,
+		},
+		{
+			name:         "not a version opcode",
+			instructions: []Instruction{op(OP_1NEGATE), push(data("1d0f172a0ecb48aee1be1f2687d2963ae33f71a1"))},
+			wantOk:       false,
+		},
+		{
+			name:         "extra instruction",
+			instructions: []Instruction{op(OP_0), push(data("1d0f172a0ecb48aee1be1f2687d2963ae33f71a1")), op(OP_DROP)},
+			wantOk:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScript()
+			s.Instructions = tt.instructions
+			version, program, ok := s.WitnessProgram()
+			if ok != tt.wantOk {
+				t.Fatalf("Script.WitnessProgram() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && (version != tt.wantVersion || hex.EncodeToString(program) != tt.wantProgram) {
+				t.Errorf("Script.WitnessProgram() = %v, %x, want %v, %v", version, program, tt.wantVersion, tt.wantProgram)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"golang-bitcoin/pkg/secp256k1"
+	"golang-bitcoin/pkg/signature"
+	"golang-bitcoin/pkg/utils"
+)
+
+const (
+	TAPROOT_LEAF_TAPSCRIPT = 0xc0
+	TAPROOT_LEAF_MASK      = 0xfe
+	TAPROOT_ANNEX_TAG      = 0x50
+
+	taprootControlBaseSize = 33
+	taprootControlNodeSize = 32
+	taprootControlMaxNodes = 128
+
+	sigOpsBudgetBase   = 50
+	sigOpsBudgetPerSig = 50
+)
+
+// NOTE: BIP341 witness version 1のprogramを検証する
+func verifyTaproot(program []byte, witness [][]byte, ctx *EvalContext) error {
+	if len(witness) == 0 {
+		return fmt.Errorf("witness is empty")
+	}
+	budget := sigOpsBudgetBase + witnessSize(witness)
+	// NOTE: 2つ以上の要素があり、最後の要素が0x50で始まる場合はannex。annexは署名ハッシュでのみ使う
+	if len(witness) >= 2 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == TAPROOT_ANNEX_TAG {
+		witness = witness[:len(witness)-1]
+	}
+
+	if len(witness) == 1 {
+		// NOTE: key path spend witnessは署名のみ
+		return verifyTaprootSig(ctx, witness[0], program, nil)
+	}
+
+	// NOTE: script path spend witnessは ... <script> <control block>
+	control := witness[len(witness)-1]
+	rawScript := witness[len(witness)-2]
+	stack := witness[:len(witness)-2]
+	if len(control) < taprootControlBaseSize || len(control) > taprootControlBaseSize+taprootControlNodeSize*taprootControlMaxNodes || (len(control)-taprootControlBaseSize)%taprootControlNodeSize != 0 {
+		return fmt.Errorf("invalid control block size: %d", len(control))
+	}
+	leafVersion := control[0] & TAPROOT_LEAF_MASK
+	leafHash := TapLeafHash(leafVersion, rawScript)
+	if err := verifyTaprootCommitment(control, program, leafHash); err != nil {
+		return err
+	}
+	if leafVersion != TAPROOT_LEAF_TAPSCRIPT {
+		// NOTE: 未定義のleaf versionは将来のソフトフォークのために常に成功とする
+		return nil
+	}
+
+	// NOTE: BIP342 OP_SUCCESSxを含むscriptは実行せずに成功とする
+	hasSuccess, err := containsOpSuccess(rawScript)
+	if err != nil {
+		return err
+	}
+	if hasSuccess {
+		return nil
+	}
+	tapscript, err := ParseRawScript(rawScript)
+	if err != nil {
+		return err
+	}
+	if len(stack) > maxStackSize {
+		return fmt.Errorf("stack size limit exceeded")
+	}
+	for _, element := range stack {
+		if len(element) > maxElementSize {
+			return fmt.Errorf("witness element is too long")
+		}
+	}
+
+	// NOTE: 呼び出し元のcontextを変更しないようにコピーする
+	tapCtx := *ctx
+	tapCtx.tapscript = true
+	tapCtx.leafHash = leafHash
+	tapCtx.codeSepPos = 0xffffffff
+	tapCtx.sigOpsBudget = budget
+	s := NewScript()
+	s.Stack = append(s.Stack, stack...)
+	s.Add(tapscript)
+	return s.Evaluate(&tapCtx)
+}
+
+// NOTE: control blockの内部鍵とmerkle pathから計算した出力鍵がprogramと一致することを確認する
+func verifyTaprootCommitment(control, program, leafHash []byte) error {
+	internalKey, err := secp256k1.ParseXOnlyPubKey(control[1:taprootControlBaseSize])
+	if err != nil {
+		return err
+	}
+	node := leafHash
+	for i := taprootControlBaseSize; i < len(control); i += taprootControlNodeSize {
+		node = TapBranchHash(node, control[i:i+taprootControlNodeSize])
+	}
+	outputKey, err := internalKey.TaprootTweak(node)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(outputKey.SerializeXOnly(), program) {
+		return fmt.Errorf("taproot commitment mismatch")
+	}
+	if outputKey.Y().Bit(0) != uint(control[0]&1) {
+		return fmt.Errorf("taproot output key parity mismatch")
+	}
+	return nil
+}
+
+func TapLeafHash(leafVersion byte, rawScript []byte) []byte {
+	length, _ := utils.SerializeVarInt(uint64(len(rawScript)))
+	return utils.TaggedHash("TapLeaf", []byte{leafVersion}, length, rawScript)
+}
+
+// NOTE: 子ノードは辞書順に並べてハッシュする
+func TapBranchHash(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return utils.TaggedHash("TapBranch", a, b)
+}
+
+// NOTE: 署名の末尾にハッシュタイプがない64バイトの署名はSIGHASH_DEFAULT(0x00)
+func verifyTaprootSig(ctx *EvalContext, sigWithHashType, xOnlyPubkey, leafHash []byte) error {
+	serializedSig := sigWithHashType
+	hashType := uint32(0)
+	switch len(sigWithHashType) {
+	case 64:
+	case 65:
+		hashType = uint32(sigWithHashType[64])
+		if hashType == 0 {
+			return fmt.Errorf("invalid schnorr signature hash type")
+		}
+		serializedSig = sigWithHashType[:64]
+	default:
+		return fmt.Errorf("invalid schnorr signature size: %d", len(sigWithHashType))
+	}
+	sig, err := signature.ParseSchnorrSignature(serializedSig)
+	if err != nil {
+		return err
+	}
+	pubkey, err := secp256k1.ParseXOnlyPubKey(xOnlyPubkey)
+	if err != nil {
+		return err
+	}
+	msg, err := ctx.TaprootSigHash(hashType, leafHash, ctx.codeSepPos)
+	if err != nil {
+		return err
+	}
+	if !pubkey.VerifySchnorr(msg, *sig) {
+		return fmt.Errorf("invalid schnorr signature")
+	}
+	return nil
+}
+
+// NOTE: BIP342 OP_CHECKSIG, OP_CHECKSIGVERIFY, OP_CHECKSIGADDの署名検証
+func checkSchnorrSig(ctx *EvalContext, sig, pubkey []byte) (bool, error) {
+	if len(sig) != 0 {
+		ctx.sigOpsBudget -= sigOpsBudgetPerSig
+		if ctx.sigOpsBudget < 0 {
+			return false, fmt.Errorf("sigops budget exceeded")
+		}
+	}
+	if len(pubkey) == 0 {
+		return false, fmt.Errorf("public key is empty")
+	}
+	if len(pubkey) != 32 {
+		// NOTE: 未定義の公開鍵の種類は将来のソフトフォークのために成功とする
+		return len(sig) != 0, nil
+	}
+	// NOTE: 空の署名は失敗として扱うが、空でない無効な署名はエラーとする
+	if len(sig) == 0 {
+		return false, nil
+	}
+	if err := verifyTaprootSig(ctx, sig, pubkey, ctx.leafHash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NOTE: BIP342 OP_SUCCESSx
+func isOpSuccess(op byte) bool {
+	return op == 80 || op == 98 || (op >= 126 && op <= 129) ||
+		(op >= 131 && op <= 134) || (op >= 137 && op <= 138) ||
+		(op >= 141 && op <= 142) || (op >= 149 && op <= 153) ||
+		(op >= 187 && op <= 254)
+}
+
+// NOTE: BIP342 先頭から命令を読み、parseに失敗する位置より前にOP_SUCCESSxがあれば成功とする
+func containsOpSuccess(rawScript []byte) (bool, error) {
+	for pos := 0; pos < len(rawScript); {
+		inst, next, err := readInstruction(rawScript, pos)
+		if err != nil {
+			return false, err
+		}
+		if !inst.IsPush() && isOpSuccess(inst.Op) {
+			return true, nil
+		}
+		pos = next
+	}
+	return false, nil
+}
+
+// NOTE: witnessのシリアライズのサイズ。署名検証の予算の計算に使う
+func witnessSize(witness [][]byte) int {
+	count, _ := utils.SerializeVarInt(uint64(len(witness)))
+	size := len(count)
+	for _, element := range witness {
+		length, _ := utils.SerializeVarInt(uint64(len(element)))
+		size += len(length) + len(element)
+	}
+	return size
+}
@@ -8,7 +8,7 @@ func encodeNum(num int64) []byte {
 		return []byte{}
 	}
 
-	absNum := big.NewInt(num)
+	absNum := new(big.Int).Abs(big.NewInt(num))
 	negative := num < 0
 	result := []byte{}
 
@@ -56,3 +56,16 @@ func decodeNum(element []byte) int64 {
 	}
 	return result.Int64()
 }
+
+// NOTE: 全てのバイトが0の場合と、負の0(最上位バイトが0x80)の場合のみfalse
+func castToBool(element []byte) bool {
+	for i, b := range element {
+		if b != 0 {
+			if i == len(element)-1 && b == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package secp256k1
+
+import (
+	"crypto/rand"
+	"golang-bitcoin/pkg/signature"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// NOTE: ブロック検証のように大量の署名をまとめて検証する
+// NOTE: Schnorr署名はランダムな係数の線形結合で一度に検証し、ECDSA署名はワーカーで並列に検証する
+type BatchVerifier struct {
+	workers int
+	entries []batchEntry
+}
+
+type batchEntry struct {
+	pubkey Secp256k1Point
+	// NOTE: ECDSA
+	z         *big.Int
+	signature *signature.Signature
+	// NOTE: Schnorr
+	msg              []byte
+	schnorrSignature *signature.SchnorrSignature
+}
+
+// NOTE: workersが0以下ならCPU数だけワーカーを使う
+func NewBatchVerifier(workers int) *BatchVerifier {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &BatchVerifier{workers: workers}
+}
+
+func (b *BatchVerifier) AddECDSA(pubkey Secp256k1Point, z *big.Int, sig signature.Signature) {
+	b.entries = append(b.entries, batchEntry{pubkey: pubkey, z: z, signature: &sig})
+}
+
+func (b *BatchVerifier) AddSchnorr(pubkey Secp256k1Point, msg []byte, sig signature.SchnorrSignature) {
+	b.entries = append(b.entries, batchEntry{pubkey: pubkey, msg: msg, schnorrSignature: &sig})
+}
+
+func (b *BatchVerifier) Len() int {
+	return len(b.entries)
+}
+
+// NOTE: 全ての署名が正しければtrueを返す。falseの場合は失敗した署名の追加順のインデックスも返す
+func (b *BatchVerifier) Verify() (bool, []int) {
+	results := make([]bool, len(b.entries))
+	var ecdsaIndices, schnorrIndices []int
+	for i, entry := range b.entries {
+		if entry.signature != nil {
+			ecdsaIndices = append(ecdsaIndices, i)
+		} else {
+			schnorrIndices = append(schnorrIndices, i)
+		}
+	}
+
+	verifyParallel(ecdsaIndices, b.workers, results, func(i int) bool {
+		entry := b.entries[i]
+		return entry.pubkey.Verify(entry.z, *entry.signature)
+	})
+
+	if len(schnorrIndices) > 0 && b.verifySchnorrBatch(schnorrIndices) {
+		for _, i := range schnorrIndices {
+			results[i] = true
+		}
+	} else {
+		// NOTE: まとめた検証に失敗した場合はどれが不正か分からないので1つずつ検証し直す
+		verifyParallel(schnorrIndices, b.workers, results, func(i int) bool {
+			entry := b.entries[i]
+			return entry.pubkey.VerifySchnorr(entry.msg, *entry.schnorrSignature)
+		})
+	}
+
+	var failed []int
+	for i, ok := range results {
+		if !ok {
+			failed = append(failed, i)
+		}
+	}
+	return len(failed) == 0, failed
+}
+
+// NOTE: BIP340 のバッチ検証
+// NOTE: (a_1*s_1 + ... + a_u*s_u)G = a_1*R_1 + ... + a_u*R_u + a_1*e_1*P_1 + ... + a_u*e_u*P_u
+// NOTE: a_1 = 1 とし、残りの係数は128bitの乱数にする
+func (b *BatchVerifier) verifySchnorrBatch(indices []int) bool {
+	s256n := NewSecp256k1n()
+	gScalar := new(big.Int)
+	scalars := make([]*big.Int, 0, 2*len(indices))
+	points := make([]jacobianPoint, 0, 2*len(indices))
+	for n, i := range indices {
+		entry := b.entries[i]
+		pubkey, e, ok := schnorrChallenge(entry.pubkey, entry.msg, *entry.schnorrSignature)
+		if !ok {
+			return false
+		}
+		R, err := ParseXOnlyPubKey(entry.schnorrSignature.Serialize()[:32])
+		if err != nil {
+			return false
+		}
+
+		a := big.NewInt(1)
+		if n > 0 {
+			a = randomBatchCoefficient()
+		}
+
+		// NOTE: 左辺を右辺に移項して、和が無限遠点になることを確かめる
+		gScalar.Add(gScalar, new(big.Int).Mul(a, entry.schnorrSignature.S()))
+		jR := jacobianFromPoint(R)
+		jP := jacobianFromPoint(pubkey)
+		points = append(points, jR.neg(), jP.neg())
+		ae := new(big.Int).Mul(a, e)
+		scalars = append(scalars, a, ae.Mod(ae, s256n))
+	}
+	gScalar.Mod(gScalar, s256n)
+	return multiScalarMult(gScalar, scalars, points).inf
+}
+
+func randomBatchCoefficient() *big.Int {
+	coefficientMax := new(big.Int).Lsh(big.NewInt(1), 128)
+	for {
+		a, err := rand.Int(rand.Reader, coefficientMax)
+		if err != nil {
+			panic(err)
+		}
+		if a.Sign() != 0 {
+			return a
+		}
+	}
+}
+
+// NOTE: indicesの各要素をworkers個のゴルーチンで検証し、結果をresultsの同じ位置に書き込む
+func verifyParallel(indices []int, workers int, results []bool, verify func(i int) bool) {
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(indices)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				results[i] = verify(i)
+			}
+		}()
+	}
+	for _, i := range indices {
+		ch <- i
+	}
+	close(ch)
+	wg.Wait()
+}
@@ -0,0 +1,200 @@
+package secp256k1
+
+import (
+	"encoding/hex"
+	"golang-bitcoin/pkg/signature"
+	"math/big"
+	"testing"
+)
+
+type batchTestEntry struct {
+	schnorr bool
+	pubkey  string
+	msg     string
+	sig     string
+}
+
+// NOTE: BIP340 test-vectors.csv の正しい署名
+var batchSchnorrVectors = []batchTestEntry{
+	{
+		schnorr: true,
+		pubkey:  "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+		msg:     "0000000000000000000000000000000000000000000000000000000000000000",
+		sig:     "e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca821525f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0",
+	},
+	{
+		schnorr: true,
+		pubkey:  "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+		msg:     "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+		sig:     "6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de33418906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a",
+	},
+	{
+		schnorr: true,
+		pubkey:  "dd308afec5777e13121fa72b9cc1b7cc0139715309b086c960e18fd969774eb8",
+		msg:     "7e2d58d8b3bcdf1abadec7829054f90dda9805aab56c77333024b9d0a508b75c",
+		sig:     "5831aaeed7b44bb74e5eab94ba9d4294c49bcf2a60728d8b4c200f50dd313c1bab745879a5ad954a72c45a91c3a51d3c7adea98d82f8481e0e1e03674a6f3fb7",
+	},
+	{
+		schnorr: true,
+		pubkey:  "25d1dff95105f5253c4022f628a996ad3a0d95fbf21d468a1b33f8c160d8f517",
+		msg:     "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		sig:     "7eb0509757e246f19449885651611cb965ecc1a187dd51b64fda1edc9637d5ec97582b9cb13db3933705b32ba982af5af25fd78881ebb32771fc5922efc66ea3",
+	},
+	{
+		schnorr: true,
+		pubkey:  "d69c3509bb99e412e68b0fe8544e72837dfa30746d8be2aa65975f29d22dc7b9",
+		msg:     "4df3c3f68fcc83b27e9d42c90431a72499f17875c81a599b566c9889b9696703",
+		sig:     "00000000000000000000003b78ce563f89a0ed9414f5aa28ad0d96d6795f9c6376afb1548af603b3eb45c9f8207dee1060cb71c04e80f593060b07d28308d7f4",
+	},
+}
+
+// NOTE: 秘密鍵とnonceから作ったECDSA署名。pubkeyは圧縮形式、sigはDER
+func newBatchECDSAEntry(seed string) batchTestEntry {
+	P, z, sig := newTestECDSASignature(seed)
+	return batchTestEntry{
+		pubkey: hex.EncodeToString(P.Serialize(true)),
+		msg:    hex.EncodeToString(z.FillBytes(make([]byte, 32))),
+		sig:    hex.EncodeToString(sig.Serialize()),
+	}
+}
+
+func TestBatchVerifier_Verify(t *testing.T) {
+	// NOTE: msgの最後のバイトを書き換えて不正な署名にする
+	tamper := func(e batchTestEntry) batchTestEntry {
+		msg, _ := hex.DecodeString(e.msg)
+		msg[len(msg)-1] ^= 0x01
+		e.msg = hex.EncodeToString(msg)
+		return e
+	}
+	ecdsa1 := newBatchECDSAEntry("batch 1")
+	ecdsa2 := newBatchECDSAEntry("batch 2")
+	tests := []struct {
+		name       string
+		entries    []batchTestEntry
+		want       bool
+		wantFailed []int
+	}{
+		{
+			name: "empty",
+			want: true,
+		},
+		{
+			name:    "schnorr only",
+			entries: batchSchnorrVectors,
+			want:    true,
+		},
+		{
+			name:    "mixed",
+			entries: append([]batchTestEntry{ecdsa1}, append(batchSchnorrVectors, ecdsa2)...),
+			want:    true,
+		},
+		{
+			name: "invalid entries",
+			entries: []batchTestEntry{
+				batchSchnorrVectors[0],
+				ecdsa1,
+				tamper(batchSchnorrVectors[1]),
+				batchSchnorrVectors[2],
+				tamper(ecdsa2),
+				batchSchnorrVectors[3],
+			},
+			want:       false,
+			wantFailed: []int{2, 4},
+		},
+		{
+			name: "has_even_y(R) is false",
+			entries: []batchTestEntry{
+				batchSchnorrVectors[0],
+				{
+					schnorr: true,
+					pubkey:  "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+					msg:     "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+					sig:     "fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a14602975563cc27944640ac607cd107ae10923d9ef7a73c643e166be5ebeafa34b1ac553e2",
+				},
+			},
+			want:       false,
+			wantFailed: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBatchVerifier(0)
+			for _, e := range tt.entries {
+				msg, _ := hex.DecodeString(e.msg)
+				sigBytes, _ := hex.DecodeString(e.sig)
+				pubkeyBytes, _ := hex.DecodeString(e.pubkey)
+				if e.schnorr {
+					pubkey, err := ParseXOnlyPubKey(pubkeyBytes)
+					if err != nil {
+						t.Fatalf("ParseXOnlyPubKey() error = %v", err)
+					}
+					sig, err := signature.ParseSchnorrSignature(sigBytes)
+					if err != nil {
+						t.Fatalf("ParseSchnorrSignature() error = %v", err)
+					}
+					b.AddSchnorr(pubkey, msg, *sig)
+				} else {
+					pubkey, err := ParseSecp256k1Point(pubkeyBytes)
+					if err != nil {
+						t.Fatalf("ParseSecp256k1Point() error = %v", err)
+					}
+					sig, err := signature.ParseSignature(sigBytes)
+					if err != nil {
+						t.Fatalf("ParseSignature() error = %v", err)
+					}
+					b.AddECDSA(pubkey, new(big.Int).SetBytes(msg), *sig)
+				}
+			}
+			if b.Len() != len(tt.entries) {
+				t.Errorf("BatchVerifier.Len() = %v, want %v", b.Len(), len(tt.entries))
+			}
+			got, gotFailed := b.Verify()
+			if got != tt.want {
+				t.Errorf("BatchVerifier.Verify() = %v, want %v", got, tt.want)
+			}
+			if len(gotFailed) != len(tt.wantFailed) {
+				t.Fatalf("BatchVerifier.Verify() failed = %v, want %v", gotFailed, tt.wantFailed)
+			}
+			for i := range gotFailed {
+				if gotFailed[i] != tt.wantFailed[i] {
+					t.Errorf("BatchVerifier.Verify() failed = %v, want %v", gotFailed, tt.wantFailed)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBatchVerifier_Verify(b *testing.B) {
+	entries := make([]struct {
+		pubkey Secp256k1Point
+		msg    []byte
+		sig    *signature.SchnorrSignature
+	}, len(batchSchnorrVectors))
+	for i, v := range batchSchnorrVectors {
+		pubkeyBytes, _ := hex.DecodeString(v.pubkey)
+		sigBytes, _ := hex.DecodeString(v.sig)
+		entries[i].pubkey, _ = ParseXOnlyPubKey(pubkeyBytes)
+		entries[i].msg, _ = hex.DecodeString(v.msg)
+		entries[i].sig, _ = signature.ParseSchnorrSignature(sigBytes)
+	}
+	b.Run("one by one", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, e := range entries {
+				if !e.pubkey.VerifySchnorr(e.msg, *e.sig) {
+					b.Fatal("invalid signature")
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			v := NewBatchVerifier(1)
+			for _, e := range entries {
+				v.AddSchnorr(e.pubkey, e.msg, *e.sig)
+			}
+			if ok, _ := v.Verify(); !ok {
+				b.Fatal("invalid signature")
+			}
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package secp256k1
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// NOTE: p = 2^256 - fieldC なので 2^256 ≡ fieldC (mod p) を使って還元する
+const fieldC = 0x1000003d1
+
+// NOTE: secp256k1 の素体の元を64bitのリム4つ(リトルエンディアン)で固定長に表す
+// NOTE: 演算の途中では [0, 2^256) に収まっていればよく、pとの比較は normalize するまで遅延させる
+// NOTE: 演算は秘密の値によって分岐しない
+type fieldVal [4]uint64
+
+func fieldFromBig(x *big.Int) fieldVal {
+	var b [32]byte
+	new(big.Int).Mod(x, NewSecp256p()).FillBytes(b[:])
+	return fieldFromBytes(b)
+}
+
+// NOTE: 32バイトのビッグエンディアンから読み込む。p以上の値はpを引いて正規化する
+func fieldFromBytes(b [32]byte) fieldVal {
+	var r fieldVal
+	for i := 0; i < 4; i++ {
+		r[i] = uint64(b[31-8*i]) | uint64(b[30-8*i])<<8 | uint64(b[29-8*i])<<16 | uint64(b[28-8*i])<<24 |
+			uint64(b[27-8*i])<<32 | uint64(b[26-8*i])<<40 | uint64(b[25-8*i])<<48 | uint64(b[24-8*i])<<56
+	}
+	r.normalize()
+	return r
+}
+
+func (a *fieldVal) bytes() [32]byte {
+	n := *a
+	n.normalize()
+	var b [32]byte
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			b[31-8*i-j] = byte(n[i] >> (8 * j))
+		}
+	}
+	return b
+}
+
+func (a *fieldVal) big() *big.Int {
+	b := a.bytes()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// NOTE: 2^256未満の値を [0, p) に正規化する
+func (a *fieldVal) normalize() {
+	// NOTE: a + fieldC が2^256を超えるなら a >= p なので、そちらを採用する
+	var t fieldVal
+	var c uint64
+	t[0], c = bits.Add64(a[0], fieldC, 0)
+	t[1], c = bits.Add64(a[1], 0, c)
+	t[2], c = bits.Add64(a[2], 0, c)
+	t[3], c = bits.Add64(a[3], 0, c)
+	a.cmov(&t, c)
+}
+
+// NOTE: carry*2^256 + a を2^256未満に収める。2^256 ≡ fieldC なので桁あふれ分はfieldCを足して戻す
+// NOTE: 足した結果がさらに桁あふれした場合、値はfieldC未満になっているのでもう一度足せば収まる
+func (a *fieldVal) addCarry(carry uint64) {
+	var c uint64
+	a[0], c = bits.Add64(a[0], carry*fieldC, 0)
+	a[1], c = bits.Add64(a[1], 0, c)
+	a[2], c = bits.Add64(a[2], 0, c)
+	a[3], c = bits.Add64(a[3], 0, c)
+	a[0], c = bits.Add64(a[0], c*fieldC, 0)
+	a[1], c = bits.Add64(a[1], 0, c)
+	a[2], c = bits.Add64(a[2], 0, c)
+	a[3], _ = bits.Add64(a[3], 0, c)
+}
+
+// NOTE: flagが1のときだけbをaにコピーする。flagは0か1
+func (a *fieldVal) cmov(b *fieldVal, flag uint64) {
+	mask := -flag
+	for i := range a {
+		a[i] ^= mask & (a[i] ^ b[i])
+	}
+}
+
+func (a *fieldVal) isZero() uint64 {
+	n := *a
+	n.normalize()
+	x := n[0] | n[1] | n[2] | n[3]
+	return 1 ^ ((x | -x) >> 63)
+}
+
+func (a *fieldVal) equals(b *fieldVal) bool {
+	d := fieldSub(a, b)
+	return d.isZero() == 1
+}
+
+func (a *fieldVal) isOdd() bool {
+	n := *a
+	n.normalize()
+	return n[0]&1 == 1
+}
+
+func fieldAdd(a, b *fieldVal) fieldVal {
+	var r fieldVal
+	var c uint64
+	r[0], c = bits.Add64(a[0], b[0], 0)
+	r[1], c = bits.Add64(a[1], b[1], c)
+	r[2], c = bits.Add64(a[2], b[2], c)
+	r[3], c = bits.Add64(a[3], b[3], c)
+	r.addCarry(c)
+	return r
+}
+
+func fieldSub(a, b *fieldVal) fieldVal {
+	var r fieldVal
+	var borrow uint64
+	r[0], borrow = bits.Sub64(a[0], b[0], 0)
+	r[1], borrow = bits.Sub64(a[1], b[1], borrow)
+	r[2], borrow = bits.Sub64(a[2], b[2], borrow)
+	r[3], borrow = bits.Sub64(a[3], b[3], borrow)
+	// NOTE: 負になった場合は2^256が足された状態なので、fieldCを引くとpを足したことになる
+	// NOTE: それでも負になる場合はもう一度pを足す
+	var c uint64
+	r[0], c = bits.Sub64(r[0], borrow*fieldC, 0)
+	r[1], c = bits.Sub64(r[1], 0, c)
+	r[2], c = bits.Sub64(r[2], 0, c)
+	r[3], c = bits.Sub64(r[3], 0, c)
+	r[0], c = bits.Sub64(r[0], c*fieldC, 0)
+	r[1], c = bits.Sub64(r[1], 0, c)
+	r[2], c = bits.Sub64(r[2], 0, c)
+	r[3], _ = bits.Sub64(r[3], 0, c)
+	return r
+}
+
+func fieldNeg(a *fieldVal) fieldVal {
+	var zero fieldVal
+	return fieldSub(&zero, a)
+}
+
+func fieldMul(a, b *fieldVal) fieldVal {
+	// NOTE: 512bitの積を計算してから還元する
+	var t [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[i], b[j])
+			var c uint64
+			lo, c = bits.Add64(lo, t[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[i+j] = lo
+			carry = hi
+		}
+		t[i+4] = carry
+	}
+	return fieldReduce(&t)
+}
+
+func fieldSquare(a *fieldVal) fieldVal {
+	return fieldMul(a, a)
+}
+
+// NOTE: 小さい定数との積。bは2^32未満であること
+func fieldMulInt(a *fieldVal, b uint64) fieldVal {
+	var t [8]uint64
+	var carry uint64
+	for i := 0; i < 4; i++ {
+		hi, lo := bits.Mul64(a[i], b)
+		var c uint64
+		t[i], c = bits.Add64(lo, carry, 0)
+		carry = hi + c
+	}
+	t[4] = carry
+	return fieldReduce(&t)
+}
+
+// NOTE: t = hi*2^256 + lo ≡ hi*fieldC + lo (mod p) を2回繰り返して256bitに収める
+func fieldReduce(t *[8]uint64) fieldVal {
+	var u [5]uint64
+	var carry uint64
+	for i := 0; i < 4; i++ {
+		hi, lo := bits.Mul64(t[4+i], fieldC)
+		var c uint64
+		lo, c = bits.Add64(lo, carry, 0)
+		u[i] = lo
+		carry = hi + c
+	}
+	u[4] = carry
+
+	var c uint64
+	u[0], c = bits.Add64(u[0], t[0], 0)
+	u[1], c = bits.Add64(u[1], t[1], c)
+	u[2], c = bits.Add64(u[2], t[2], c)
+	u[3], c = bits.Add64(u[3], t[3], c)
+	u[4] += c
+
+	var r fieldVal
+	hi, lo := bits.Mul64(u[4], fieldC)
+	r[0], c = bits.Add64(u[0], lo, 0)
+	r[1], c = bits.Add64(u[1], hi, c)
+	r[2], c = bits.Add64(u[2], 0, c)
+	r[3], c = bits.Add64(u[3], 0, c)
+	r.addCarry(c)
+	return r
+}
+
+// NOTE: Fermatの小定理で a^(p-2) を計算する。指数は公開値なので実行時間は一定
+func fieldInverse(a *fieldVal) fieldVal {
+	// NOTE: p-2 の各リム
+	e := [4]uint64{0xfffffffefffffc2d, 0xffffffffffffffff, 0xffffffffffffffff, 0xffffffffffffffff}
+	r := fieldVal{1}
+	for i := 3; i >= 0; i-- {
+		for j := 63; j >= 0; j-- {
+			r = fieldSquare(&r)
+			if (e[i]>>j)&1 == 1 {
+				r = fieldMul(&r, a)
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,156 @@
+package secp256k1
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// NOTE: big.Int で計算した結果と突き合わせる
+func fieldTestValues() []*big.Int {
+	s256p := NewSecp256p()
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(2),
+		big.NewInt(fieldC),
+		new(big.Int).Sub(s256p, big.NewInt(1)),
+		new(big.Int).Sub(s256p, big.NewInt(2)),
+		new(big.Int).Lsh(big.NewInt(1), 255),
+		new(big.Int).Lsh(big.NewInt(1), 128),
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 16; i++ {
+		values = append(values, new(big.Int).Rand(r, s256p))
+	}
+	return values
+}
+
+func Test_fieldVal(t *testing.T) {
+	s256p := NewSecp256p()
+	values := fieldTestValues()
+	tests := []struct {
+		name string
+		got  func(a, b *fieldVal) fieldVal
+		want func(a, b *big.Int) *big.Int
+	}{
+		{
+			name: "add",
+			got:  fieldAdd,
+			want: func(a, b *big.Int) *big.Int { return new(big.Int).Add(a, b) },
+		},
+		{
+			name: "sub",
+			got:  fieldSub,
+			want: func(a, b *big.Int) *big.Int { return new(big.Int).Sub(a, b) },
+		},
+		{
+			name: "neg",
+			got:  func(a, b *fieldVal) fieldVal { return fieldNeg(a) },
+			want: func(a, b *big.Int) *big.Int { return new(big.Int).Neg(a) },
+		},
+		{
+			name: "mul",
+			got:  fieldMul,
+			want: func(a, b *big.Int) *big.Int { return new(big.Int).Mul(a, b) },
+		},
+		{
+			name: "mul int",
+			got:  func(a, b *fieldVal) fieldVal { return fieldMulInt(a, curveB3) },
+			want: func(a, b *big.Int) *big.Int { return new(big.Int).Mul(a, big.NewInt(curveB3)) },
+		},
+		{
+			name: "inverse",
+			got:  func(a, b *fieldVal) fieldVal { return fieldInverse(a) },
+			want: func(a, b *big.Int) *big.Int {
+				if a.Sign() == 0 {
+					return big.NewInt(0)
+				}
+				return new(big.Int).ModInverse(a, s256p)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, a := range values {
+				for _, b := range values {
+					fa := fieldFromBig(a)
+					fb := fieldFromBig(b)
+					got := tt.got(&fa, &fb)
+					want := tt.want(a, b)
+					want.Mod(want, s256p)
+					if got.big().Cmp(want) != 0 {
+						t.Fatalf("%s(%x, %x) = %x, want %x", tt.name, a, b, got.big(), want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_fieldFromBytes(t *testing.T) {
+	s256p := NewSecp256p()
+	tests := []struct {
+		name string
+		in   *big.Int
+		want *big.Int
+	}{
+		{
+			name: "p",
+			in:   s256p,
+			want: big.NewInt(0),
+		},
+		{
+			name: "2^256 - 1",
+			in:   new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)),
+			want: big.NewInt(fieldC - 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b [32]byte
+			tt.in.FillBytes(b[:])
+			got := fieldFromBytes(b)
+			if got.big().Cmp(tt.want) != 0 {
+				t.Errorf("fieldFromBytes() = %x, want %x", got.big(), tt.want)
+			}
+			if got.bytes() != [32]byte(tt.want.FillBytes(make([]byte, 32))) {
+				t.Errorf("fieldVal.bytes() = %x, want %x", got.bytes(), tt.want)
+			}
+		})
+	}
+}
+
+// NOTE: 正規化していない [p, 2^256) の値を入力しても正しく計算できる
+func Test_fieldVal_unnormalized(t *testing.T) {
+	s256p := NewSecp256p()
+	// NOTE: 2^256 - 1 ≡ fieldC - 1, p ≡ 0
+	weak := []fieldVal{
+		{0xffffffffffffffff, 0xffffffffffffffff, 0xffffffffffffffff, 0xffffffffffffffff},
+		{0xfffffffefffffc2f, 0xffffffffffffffff, 0xffffffffffffffff, 0xffffffffffffffff},
+	}
+	values := []*big.Int{big.NewInt(fieldC - 1), big.NewInt(0)}
+	for i := range weak {
+		for j := range weak {
+			a, b := weak[i], weak[j]
+			sum := fieldAdd(&a, &b)
+			want := new(big.Int).Add(values[i], values[j])
+			if got := sum.big(); got.Cmp(want.Mod(want, s256p)) != 0 {
+				t.Errorf("fieldAdd() = %x, want %x", got, want)
+			}
+			diff := fieldSub(&a, &b)
+			want = new(big.Int).Sub(values[i], values[j])
+			if got := diff.big(); got.Cmp(want.Mod(want, s256p)) != 0 {
+				t.Errorf("fieldSub() = %x, want %x", got, want)
+			}
+			prod := fieldMul(&a, &b)
+			want = new(big.Int).Mul(values[i], values[j])
+			if got := prod.big(); got.Cmp(want.Mod(want, s256p)) != 0 {
+				t.Errorf("fieldMul() = %x, want %x", got, want)
+			}
+		}
+		if got := weak[i].equals(&fieldVal{uint64(values[i].Int64())}); !got {
+			t.Errorf("fieldVal.equals() = false, want true")
+		}
+	}
+}
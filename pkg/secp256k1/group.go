@@ -0,0 +1,150 @@
+package secp256k1
+
+import (
+	"golang-bitcoin/pkg/utils"
+	"math/big"
+)
+
+// NOTE: 射影座標 (X:Y:Z) で x = X/Z, y = Y/Z を表す。無限遠点は (0:1:0)
+// NOTE: Renes-Costello-Batina の完全な加算公式を使うので、無限遠点や同じ点同士の加算でも分岐しない
+type projectivePoint struct {
+	x, y, z fieldVal
+}
+
+// NOTE: 3b = 21
+const curveB3 = 21
+
+func projectiveInfinity() projectivePoint {
+	return projectivePoint{y: fieldVal{1}}
+}
+
+func projectiveFromPoint(p Secp256k1Point) projectivePoint {
+	if p.IsInf() {
+		return projectiveInfinity()
+	}
+	return projectivePoint{x: fieldFromBig(p.X()), y: fieldFromBig(p.Y()), z: fieldVal{1}}
+}
+
+// NOTE: 無限遠点は z = 0 なので逆元も0になり、(0, 0) すなわち curve.Point の無限遠点になる
+func (p *projectivePoint) toPoint() Secp256k1Point {
+	zInv := fieldInverse(&p.z)
+	x := fieldMul(&p.x, &zInv)
+	y := fieldMul(&p.y, &zInv)
+	return NewSecp256k1Point(x.big(), y.big())
+}
+
+func (p *projectivePoint) cmov(q *projectivePoint, flag uint64) {
+	p.x.cmov(&q.x, flag)
+	p.y.cmov(&q.y, flag)
+	p.z.cmov(&q.z, flag)
+}
+
+// NOTE: a = 0 の場合の完全な加算公式 (RCB 2015 Algorithm 7)
+func projectiveAdd(p, q *projectivePoint) projectivePoint {
+	t0 := fieldMul(&p.x, &q.x)
+	t1 := fieldMul(&p.y, &q.y)
+	t2 := fieldMul(&p.z, &q.z)
+	t3 := fieldAdd(&p.x, &p.y)
+	t4 := fieldAdd(&q.x, &q.y)
+	t3 = fieldMul(&t3, &t4)
+	t4 = fieldAdd(&t0, &t1)
+	t3 = fieldSub(&t3, &t4)
+	t4 = fieldAdd(&p.y, &p.z)
+	x3 := fieldAdd(&q.y, &q.z)
+	t4 = fieldMul(&t4, &x3)
+	x3 = fieldAdd(&t1, &t2)
+	t4 = fieldSub(&t4, &x3)
+	x3 = fieldAdd(&p.x, &p.z)
+	y3 := fieldAdd(&q.x, &q.z)
+	x3 = fieldMul(&x3, &y3)
+	y3 = fieldAdd(&t0, &t2)
+	y3 = fieldSub(&x3, &y3)
+	x3 = fieldAdd(&t0, &t0)
+	t0 = fieldAdd(&x3, &t0)
+	t2 = fieldMulInt(&t2, curveB3)
+	z3 := fieldAdd(&t1, &t2)
+	t1 = fieldSub(&t1, &t2)
+	y3 = fieldMulInt(&y3, curveB3)
+	x3 = fieldMul(&t4, &y3)
+	t2 = fieldMul(&t3, &t1)
+	x3 = fieldSub(&t2, &x3)
+	y3 = fieldMul(&y3, &t0)
+	t1 = fieldMul(&t1, &z3)
+	y3 = fieldAdd(&t1, &y3)
+	t0 = fieldMul(&t0, &t3)
+	z3 = fieldMul(&z3, &t4)
+	z3 = fieldAdd(&z3, &t0)
+	return projectivePoint{x3, y3, z3}
+}
+
+// NOTE: a = 0 の場合の完全な2倍算公式 (RCB 2015 Algorithm 9)
+func projectiveDouble(p *projectivePoint) projectivePoint {
+	t0 := fieldSquare(&p.y)
+	z3 := fieldAdd(&t0, &t0)
+	z3 = fieldAdd(&z3, &z3)
+	z3 = fieldAdd(&z3, &z3)
+	t1 := fieldMul(&p.y, &p.z)
+	t2 := fieldSquare(&p.z)
+	t2 = fieldMulInt(&t2, curveB3)
+	x3 := fieldMul(&t2, &z3)
+	y3 := fieldAdd(&t0, &t2)
+	z3 = fieldMul(&t1, &z3)
+	t1 = fieldAdd(&t2, &t2)
+	t2 = fieldAdd(&t1, &t2)
+	t0 = fieldSub(&t0, &t2)
+	y3 = fieldMul(&t0, &y3)
+	y3 = fieldAdd(&x3, &y3)
+	t1 = fieldMul(&p.x, &p.y)
+	x3 = fieldMul(&t0, &t1)
+	x3 = fieldAdd(&x3, &x3)
+	return projectivePoint{x3, y3, z3}
+}
+
+// NOTE: 4bitの固定ウィンドウでkPを計算する
+// NOTE: スカラーのビットによらず同じ回数の2倍算・加算を行い、テーブルも全要素を走査して選ぶ
+func projectiveScalarMult(p *projectivePoint, k *[32]byte) projectivePoint {
+	var table [16]projectivePoint
+	table[0] = projectiveInfinity()
+	for i := 1; i < 16; i++ {
+		table[i] = projectiveAdd(&table[i-1], p)
+	}
+
+	r := projectiveInfinity()
+	for i := 0; i < 64; i++ {
+		for j := 0; j < 4; j++ {
+			r = projectiveDouble(&r)
+		}
+		// NOTE: 上位のニブルから順に処理する
+		nibble := uint64(k[i/2]>>(4*(1-i%2))) & 0x0f
+		var selected projectivePoint
+		for j := range table {
+			selected.cmov(&table[j], ctEqual(uint64(j), nibble))
+		}
+		r = projectiveAdd(&r, &selected)
+	}
+	return r
+}
+
+// NOTE: a == b なら1、そうでなければ0を分岐せずに返す
+func ctEqual(a, b uint64) uint64 {
+	x := a ^ b
+	return 1 ^ ((x | -x) >> 63)
+}
+
+// NOTE: 秘密鍵やnonceを掛けるときに使う定数時間のスカラー倍算
+// NOTE: スカラーは32バイトの固定長に変換してから使い、使い終わったら消去する
+func (p Secp256k1Point) MultiplyConstantTime(scalar *big.Int) Secp256k1Point {
+	var k [32]byte
+	defer clear(k[:])
+	if scalar.Sign() < 0 || scalar.BitLen() > 256 {
+		reduced := new(big.Int).Mod(scalar, NewSecp256k1n())
+		reduced.FillBytes(k[:])
+		utils.ZeroBigInt(reduced)
+	} else {
+		scalar.FillBytes(k[:])
+	}
+
+	base := projectiveFromPoint(p)
+	r := projectiveScalarMult(&base, &k)
+	return r.toPoint()
+}
@@ -0,0 +1,81 @@
+package secp256k1
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSecp256k1Point_MultiplyConstantTime(t *testing.T) {
+	s256n := NewSecp256k1n()
+	G := NewSecp256k1G()
+	P := Secp256k1Point{G.Multiply(big.NewInt(0xdeadbeef))}
+	tests := []struct {
+		name   string
+		point  Secp256k1Point
+		scalar *big.Int
+	}{
+		{
+			name:   "zero",
+			point:  G,
+			scalar: big.NewInt(0),
+		},
+		{
+			name:   "one",
+			point:  G,
+			scalar: big.NewInt(1),
+		},
+		{
+			name:   "two",
+			point:  G,
+			scalar: big.NewInt(2),
+		},
+		{
+			name:   "n-1",
+			point:  G,
+			scalar: new(big.Int).Sub(s256n, big.NewInt(1)),
+		},
+		{
+			name:   "n",
+			point:  G,
+			scalar: s256n,
+		},
+		{
+			name:   "larger than 2^256",
+			point:  G,
+			scalar: new(big.Int).Lsh(big.NewInt(12345), 256),
+		},
+		{
+			name:   "arbitrary point",
+			point:  P,
+			scalar: new(big.Int).SetBytes([]byte("my secret scalar value")),
+		},
+		{
+			name:   "infinity",
+			point:  Secp256k1Point{G.Multiply(big.NewInt(0))},
+			scalar: big.NewInt(5),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.point.Multiply(new(big.Int).Mod(tt.scalar, s256n))
+			got := tt.point.MultiplyConstantTime(tt.scalar)
+			if !got.Equals(want) {
+				t.Errorf("Secp256k1Point.MultiplyConstantTime() = (%x, %x), want (%x, %x)", got.X(), got.Y(), want.X(), want.Y())
+			}
+		})
+	}
+}
+
+func Test_projectiveDouble(t *testing.T) {
+	G := NewSecp256k1G()
+	p := projectiveFromPoint(G)
+	doubled := projectiveDouble(&p)
+	added := projectiveAdd(&p, &p)
+	want := G.Multiply(big.NewInt(2))
+	if got := doubled.toPoint(); !got.Equals(want) {
+		t.Errorf("projectiveDouble() = (%x, %x), want (%x, %x)", got.X(), got.Y(), want.X(), want.Y())
+	}
+	if got := added.toPoint(); !got.Equals(want) {
+		t.Errorf("projectiveAdd() = (%x, %x), want (%x, %x)", got.X(), got.Y(), want.X(), want.Y())
+	}
+}
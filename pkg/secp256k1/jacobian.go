@@ -0,0 +1,304 @@
+package secp256k1
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+// NOTE: 署名検証など公開値しか扱わない処理のための高速な(可変時間の)演算
+// NOTE: ヤコビアン座標 (X:Y:Z) で x = X/Z^2, y = Y/Z^3 を表す
+type jacobianPoint struct {
+	x, y, z fieldVal
+	inf     bool
+}
+
+// NOTE: アフィン座標の点。事前計算テーブルに使う
+type affinePoint struct {
+	x, y fieldVal
+}
+
+const (
+	// NOTE: wNAFのウィンドウ幅。Gは事前計算するので大きめにとる
+	generatorWindow = 8
+	pointWindow     = 5
+)
+
+var (
+	generatorTableOnce sync.Once
+	// NOTE: G, 3G, 5G, ..., (2^(generatorWindow-1)-1)G
+	generatorTable []affinePoint
+)
+
+func jacobianFromPoint(p Secp256k1Point) jacobianPoint {
+	if p.IsInf() {
+		return jacobianPoint{inf: true}
+	}
+	return jacobianPoint{x: fieldFromBig(p.X()), y: fieldFromBig(p.Y()), z: fieldVal{1}}
+}
+
+func (p *jacobianPoint) toAffine() affinePoint {
+	zInv := fieldInverse(&p.z)
+	zInv2 := fieldSquare(&zInv)
+	zInv3 := fieldMul(&zInv2, &zInv)
+	return affinePoint{x: fieldMul(&p.x, &zInv2), y: fieldMul(&p.y, &zInv3)}
+}
+
+func (p *jacobianPoint) toPoint() Secp256k1Point {
+	if p.inf {
+		return NewSecp256k1Point(big.NewInt(0), big.NewInt(0))
+	}
+	a := p.toAffine()
+	return NewSecp256k1Point(a.x.big(), a.y.big())
+}
+
+func (p *jacobianPoint) neg() jacobianPoint {
+	return jacobianPoint{x: p.x, y: fieldNeg(&p.y), z: p.z, inf: p.inf}
+}
+
+func (a *affinePoint) neg() affinePoint {
+	return affinePoint{x: a.x, y: fieldNeg(&a.y)}
+}
+
+// NOTE: dbl-2009-l (a = 0)。secp256k1には位数2の点がないのでy=0の場合は考えなくてよい
+func jacobianDouble(p *jacobianPoint) jacobianPoint {
+	if p.inf {
+		return *p
+	}
+	a := fieldSquare(&p.x)
+	b := fieldSquare(&p.y)
+	c := fieldSquare(&b)
+	// NOTE: D = 2*((X1+B)^2-A-C)
+	d := fieldAdd(&p.x, &b)
+	d = fieldSquare(&d)
+	d = fieldSub(&d, &a)
+	d = fieldSub(&d, &c)
+	d = fieldAdd(&d, &d)
+	e := fieldMulInt(&a, 3)
+	f := fieldSquare(&e)
+	// NOTE: X3 = F-2*D
+	twoD := fieldAdd(&d, &d)
+	x3 := fieldSub(&f, &twoD)
+	// NOTE: Y3 = E*(D-X3)-8*C
+	y3 := fieldSub(&d, &x3)
+	y3 = fieldMul(&e, &y3)
+	eightC := fieldMulInt(&c, 8)
+	y3 = fieldSub(&y3, &eightC)
+	// NOTE: Z3 = 2*Y1*Z1
+	z3 := fieldMul(&p.y, &p.z)
+	z3 = fieldAdd(&z3, &z3)
+	return jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+// NOTE: madd-2007-bl。qはアフィン座標 (Z2 = 1)
+func jacobianAddAffine(p *jacobianPoint, q *affinePoint) jacobianPoint {
+	if p.inf {
+		return jacobianPoint{x: q.x, y: q.y, z: fieldVal{1}}
+	}
+	z1z1 := fieldSquare(&p.z)
+	u2 := fieldMul(&q.x, &z1z1)
+	s2 := fieldMul(&q.y, &p.z)
+	s2 = fieldMul(&s2, &z1z1)
+	h := fieldSub(&u2, &p.x)
+	r := fieldSub(&s2, &p.y)
+	if h.isZero() == 1 {
+		// NOTE: x座標が同じなら同じ点か逆元
+		if r.isZero() == 1 {
+			return jacobianDouble(p)
+		}
+		return jacobianPoint{inf: true}
+	}
+	r = fieldAdd(&r, &r)
+	hh := fieldSquare(&h)
+	i := fieldMulInt(&hh, 4)
+	j := fieldMul(&h, &i)
+	v := fieldMul(&p.x, &i)
+	// NOTE: X3 = r^2-J-2*V
+	x3 := fieldSquare(&r)
+	x3 = fieldSub(&x3, &j)
+	x3 = fieldSub(&x3, &v)
+	x3 = fieldSub(&x3, &v)
+	// NOTE: Y3 = r*(V-X3)-2*Y1*J
+	y3 := fieldSub(&v, &x3)
+	y3 = fieldMul(&r, &y3)
+	y1j := fieldMul(&p.y, &j)
+	y3 = fieldSub(&y3, &y1j)
+	y3 = fieldSub(&y3, &y1j)
+	// NOTE: Z3 = (Z1+H)^2-Z1Z1-HH
+	z3 := fieldAdd(&p.z, &h)
+	z3 = fieldSquare(&z3)
+	z3 = fieldSub(&z3, &z1z1)
+	z3 = fieldSub(&z3, &hh)
+	return jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+// NOTE: add-2007-bl
+func jacobianAdd(p, q *jacobianPoint) jacobianPoint {
+	if p.inf {
+		return *q
+	}
+	if q.inf {
+		return *p
+	}
+	z1z1 := fieldSquare(&p.z)
+	z2z2 := fieldSquare(&q.z)
+	u1 := fieldMul(&p.x, &z2z2)
+	u2 := fieldMul(&q.x, &z1z1)
+	s1 := fieldMul(&p.y, &q.z)
+	s1 = fieldMul(&s1, &z2z2)
+	s2 := fieldMul(&q.y, &p.z)
+	s2 = fieldMul(&s2, &z1z1)
+	h := fieldSub(&u2, &u1)
+	r := fieldSub(&s2, &s1)
+	if h.isZero() == 1 {
+		if r.isZero() == 1 {
+			return jacobianDouble(p)
+		}
+		return jacobianPoint{inf: true}
+	}
+	r = fieldAdd(&r, &r)
+	i := fieldAdd(&h, &h)
+	i = fieldSquare(&i)
+	j := fieldMul(&h, &i)
+	v := fieldMul(&u1, &i)
+	// NOTE: X3 = r^2-J-2*V
+	x3 := fieldSquare(&r)
+	x3 = fieldSub(&x3, &j)
+	x3 = fieldSub(&x3, &v)
+	x3 = fieldSub(&x3, &v)
+	// NOTE: Y3 = r*(V-X3)-2*S1*J
+	y3 := fieldSub(&v, &x3)
+	y3 = fieldMul(&r, &y3)
+	s1j := fieldMul(&s1, &j)
+	y3 = fieldSub(&y3, &s1j)
+	y3 = fieldSub(&y3, &s1j)
+	// NOTE: Z3 = ((Z1+Z2)^2-Z1Z1-Z2Z2)*H
+	z3 := fieldAdd(&p.z, &q.z)
+	z3 = fieldSquare(&z3)
+	z3 = fieldSub(&z3, &z1z1)
+	z3 = fieldSub(&z3, &z2z2)
+	z3 = fieldMul(&z3, &h)
+	return jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+// NOTE: 奇数倍 P, 3P, 5P, ... を n 個計算する
+func oddMultiples(p *jacobianPoint, n int) []jacobianPoint {
+	table := make([]jacobianPoint, n)
+	table[0] = *p
+	twoP := jacobianDouble(p)
+	for i := 1; i < n; i++ {
+		table[i] = jacobianAdd(&table[i-1], &twoP)
+	}
+	return table
+}
+
+func getGeneratorTable() []affinePoint {
+	generatorTableOnce.Do(func() {
+		g := jacobianFromPoint(NewSecp256k1G())
+		multiples := oddMultiples(&g, 1<<(generatorWindow-2))
+		generatorTable = make([]affinePoint, len(multiples))
+		for i := range multiples {
+			generatorTable[i] = multiples[i].toAffine()
+		}
+	})
+	return generatorTable
+}
+
+// NOTE: スカラーを幅wのwNAFに変換する。各桁は0か (-2^(w-1), 2^(w-1)) の奇数
+// NOTE: スカラーはn未満であること
+func wnaf(scalar *big.Int, w uint) []int8 {
+	// NOTE: k -= digit で負の桁を足したときに256bitを超えても良いように1リム余分にとる
+	var k [5]uint64
+	var b [32]byte
+	scalar.FillBytes(b[:])
+	for i := 0; i < 4; i++ {
+		k[i] = binary.BigEndian.Uint64(b[24-8*i:])
+	}
+	isZero := func() bool {
+		return k[0]|k[1]|k[2]|k[3]|k[4] == 0
+	}
+	window := uint64(1) << w
+	naf := make([]int8, 0, 257)
+	for !isZero() {
+		var digit int64
+		if k[0]&1 == 1 {
+			digit = int64(k[0] & (window - 1))
+			if digit >= int64(window>>1) {
+				digit -= int64(window)
+			}
+			// NOTE: k -= digit
+			if digit > 0 {
+				k[0] -= uint64(digit)
+			} else {
+				var c uint64
+				k[0], c = bits.Add64(k[0], uint64(-digit), 0)
+				for i := 1; i < len(k); i++ {
+					k[i], c = bits.Add64(k[i], 0, c)
+				}
+			}
+		}
+		naf = append(naf, int8(digit))
+		// NOTE: k >>= 1
+		for i := 0; i < len(k)-1; i++ {
+			k[i] = k[i]>>1 | k[i+1]<<63
+		}
+		k[len(k)-1] >>= 1
+	}
+	return naf
+}
+
+// NOTE: u*G + v*P をwNAFとShamirのトリックで同時に計算する
+func doubleScalarMult(u *big.Int, v *big.Int, p *jacobianPoint) jacobianPoint {
+	return multiScalarMult(u, []*big.Int{v}, []jacobianPoint{*p})
+}
+
+// NOTE: u*G + Σ scalars[i]*points[i] を計算する (Strauss の方法)
+// NOTE: 2倍算を全ての点で共有するので、点の数が多いほど1点あたりのコストが下がる
+func multiScalarMult(u *big.Int, scalars []*big.Int, points []jacobianPoint) jacobianPoint {
+	s256n := NewSecp256k1n()
+	gTable := getGeneratorTable()
+	uNaf := wnaf(new(big.Int).Mod(u, s256n), generatorWindow)
+	length := len(uNaf)
+
+	nafs := make([][]int8, len(points))
+	tables := make([][]jacobianPoint, len(points))
+	for i := range points {
+		if points[i].inf {
+			continue
+		}
+		nafs[i] = wnaf(new(big.Int).Mod(scalars[i], s256n), pointWindow)
+		if len(nafs[i]) == 0 {
+			continue
+		}
+		tables[i] = oddMultiples(&points[i], 1<<(pointWindow-2))
+		length = max(length, len(nafs[i]))
+	}
+
+	r := jacobianPoint{inf: true}
+	for bit := length - 1; bit >= 0; bit-- {
+		r = jacobianDouble(&r)
+		if bit < len(uNaf) && uNaf[bit] != 0 {
+			d := uNaf[bit]
+			if d > 0 {
+				r = jacobianAddAffine(&r, &gTable[d/2])
+			} else {
+				q := gTable[-d/2].neg()
+				r = jacobianAddAffine(&r, &q)
+			}
+		}
+		for i := range points {
+			if bit >= len(nafs[i]) || nafs[i][bit] == 0 {
+				continue
+			}
+			d := nafs[i][bit]
+			if d > 0 {
+				r = jacobianAdd(&r, &tables[i][d/2])
+			} else {
+				q := tables[i][-d/2].neg()
+				r = jacobianAdd(&r, &q)
+			}
+		}
+	}
+	return r
+}
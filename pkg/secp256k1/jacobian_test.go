@@ -0,0 +1,198 @@
+package secp256k1
+
+import (
+	"golang-bitcoin/pkg/signature"
+	"golang-bitcoin/pkg/utils"
+	"math/big"
+	"testing"
+)
+
+func Test_wnaf(t *testing.T) {
+	s256n := NewSecp256k1n()
+	tests := []struct {
+		name   string
+		scalar *big.Int
+		w      uint
+	}{
+		{name: "zero", scalar: big.NewInt(0), w: 5},
+		{name: "one", scalar: big.NewInt(1), w: 5},
+		{name: "negative digit", scalar: big.NewInt(0x1f), w: 5},
+		{name: "n-1 window 5", scalar: new(big.Int).Sub(s256n, big.NewInt(1)), w: 5},
+		{name: "n-1 window 8", scalar: new(big.Int).Sub(s256n, big.NewInt(1)), w: 8},
+		{name: "all ones", scalar: new(big.Int).SetBytes(utils.Hash256([]byte("wnaf"))), w: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			naf := wnaf(tt.scalar, tt.w)
+			// NOTE: 各桁から元のスカラーを復元できること、非ゼロの桁の間に少なくとも w-1 個の0があること
+			got := new(big.Int)
+			last := -int(tt.w)
+			for i := len(naf) - 1; i >= 0; i-- {
+				got.Lsh(got, 1)
+				got.Add(got, big.NewInt(int64(naf[i])))
+				if naf[i] == 0 {
+					continue
+				}
+				bound := 1 << (tt.w - 1)
+				if d := int(naf[i]); d%2 == 0 || d >= bound || d <= -bound {
+					t.Errorf("wnaf() digit %d = %d is out of range", i, naf[i])
+				}
+				if last >= 0 && last-i < int(tt.w) {
+					t.Errorf("wnaf() digits %d and %d are too close", last, i)
+				}
+				last = i
+			}
+			if got.Cmp(tt.scalar) != 0 {
+				t.Errorf("wnaf() = %x, want %x", got, tt.scalar)
+			}
+		})
+	}
+}
+
+func TestSecp256k1Point_Multiply(t *testing.T) {
+	s256n := NewSecp256k1n()
+	G := NewSecp256k1G()
+	P := NewSecp256k1Point(G.Point.Multiply(big.NewInt(0xdeadbeef)).X(), G.Point.Multiply(big.NewInt(0xdeadbeef)).Y())
+	tests := []struct {
+		name   string
+		point  Secp256k1Point
+		scalar *big.Int
+	}{
+		{name: "G zero", point: G, scalar: big.NewInt(0)},
+		{name: "G one", point: G, scalar: big.NewInt(1)},
+		{name: "G n-1", point: G, scalar: new(big.Int).Sub(s256n, big.NewInt(1))},
+		{name: "G n", point: G, scalar: s256n},
+		{name: "G large", point: G, scalar: new(big.Int).SetBytes(utils.Hash256([]byte("G")))},
+		{name: "P one", point: P, scalar: big.NewInt(1)},
+		{name: "P two", point: P, scalar: big.NewInt(2)},
+		{name: "P n-1", point: P, scalar: new(big.Int).Sub(s256n, big.NewInt(1))},
+		{name: "P large", point: P, scalar: new(big.Int).SetBytes(utils.Hash256([]byte("P")))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// NOTE: curve.Point の素朴な実装と比較する
+			want := tt.point.Point.Multiply(tt.scalar)
+			if got := tt.point.Multiply(tt.scalar); !got.Equals(want) {
+				t.Errorf("Secp256k1Point.Multiply() = (%x, %x), want (%x, %x)", got.X(), got.Y(), want.X(), want.Y())
+			}
+		})
+	}
+}
+
+func Test_doubleScalarMult(t *testing.T) {
+	G := NewSecp256k1G()
+	P := Secp256k1Point{G.Point.Multiply(big.NewInt(7))}
+	jp := jacobianFromPoint(P)
+	tests := []struct {
+		name string
+		u, v *big.Int
+	}{
+		{name: "uG + vP", u: big.NewInt(3), v: big.NewInt(5)},
+		{name: "cancel out", u: new(big.Int).Sub(NewSecp256k1n(), big.NewInt(7)), v: big.NewInt(1)},
+		{name: "same point", u: big.NewInt(7), v: big.NewInt(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := G.Point.Multiply(tt.u).Add(P.Point.Multiply(tt.v))
+			r := doubleScalarMult(tt.u, tt.v, &jp)
+			if got := r.toPoint(); !got.Equals(want) {
+				t.Errorf("doubleScalarMult() = (%x, %x), want (%x, %x)", got.X(), got.Y(), want.X(), want.Y())
+			}
+		})
+	}
+}
+
+func TestSecp256k1Point_Verify(t *testing.T) {
+	s256n := NewSecp256k1n()
+	secret := big.NewInt(12345)
+	k := big.NewInt(67890)
+	P := Secp256k1Point{NewSecp256k1G().Point.Multiply(secret)}
+	z := new(big.Int).SetBytes(utils.Hash256([]byte("verify")))
+	r := NewSecp256k1G().Point.Multiply(k).X()
+	r.Mod(r, s256n)
+	s := new(big.Int).Mul(secret, r)
+	s.Add(s, z)
+	s.Mul(s, new(big.Int).ModInverse(k, s256n))
+	s.Mod(s, s256n)
+	tests := []struct {
+		name string
+		r, s *big.Int
+		want bool
+	}{
+		{name: "valid", r: r, s: s, want: true},
+		{name: "high s", r: r, s: new(big.Int).Sub(s256n, s), want: true},
+		{name: "wrong s", r: r, s: new(big.Int).Add(s, big.NewInt(1)), want: false},
+		{name: "r is zero", r: big.NewInt(0), s: s, want: false},
+		{name: "s is zero", r: r, s: big.NewInt(0), want: false},
+		{name: "r is n", r: s256n, s: s, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := P.Verify(z, *signature.NewSignature(tt.r, tt.s)); got != tt.want {
+				t.Errorf("Secp256k1Point.Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// NOTE: seedから秘密鍵とnonceを決めてECDSA署名を作る
+func newTestECDSASignature(seed string) (Secp256k1Point, *big.Int, signature.Signature) {
+	s256n := NewSecp256k1n()
+	secret := new(big.Int).SetBytes(utils.Hash256([]byte(seed + " secret")))
+	k := new(big.Int).SetBytes(utils.Hash256([]byte(seed + " nonce")))
+	P := Secp256k1Point{NewSecp256k1G().Multiply(secret)}
+	z := new(big.Int).SetBytes(utils.Hash256([]byte(seed + " message")))
+	r := NewSecp256k1G().Multiply(k).X()
+	r.Mod(r, s256n)
+	s := new(big.Int).Mul(secret, r)
+	s.Add(s, z)
+	s.Mul(s, new(big.Int).ModInverse(k, s256n))
+	s.Mod(s, s256n)
+	return P, z, *signature.NewSignature(r, s)
+}
+
+// NOTE: go test -bench . ./pkg/secp256k1 で curve パッケージの実装との速度差を確認できる
+func BenchmarkMultiply(b *testing.B) {
+	scalar := new(big.Int).SetBytes(utils.Hash256([]byte("benchmark scalar")))
+	G := NewSecp256k1G()
+	b.Run("curve", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			G.Point.Multiply(scalar)
+		}
+	})
+	b.Run("secp256k1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			G.Multiply(scalar)
+		}
+	})
+	b.Run("constant time", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			G.MultiplyConstantTime(scalar)
+		}
+	})
+}
+
+func BenchmarkSecp256k1Point_Verify(b *testing.B) {
+	P, z, sig := newTestECDSASignature("benchmark")
+	b.Run("curve", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s256n := NewSecp256k1n()
+			invS := new(big.Int).ModInverse(sig.S(), s256n)
+			u := new(big.Int).Mul(z, invS)
+			u.Mod(u, s256n)
+			v := new(big.Int).Mul(sig.R(), invS)
+			v.Mod(v, s256n)
+			total := NewSecp256k1G().Point.Multiply(u).Add(P.Point.Multiply(v))
+			if total.X().Cmp(sig.R()) != 0 {
+				b.Fatal("invalid signature")
+			}
+		}
+	})
+	b.Run("secp256k1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if !P.Verify(z, sig) {
+				b.Fatal("invalid signature")
+			}
+		}
+	})
+}
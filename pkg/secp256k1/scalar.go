@@ -0,0 +1,182 @@
+package secp256k1
+
+import (
+	"golang-bitcoin/pkg/utils"
+	"math/big"
+	"math/bits"
+)
+
+// NOTE: 群の位数nを64bitのリム4つ(リトルエンディアン)で表したもの
+var scalarN = [4]uint64{0xbfd25e8cd0364141, 0xbaaedce6af48a03b, 0xfffffffffffffffe, 0xffffffffffffffff}
+
+// NOTE: Montgomery乗算で使う -n^-1 mod 2^64 と R^2 mod n (R = 2^256)
+const scalarNInv = 0x4b0dff665588b13f
+
+var scalarR2 = scalarVal{0x896cf21467d7d140, 0x741496c20e7cf878, 0xe697f5e45bcd07c6, 0x9d671cd581c69bc5}
+
+// NOTE: 秘密鍵やnonceを扱うための mod n の固定長のスカラー。常に [0, n) に正規化しておく
+// NOTE: 演算は秘密の値によって分岐しない
+type scalarVal [4]uint64
+
+// NOTE: 32バイトのビッグエンディアンから読み込む。n以上の値はnを引いて正規化する
+func scalarFromBytes(b *[32]byte) scalarVal {
+	var t [5]uint64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			t[i] |= uint64(b[31-8*i-j]) << (8 * j)
+		}
+	}
+	return scalarSubN(&t)
+}
+
+// NOTE: 32バイトの固定長に詰めてから変換し、作業用のバッファは消去する
+func scalarFromBig(x *big.Int) scalarVal {
+	var b [32]byte
+	defer clear(b[:])
+	if x.Sign() < 0 || x.BitLen() > 256 {
+		reduced := new(big.Int).Mod(x, NewSecp256k1n())
+		reduced.FillBytes(b[:])
+		utils.ZeroBigInt(reduced)
+	} else {
+		x.FillBytes(b[:])
+	}
+	return scalarFromBytes(&b)
+}
+
+func (a *scalarVal) bytes() [32]byte {
+	var b [32]byte
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			b[31-8*i-j] = byte(a[i] >> (8 * j))
+		}
+	}
+	return b
+}
+
+func (a *scalarVal) big() *big.Int {
+	b := a.bytes()
+	defer clear(b[:])
+	return new(big.Int).SetBytes(b[:])
+}
+
+// NOTE: flagが1のときだけbをaにコピーする。flagは0か1
+func (a *scalarVal) cmov(b *scalarVal, flag uint64) {
+	mask := -flag
+	for i := range a {
+		a[i] ^= mask & (a[i] ^ b[i])
+	}
+}
+
+// NOTE: t[4]*2^256 + t が 2n 未満のとき、nを引くべきかを分岐せずに判断して [0, n) に収める
+func scalarSubN(t *[5]uint64) scalarVal {
+	var r scalarVal
+	var borrow uint64
+	r[0], borrow = bits.Sub64(t[0], scalarN[0], 0)
+	r[1], borrow = bits.Sub64(t[1], scalarN[1], borrow)
+	r[2], borrow = bits.Sub64(t[2], scalarN[2], borrow)
+	r[3], borrow = bits.Sub64(t[3], scalarN[3], borrow)
+	_, borrow = bits.Sub64(t[4], 0, borrow)
+	// NOTE: 引いて負になった場合は元の値を使う
+	orig := scalarVal{t[0], t[1], t[2], t[3]}
+	r.cmov(&orig, borrow)
+	return r
+}
+
+func scalarAdd(a, b *scalarVal) scalarVal {
+	var t [5]uint64
+	var c uint64
+	t[0], c = bits.Add64(a[0], b[0], 0)
+	t[1], c = bits.Add64(a[1], b[1], c)
+	t[2], c = bits.Add64(a[2], b[2], c)
+	t[3], c = bits.Add64(a[3], b[3], c)
+	t[4] = c
+	return scalarSubN(&t)
+}
+
+// NOTE: Montgomery乗算 a*b*R^-1 mod n。a, bはn未満であること
+func scalarMontMul(a, b *scalarVal) scalarVal {
+	var t [6]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[j], b[i])
+			var c uint64
+			lo, c = bits.Add64(lo, t[j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[j] = lo
+			carry = hi
+		}
+		var c uint64
+		t[4], c = bits.Add64(t[4], carry, 0)
+		t[5] = c
+
+		// NOTE: t + m*n の下位64bitが0になるmを選び、64bit右にずらす
+		m := t[0] * scalarNInv
+		hi, lo := bits.Mul64(m, scalarN[0])
+		_, c = bits.Add64(lo, t[0], 0)
+		carry = hi + c
+		for j := 1; j < 4; j++ {
+			hi, lo := bits.Mul64(m, scalarN[j])
+			lo, c = bits.Add64(lo, t[j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[j-1] = lo
+			carry = hi
+		}
+		t[3], c = bits.Add64(t[4], carry, 0)
+		t[4] = t[5] + c
+	}
+	r := [5]uint64{t[0], t[1], t[2], t[3], t[4]}
+	return scalarSubN(&r)
+}
+
+func scalarMul(a, b *scalarVal) scalarVal {
+	// NOTE: (a*b*R^-1) * R^2 * R^-1 = a*b
+	t := scalarMontMul(a, b)
+	return scalarMontMul(&t, &scalarR2)
+}
+
+// NOTE: Fermatの小定理で a^(n-2) を計算する。指数は公開値なので実行時間は一定
+func scalarInverse(a *scalarVal) scalarVal {
+	// NOTE: n-2 の各リム
+	e := [4]uint64{0xbfd25e8cd036413f, 0xbaaedce6af48a03b, 0xfffffffffffffffe, 0xffffffffffffffff}
+	// NOTE: Montgomery表現のまま累乗する。1のMontgomery表現は R mod n
+	one := scalarVal{1}
+	aMont := scalarMontMul(a, &scalarR2)
+	r := scalarMontMul(&one, &scalarR2)
+	for i := 3; i >= 0; i-- {
+		for j := 63; j >= 0; j-- {
+			r = scalarMontMul(&r, &r)
+			if (e[i]>>j)&1 == 1 {
+				r = scalarMontMul(&r, &aMont)
+			}
+		}
+	}
+	clear(aMont[:])
+	return scalarMontMul(&r, &one)
+}
+
+// NOTE: 署名のnonceの逆数を求めるときに使う定数時間の mod n の逆数。0の逆数は0を返す
+func ScalarInverseConstantTime(a *big.Int) *big.Int {
+	s := scalarFromBig(a)
+	defer clear(s[:])
+	inv := scalarInverse(&s)
+	defer clear(inv[:])
+	return inv.big()
+}
+
+// NOTE: 秘密鍵やnonceを含む a*b + c mod n を定数時間で計算する
+func ScalarMulAddConstantTime(a, b, c *big.Int) *big.Int {
+	x, y, z := scalarFromBig(a), scalarFromBig(b), scalarFromBig(c)
+	defer clear(x[:])
+	defer clear(y[:])
+	defer clear(z[:])
+	product := scalarMul(&x, &y)
+	defer clear(product[:])
+	sum := scalarAdd(&product, &z)
+	defer clear(sum[:])
+	return sum.big()
+}
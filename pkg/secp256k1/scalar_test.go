@@ -0,0 +1,124 @@
+package secp256k1
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// NOTE: big.Int で計算した結果と突き合わせる
+func scalarTestValues() []*big.Int {
+	s256n := NewSecp256k1n()
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(2),
+		new(big.Int).Sub(s256n, big.NewInt(1)),
+		new(big.Int).Sub(s256n, big.NewInt(2)),
+		new(big.Int).Rsh(s256n, 1),
+		new(big.Int).Lsh(big.NewInt(1), 255),
+		new(big.Int).Lsh(big.NewInt(1), 128),
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 16; i++ {
+		values = append(values, new(big.Int).Rand(r, s256n))
+	}
+	return values
+}
+
+func Test_scalarVal(t *testing.T) {
+	s256n := NewSecp256k1n()
+	values := scalarTestValues()
+	tests := []struct {
+		name string
+		got  func(a, b *scalarVal) scalarVal
+		want func(a, b *big.Int) *big.Int
+	}{
+		{
+			name: "add",
+			got:  scalarAdd,
+			want: func(a, b *big.Int) *big.Int { return new(big.Int).Add(a, b) },
+		},
+		{
+			name: "mul",
+			got:  scalarMul,
+			want: func(a, b *big.Int) *big.Int { return new(big.Int).Mul(a, b) },
+		},
+		{
+			name: "inverse",
+			got:  func(a, b *scalarVal) scalarVal { return scalarInverse(a) },
+			want: func(a, b *big.Int) *big.Int {
+				if a.Sign() == 0 {
+					return big.NewInt(0)
+				}
+				return new(big.Int).ModInverse(a, s256n)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, a := range values {
+				for _, b := range values {
+					sa := scalarFromBig(a)
+					sb := scalarFromBig(b)
+					got := tt.got(&sa, &sb)
+					want := tt.want(a, b)
+					want.Mod(want, s256n)
+					if got.big().Cmp(want) != 0 {
+						t.Fatalf("%s(%x, %x) = %x, want %x", tt.name, a, b, got.big(), want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// NOTE: n以上の値や負の値は mod n に還元してから使う
+func Test_scalarFromBig(t *testing.T) {
+	s256n := NewSecp256k1n()
+	tests := []struct {
+		name string
+		x    *big.Int
+		want *big.Int
+	}{
+		{name: "n", x: new(big.Int).Set(s256n), want: big.NewInt(0)},
+		{name: "n + 1", x: new(big.Int).Add(s256n, big.NewInt(1)), want: big.NewInt(1)},
+		{name: "2^256 - 1", x: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)), want: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), new(big.Int).Add(s256n, big.NewInt(1)))},
+		{name: "2^257", x: new(big.Int).Lsh(big.NewInt(1), 257), want: new(big.Int).Mod(new(big.Int).Lsh(big.NewInt(1), 257), s256n)},
+		{name: "-1", x: big.NewInt(-1), want: new(big.Int).Sub(s256n, big.NewInt(1))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := scalarFromBig(tt.x)
+			if got := s.big(); got.Cmp(tt.want) != 0 {
+				t.Errorf("scalarFromBig(%x) = %x, want %x", tt.x, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScalarMulAddConstantTime(t *testing.T) {
+	s256n := NewSecp256k1n()
+	values := scalarTestValues()
+	for _, a := range values {
+		for _, b := range values {
+			c := values[len(values)-1]
+			want := new(big.Int).Mul(a, b)
+			want.Add(want, c)
+			want.Mod(want, s256n)
+			if got := ScalarMulAddConstantTime(a, b, c); got.Cmp(want) != 0 {
+				t.Fatalf("ScalarMulAddConstantTime(%x, %x, %x) = %x, want %x", a, b, c, got, want)
+			}
+		}
+	}
+}
+
+func TestScalarInverseConstantTime(t *testing.T) {
+	s256n := NewSecp256k1n()
+	for _, a := range scalarTestValues()[1:] {
+		want := new(big.Int).ModInverse(a, s256n)
+		if got := ScalarInverseConstantTime(a); got.Cmp(want) != 0 {
+			t.Fatalf("ScalarInverseConstantTime(%x) = %x, want %x", a, got, want)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package secp256k1
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"golang-bitcoin/pkg/bech32"
+	"golang-bitcoin/pkg/chaincfg"
 	"golang-bitcoin/pkg/curve"
 	"golang-bitcoin/pkg/field"
 	"golang-bitcoin/pkg/signature"
@@ -37,15 +40,89 @@ func NewSecp256k1Point(x, y *big.Int) Secp256k1Point {
 
 func (p Secp256k1Point) Verify(z *big.Int, sig signature.Signature) bool {
 	s256n := NewSecp256k1n()
+	r := sig.R()
+	if r.Sign() <= 0 || r.Cmp(s256n) >= 0 || sig.S().Sign() <= 0 || sig.S().Cmp(s256n) >= 0 {
+		return false
+	}
 	invS := new(big.Int).ModInverse(sig.S(), s256n)
 	u := new(big.Int).Mul(z, invS)
 	u.Mod(u, s256n)
-	v := new(big.Int).Mul(sig.R(), invS)
+	v := new(big.Int).Mul(r, invS)
 	v.Mod(v, s256n)
 
-	s256G := NewSecp256k1G()
-	total := s256G.Multiply(u).Add(p.Multiply(v))
-	return total.X().Cmp(sig.R()) == 0
+	// NOTE: uG + vP をヤコビアン座標のまま求め、逆元を計算せずに x/Z^2 ≡ r (mod n) を確かめる
+	jp := jacobianFromPoint(p)
+	total := doubleScalarMult(u, v, &jp)
+	if total.inf {
+		return false
+	}
+	zz := fieldSquare(&total.z)
+	for candidate := new(big.Int).Set(r); candidate.Cmp(NewSecp256p()) < 0; candidate.Add(candidate, s256n) {
+		c := fieldFromBig(candidate)
+		c = fieldMul(&c, &zz)
+		if c.equals(&total.x) {
+			return true
+		}
+	}
+	return false
+}
+
+// NOTE: 署名とzから公開鍵を復元する。recidの下位ビットはRのyの偶奇、上位ビットはRのx座標がn以上か
+// NOTE: Q = r^-1 (sR - zG)
+func RecoverPubKey(z *big.Int, sig signature.Signature, recid byte) (Secp256k1Point, error) {
+	s256n := NewSecp256k1n()
+	r := sig.R()
+	s := sig.S()
+	if recid > 3 {
+		return Secp256k1Point{}, fmt.Errorf("invalid recovery id: %d", recid)
+	}
+	if r.Sign() <= 0 || r.Cmp(s256n) >= 0 || s.Sign() <= 0 || s.Cmp(s256n) >= 0 {
+		return Secp256k1Point{}, fmt.Errorf("signature is out of range")
+	}
+
+	x := new(big.Int).Set(r)
+	if recid&2 != 0 {
+		x.Add(x, s256n)
+	}
+	if x.Cmp(NewSecp256p()) >= 0 {
+		return Secp256k1Point{}, fmt.Errorf("r + n is not in field range")
+	}
+	marker := byte(0x02)
+	if recid&1 != 0 {
+		marker = 0x03
+	}
+	R, err := ParseSecp256k1Point(append([]byte{marker}, utils.PadTo32Bytes(x.Bytes())...))
+	if err != nil {
+		return Secp256k1Point{}, err
+	}
+
+	invR := new(big.Int).ModInverse(r, s256n)
+	u1 := new(big.Int).Mul(z, invR)
+	u1.Sub(s256n, u1.Mod(u1, s256n))
+	u2 := new(big.Int).Mul(s, invR)
+	u2.Mod(u2, s256n)
+	jR := jacobianFromPoint(R)
+	Q := doubleScalarMult(u1, u2, &jR)
+	if Q.inf {
+		return Secp256k1Point{}, fmt.Errorf("recovered public key is infinity")
+	}
+	return Q.toPoint(), nil
+}
+
+// NOTE: curve.Point.Multiply と同じ結果をヤコビアン座標とwNAFで高速に計算する
+// NOTE: 可変時間なので秘密の値を掛けるときは MultiplyConstantTime を使う
+func (p Secp256k1Point) Multiply(scalar *big.Int) curve.Point {
+	if scalar.Sign() < 0 {
+		panic("Scalar must be a positive integer")
+	}
+	var r jacobianPoint
+	if p.Equals(NewSecp256k1G().Point) {
+		r = doubleScalarMult(scalar, big.NewInt(0), &jacobianPoint{inf: true})
+	} else {
+		jp := jacobianFromPoint(p)
+		r = doubleScalarMult(big.NewInt(0), scalar, &jp)
+	}
+	return r.toPoint().Point
 }
 
 func (p Secp256k1Point) Serialize(compressed bool) []byte {
@@ -78,22 +155,44 @@ func (p Secp256k1Point) Serialize(compressed bool) []byte {
 	}
 }
 
-func ParseSecp256k1Point(serialized []byte) Secp256k1Point {
+func ParseSecp256k1Point(serialized []byte) (Secp256k1Point, error) {
+	if len(serialized) == 0 {
+		return Secp256k1Point{}, fmt.Errorf("empty public key")
+	}
 	marker := serialized[0]
+	if marker == 4 && len(serialized) != 65 {
+		return Secp256k1Point{}, fmt.Errorf("invalid uncompressed public key length")
+	}
+	if (marker == 2 || marker == 3) && len(serialized) != 33 {
+		return Secp256k1Point{}, fmt.Errorf("invalid compressed public key length")
+	}
+	if marker != 2 && marker != 3 && marker != 4 {
+		return Secp256k1Point{}, fmt.Errorf("invalid public key marker")
+	}
+	s256p := NewSecp256p()
 	x := new(big.Int).SetBytes(serialized[1:33])
+	if x.Cmp(s256p) >= 0 {
+		return Secp256k1Point{}, fmt.Errorf("x is not in field range")
+	}
+	right := new(big.Int).Exp(x, big.NewInt(3), s256p)
+	right = right.Add(right, big.NewInt(7))
+	right = right.Mod(right, s256p)
+
 	var y *big.Int
 	if marker == 4 {
 		y = new(big.Int).SetBytes(serialized[33:])
+		left := new(big.Int).Exp(y, big.NewInt(2), s256p)
+		if y.Cmp(s256p) >= 0 || left.Cmp(right) != 0 {
+			return Secp256k1Point{}, fmt.Errorf("point is not on the curve")
+		}
 	} else {
-		right := new(big.Int).Exp(x, big.NewInt(3), NewSecp256p())
-		right = right.Add(right, big.NewInt(7))
-		right = right.Mod(right, NewSecp256p())
-
-		y = new(big.Int).ModSqrt(right, NewSecp256p())
+		y = new(big.Int).ModSqrt(right, s256p)
+		if y == nil {
+			return Secp256k1Point{}, fmt.Errorf("point is not on the curve")
+		}
 
 		var even_y *big.Int
 		var odd_y *big.Int
-		s256p := NewSecp256p()
 		if y.Bit(0) == 0 {
 			even_y = y
 			odd_y = new(big.Int).Sub(s256p, y)
@@ -110,31 +209,118 @@ func ParseSecp256k1Point(serialized []byte) Secp256k1Point {
 		}
 	}
 
-	return NewSecp256k1Point(x, y)
+	return NewSecp256k1Point(x, y), nil
 }
 
-func (p Secp256k1Point) Address(compressed bool, testnet bool) string {
-	serialized := p.Serialize(compressed)
+// NOTE: BIP340 x座標のみの公開鍵。yが偶数の点を表す
+func ParseXOnlyPubKey(serialized []byte) (Secp256k1Point, error) {
+	if len(serialized) != 32 {
+		return Secp256k1Point{}, fmt.Errorf("invalid x-only public key length")
+	}
+	return ParseSecp256k1Point(append([]byte{0x02}, serialized...))
+}
 
-	serialized160 := utils.Hash160(serialized)
+func (p Secp256k1Point) SerializeXOnly() []byte {
+	return utils.PadTo32Bytes(p.X().Bytes())
+}
 
-	var prefix []byte
-	if testnet {
-		prefix = []byte{0x6f}
-	} else {
-		prefix = []byte{0x00}
+// NOTE: BIP340 Schnorr署名を検証する。公開鍵はx座標のみを使う
+func (p Secp256k1Point) VerifySchnorr(msg []byte, sig signature.SchnorrSignature) bool {
+	pubkey, e, ok := schnorrChallenge(p, msg, sig)
+	if !ok {
+		return false
+	}
+	// NOTE: R = sG - eP
+	negE := new(big.Int).Sub(NewSecp256k1n(), e)
+	jp := jacobianFromPoint(pubkey)
+	R := doubleScalarMult(sig.S(), negE, &jp)
+	if R.inf {
+		return false
+	}
+	affine := R.toAffine()
+	if affine.y.isOdd() {
+		return false
+	}
+	return affine.x.big().Cmp(sig.R()) == 0
+}
+
+// NOTE: 署名の範囲を確認し、yが偶数の公開鍵とチャレンジ e = H(r || P || m) mod n を返す
+func schnorrChallenge(p Secp256k1Point, msg []byte, sig signature.SchnorrSignature) (Secp256k1Point, *big.Int, bool) {
+	s256n := NewSecp256k1n()
+	r := sig.R()
+	if r.Cmp(NewSecp256p()) >= 0 || sig.S().Cmp(s256n) >= 0 {
+		return Secp256k1Point{}, nil, false
+	}
+	pubkey, err := ParseXOnlyPubKey(p.SerializeXOnly())
+	if err != nil {
+		return Secp256k1Point{}, nil, false
 	}
+	e := new(big.Int).SetBytes(utils.TaggedHash("BIP0340/challenge", utils.PadTo32Bytes(r.Bytes()), pubkey.SerializeXOnly(), msg))
+	e.Mod(e, s256n)
+	return pubkey, e, true
+}
 
-	joint := append(prefix, serialized160...)
+// NOTE: BIP341 内部鍵をmerkle rootでtweakした出力鍵 Q = P + H_TapTweak(P || root)G を返す
+func (p Secp256k1Point) TaprootTweak(merkleRoot []byte) (Secp256k1Point, error) {
+	internalKey, err := ParseXOnlyPubKey(p.SerializeXOnly())
+	if err != nil {
+		return Secp256k1Point{}, err
+	}
+	tweak := new(big.Int).SetBytes(utils.TaggedHash("TapTweak", internalKey.SerializeXOnly(), merkleRoot))
+	if tweak.Cmp(NewSecp256k1n()) >= 0 {
+		return Secp256k1Point{}, fmt.Errorf("tweak is out of range")
+	}
+	Q := internalKey.Add(NewSecp256k1G().Multiply(tweak))
+	if Q.IsInf() {
+		return Secp256k1Point{}, fmt.Errorf("tweaked key is infinity")
+	}
+	return Secp256k1Point{Q}, nil
+}
+
+func (p Secp256k1Point) Address(compressed bool, params *chaincfg.Params) string {
+	serialized := p.Serialize(compressed)
+
+	serialized160 := utils.Hash160(serialized)
+
+	joint := append([]byte{params.PubKeyHashAddrID}, serialized160...)
 	checksum := utils.Hash256(joint)[:4]
 
 	return base58.Encode(append(joint, checksum...))
 }
 
+// NOTE: BIP141 P2WPKHアドレス。witness v0では圧縮公開鍵しか使えない
+func (p Secp256k1Point) P2WPKHAddress(params *chaincfg.Params) (string, error) {
+	return bech32.EncodeSegwitAddress(params.Bech32HRP, 0, utils.Hash160(p.Serialize(true)))
+}
+
+// NOTE: BIP141 <pubkey> OP_CHECKSIG をwitness scriptとするP2WSHアドレス
+func (p Secp256k1Point) P2WSHAddress(params *chaincfg.Params) (string, error) {
+	witnessScript := append([]byte{33}, p.Serialize(true)...)
+	witnessScript = append(witnessScript, 0xac)
+	program := sha256.Sum256(witnessScript)
+	return bech32.EncodeSegwitAddress(params.Bech32HRP, 0, program[:])
+}
+
+// NOTE: BIP86 スクリプトパスを持たないP2TRアドレス。出力鍵は内部鍵を空のmerkle rootでtweakしたもの
+func (p Secp256k1Point) P2TRAddress(params *chaincfg.Params) (string, error) {
+	outputKey, err := p.TaprootTweak(nil)
+	if err != nil {
+		return "", err
+	}
+	return bech32.EncodeSegwitAddress(params.Bech32HRP, 1, outputKey.SerializeXOnly())
+}
+
+// NOTE: バージョンバイトは確認しないので、P2PKHかP2SHかやネットワークは呼び出し側で確認する
 func ExtractHash160(address string) ([]byte, error) {
+	_, hash160, err := DecodeBase58Address(address)
+	return hash160, err
+}
+
+// NOTE: Base58Checkのアドレスをバージョンバイトと20バイトのハッシュに分ける
+func DecodeBase58Address(address string) (byte, []byte, error) {
 	decoded := base58.Decode(address)
 	if len(decoded) != 25 {
-		return nil, fmt.Errorf("invalid address length")
+		return 0, nil, fmt.Errorf("invalid address length")
 	}
 
 	prefix := decoded[0]
@@ -144,10 +330,10 @@ func ExtractHash160(address string) ([]byte, error) {
 	joint := append([]byte{prefix}, serialized160...)
 	rawChecksum := utils.Hash256(joint)[:4]
 	if !utils.CompareBytes(checksum, rawChecksum) {
-		return nil, fmt.Errorf("invalid checksum")
+		return 0, nil, fmt.Errorf("invalid checksum")
 	}
 
-	return serialized160, nil
+	return prefix, serialized160, nil
 }
 
 func NewSecp256p() *big.Int {
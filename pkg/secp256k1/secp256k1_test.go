@@ -1,7 +1,10 @@
 package secp256k1
 
 import (
+	"encoding/hex"
+	"golang-bitcoin/pkg/chaincfg"
 	"golang-bitcoin/pkg/curve"
+	"golang-bitcoin/pkg/signature"
 	"math/big"
 	"reflect"
 	"testing"
@@ -83,6 +86,7 @@ func TestParseSecp256k1Point(t *testing.T) {
 		name          string
 		argsGenerator func() args
 		wantGenerator func() Secp256k1Point
+		wantErr       bool
 	}{
 		{
 			name: "uncompressed case 1",
@@ -120,12 +124,44 @@ func TestParseSecp256k1Point(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "compressed not on curve",
+			argsGenerator: func() args {
+				// NOTE: x = 5 の場合、x^3 + 7 は平方剰余ではない
+				serialized := make([]byte, 33)
+				serialized[0] = 0x02
+				serialized[32] = 0x05
+				return args{
+					serialized: serialized,
+				}
+			},
+			wantGenerator: func() Secp256k1Point {
+				return Secp256k1Point{}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid length",
+			argsGenerator: func() args {
+				return args{
+					serialized: []byte{0x02, 0x01},
+				}
+			},
+			wantGenerator: func() Secp256k1Point {
+				return Secp256k1Point{}
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			args := tt.argsGenerator()
 			want := tt.wantGenerator()
-			if got := ParseSecp256k1Point(args.serialized); !got.Point.Equals(want.Point) {
+			got, err := ParseSecp256k1Point(args.serialized)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSecp256k1Point() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Point.Equals(want.Point) {
 				t.Errorf("ParseSecp256k1Point() = %v, want %v", got, want)
 			}
 		})
@@ -138,7 +174,7 @@ func TestSecp256k1Point_Address(t *testing.T) {
 	}
 	type args struct {
 		compressed bool
-		testnet    bool
+		params     *chaincfg.Params
 	}
 	tests := []struct {
 		name            string
@@ -157,7 +193,7 @@ func TestSecp256k1Point_Address(t *testing.T) {
 			},
 			args: args{
 				compressed: false,
-				testnet:    true,
+				params:     &chaincfg.TestNet3Params,
 			},
 			want: "mmTPbXQFxboEtNRkwfh6K51jvdtHLxGeMA",
 		},
@@ -173,7 +209,7 @@ func TestSecp256k1Point_Address(t *testing.T) {
 			},
 			args: args{
 				compressed: true,
-				testnet:    true,
+				params:     &chaincfg.TestNet3Params,
 			},
 			want: "mopVkxp8UhXqRYbCYJsbeE1h1fiF64jcoH",
 		},
@@ -188,7 +224,7 @@ func TestSecp256k1Point_Address(t *testing.T) {
 			},
 			args: args{
 				compressed: true,
-				testnet:    false,
+				params:     &chaincfg.MainNetParams,
 			},
 			want: "1F1Pn2y6pDb68E5nYJJeba4TLg2U7B6KF1",
 		},
@@ -199,9 +235,322 @@ func TestSecp256k1Point_Address(t *testing.T) {
 			p := Secp256k1Point{
 				Point: fields.Point,
 			}
-			if got := p.Address(tt.args.compressed, tt.args.testnet); got != tt.want {
+			if got := p.Address(tt.args.compressed, tt.args.params); got != tt.want {
 				t.Errorf("Secp256k1Point.Address() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+// NOTE: BIP173 の例 (公開鍵はG) と BIP86 の最初の受け取りアドレス
+func TestSecp256k1Point_SegwitAddress(t *testing.T) {
+	internalKeyBytes, _ := hex.DecodeString("cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115")
+	bip86InternalKey, err := ParseXOnlyPubKey(internalKeyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		point   Secp256k1Point
+		address func(p Secp256k1Point, params *chaincfg.Params) (string, error)
+		params  *chaincfg.Params
+		want    string
+	}{
+		{
+			name:    "mainnet p2wpkh",
+			point:   NewSecp256k1G(),
+			address: Secp256k1Point.P2WPKHAddress,
+			params:  &chaincfg.MainNetParams,
+			want:    "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		},
+		{
+			name:    "testnet p2wpkh",
+			point:   NewSecp256k1G(),
+			address: Secp256k1Point.P2WPKHAddress,
+			params:  &chaincfg.TestNet3Params,
+			want:    "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx",
+		},
+		{
+			name:    "regtest p2wpkh",
+			point:   NewSecp256k1G(),
+			address: Secp256k1Point.P2WPKHAddress,
+			params:  &chaincfg.RegressionNetParams,
+			want:    "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080",
+		},
+		{
+			name:    "mainnet p2wsh",
+			point:   NewSecp256k1G(),
+			address: Secp256k1Point.P2WSHAddress,
+			params:  &chaincfg.MainNetParams,
+			want:    "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3",
+		},
+		{
+			name:    "testnet p2wsh",
+			point:   NewSecp256k1G(),
+			address: Secp256k1Point.P2WSHAddress,
+			params:  &chaincfg.TestNet3Params,
+			want:    "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7",
+		},
+		{
+			name:    "mainnet p2tr",
+			point:   bip86InternalKey,
+			address: Secp256k1Point.P2TRAddress,
+			params:  &chaincfg.MainNetParams,
+			want:    "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.address(tt.point, tt.params)
+			if err != nil {
+				t.Fatalf("address error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("address = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// NOTE: BIP340 test-vectors.csv
+func TestSecp256k1Point_VerifySchnorr(t *testing.T) {
+	tests := []struct {
+		name   string
+		pubkey string
+		msg    string
+		sig    string
+		want   bool
+	}{
+		{
+			name:   "vector 0",
+			pubkey: "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+			msg:    "0000000000000000000000000000000000000000000000000000000000000000",
+			sig:    "e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca821525f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0",
+			want:   true,
+		},
+		{
+			name:   "vector 1",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de33418906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a",
+			want:   true,
+		},
+		{
+			name:   "vector 2",
+			pubkey: "dd308afec5777e13121fa72b9cc1b7cc0139715309b086c960e18fd969774eb8",
+			msg:    "7e2d58d8b3bcdf1abadec7829054f90dda9805aab56c77333024b9d0a508b75c",
+			sig:    "5831aaeed7b44bb74e5eab94ba9d4294c49bcf2a60728d8b4c200f50dd313c1bab745879a5ad954a72c45a91c3a51d3c7adea98d82f8481e0e1e03674a6f3fb7",
+			want:   true,
+		},
+		{
+			name:   "vector 3",
+			pubkey: "25d1dff95105f5253c4022f628a996ad3a0d95fbf21d468a1b33f8c160d8f517",
+			msg:    "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			sig:    "7eb0509757e246f19449885651611cb965ecc1a187dd51b64fda1edc9637d5ec97582b9cb13db3933705b32ba982af5af25fd78881ebb32771fc5922efc66ea3",
+			want:   true,
+		},
+		{
+			name:   "vector 4",
+			pubkey: "d69c3509bb99e412e68b0fe8544e72837dfa30746d8be2aa65975f29d22dc7b9",
+			msg:    "4df3c3f68fcc83b27e9d42c90431a72499f17875c81a599b566c9889b9696703",
+			sig:    "00000000000000000000003b78ce563f89a0ed9414f5aa28ad0d96d6795f9c6376afb1548af603b3eb45c9f8207dee1060cb71c04e80f593060b07d28308d7f4",
+			want:   true,
+		},
+		{
+			name:   "public key not on the curve",
+			pubkey: "eefdea4cdb677750a420fee807eacf21eb9898ae79b9768766e4faa04a2d4a34",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "6cff5c3ba86c69ea4b7376f31a9bcb4f74c1976089b2d9963da2e5543e17776969e89b4c5564d00349106b8497785dd7d1d713a8ae82b32fa79d5f7fc407d39b",
+			want:   false,
+		},
+		{
+			name:   "has_even_y(R) is false",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a14602975563cc27944640ac607cd107ae10923d9ef7a73c643e166be5ebeafa34b1ac553e2",
+			want:   false,
+		},
+		{
+			name:   "negated message",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "1fa62e331edbc21c394792d2ab1100a7b432b013df3f6ff4f99fcb33e0e1515f28890b3edb6e7189b630448b515ce4f8622a954cfe545735aaea5134fccdb2bd",
+			want:   false,
+		},
+		{
+			name:   "negated s value",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "6cff5c3ba86c69ea4b7376f31a9bcb4f74c1976089b2d9963da2e5543e177769961764b3aa9b2ffcb6ef947b6887a226e8d7c93e00c5ed0c1834ff0d0c2e6da6",
+			want:   false,
+		},
+		{
+			name:   "sG - eP is infinite (e = 0)",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "0000000000000000000000000000000000000000000000000000000000000000123dda8328af9c23a94c1feecfd123ba4fb73476f0d594dcb65c6425bd186051",
+			want:   false,
+		},
+		{
+			name:   "sG - eP is infinite (e = 1)",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "00000000000000000000000000000000000000000000000000000000000000017615fbaf5ae28864013c099742deadb4dba87f11ac6754f93780d5a1837cf197",
+			want:   false,
+		},
+		{
+			name:   "sig[0:32] is not an X coordinate on the curve",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "4a298dacae57395a15d0795ddbfd1dcb564da82b0f269bc70a74f8220429ba1d69e89b4c5564d00349106b8497785dd7d1d713a8ae82b32fa79d5f7fc407d39b",
+			want:   false,
+		},
+		{
+			name:   "sig[0:32] is equal to field size",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f69e89b4c5564d00349106b8497785dd7d1d713a8ae82b32fa79d5f7fc407d39b",
+			want:   false,
+		},
+		{
+			name:   "sig[32:64] is equal to curve order",
+			pubkey: "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "6cff5c3ba86c69ea4b7376f31a9bcb4f74c1976089b2d9963da2e5543e177769fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141",
+			want:   false,
+		},
+		{
+			name:   "public key exceeds field size",
+			pubkey: "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc30",
+			msg:    "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			sig:    "6cff5c3ba86c69ea4b7376f31a9bcb4f74c1976089b2d9963da2e5543e17776969e89b4c5564d00349106b8497785dd7d1d713a8ae82b32fa79d5f7fc407d39b",
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pubkeyBytes, _ := hex.DecodeString(tt.pubkey)
+			msg, _ := hex.DecodeString(tt.msg)
+			serializedSig, _ := hex.DecodeString(tt.sig)
+			sig, _ := signature.ParseSchnorrSignature(serializedSig)
+			pubkey, err := ParseXOnlyPubKey(pubkeyBytes)
+			if err != nil {
+				// NOTE: 不正な公開鍵は検証失敗として扱う
+				if tt.want {
+					t.Fatalf("ParseXOnlyPubKey() error = %v", err)
+				}
+				return
+			}
+			if got := pubkey.VerifySchnorr(msg, *sig); got != tt.want {
+				t.Errorf("Secp256k1Point.VerifySchnorr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// NOTE: BIP341 wallet-test-vectors.json scriptPubKey
+func TestSecp256k1Point_TaprootTweak(t *testing.T) {
+	tests := []struct {
+		name        string
+		internalKey string
+		merkleRoot  string
+		want        string
+		wantOddY    bool
+	}{
+		{
+			name:        "key path only",
+			internalKey: "d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d",
+			merkleRoot:  "",
+			want:        "53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343",
+			wantOddY:    true,
+		},
+		{
+			name:        "single leaf",
+			internalKey: "187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+			merkleRoot:  "5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21",
+			want:        "147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+			wantOddY:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			internalKeyBytes, _ := hex.DecodeString(tt.internalKey)
+			merkleRoot, _ := hex.DecodeString(tt.merkleRoot)
+			internalKey, err := ParseXOnlyPubKey(internalKeyBytes)
+			if err != nil {
+				t.Fatalf("ParseXOnlyPubKey() error = %v", err)
+			}
+			got, err := internalKey.TaprootTweak(merkleRoot)
+			if err != nil {
+				t.Fatalf("Secp256k1Point.TaprootTweak() error = %v", err)
+			}
+			if hex.EncodeToString(got.SerializeXOnly()) != tt.want {
+				t.Errorf("Secp256k1Point.TaprootTweak() = %x, want %v", got.SerializeXOnly(), tt.want)
+			}
+			if (got.Y().Bit(0) == 1) != tt.wantOddY {
+				t.Errorf("Secp256k1Point.TaprootTweak() odd y = %v, want %v", got.Y().Bit(0) == 1, tt.wantOddY)
+			}
+		})
+	}
+}
+
+func TestRecoverPubKey(t *testing.T) {
+	P, z, sig := newTestECDSASignature("recover")
+	s256n := NewSecp256k1n()
+	tests := []struct {
+		name    string
+		z       *big.Int
+		sig     signature.Signature
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			z:    z,
+			sig:  sig,
+		},
+		{
+			name: "high s",
+			z:    z,
+			sig:  *signature.NewSignature(sig.R(), new(big.Int).Sub(s256n, sig.S())),
+		},
+		{
+			name:    "r is zero",
+			z:       z,
+			sig:     *signature.NewSignature(big.NewInt(0), sig.S()),
+			wantErr: true,
+		},
+		{
+			name:    "s is n",
+			z:       z,
+			sig:     *signature.NewSignature(sig.R(), s256n),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// NOTE: 正しいrecidは1つだけで、ほかのrecidでは別の公開鍵になるかエラーになる
+			found := 0
+			for recid := byte(0); recid < 4; recid++ {
+				got, err := RecoverPubKey(tt.z, tt.sig, recid)
+				if err != nil {
+					continue
+				}
+				if got.Equals(P.Point) {
+					found++
+				}
+				if !got.Verify(tt.z, tt.sig) {
+					t.Errorf("RecoverPubKey() = %x does not verify the signature", got.Serialize(true))
+				}
+			}
+			if (found == 0) != tt.wantErr {
+				t.Errorf("RecoverPubKey() found %d keys, wantErr %v", found, tt.wantErr)
+			}
+			if !tt.wantErr && found != 1 {
+				t.Errorf("RecoverPubKey() found %d keys, want 1", found)
+			}
+		})
+	}
+	if _, err := RecoverPubKey(z, sig, 4); err == nil {
+		t.Errorf("RecoverPubKey() with recid 4 error = nil, want error")
+	}
+}
@@ -2,9 +2,16 @@ package signature
 
 import (
 	"fmt"
+	"golang-bitcoin/pkg/utils"
 	"math/big"
 )
 
+var (
+	// NOTE: secp256k1 パッケージはこのパッケージに依存しているので、位数をここでも持っておく
+	secp256k1n, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	secp256k1nHalf = new(big.Int).Rsh(secp256k1n, 1)
+)
+
 type Signature struct {
 	r, s *big.Int
 }
@@ -27,33 +34,46 @@ func (s *Signature) Equals(other *Signature) bool {
 
 func (s *Signature) Serialize() []byte {
 	marker := []byte{0x30}
-	rbin := s.r.Bytes()
-	for len(rbin) > 0 && rbin[0] == 0 {
-		rbin = rbin[1:]
-	}
-	if rbin[0]&0x80 != 0 {
-		rbin = append([]byte{0x00}, rbin...)
-	}
-	rbin = append([]byte{0x02, byte(len(rbin))}, rbin...)
-
-	sbin := s.s.Bytes()
-	for len(sbin) > 0 && sbin[0] == 0 {
-		sbin = sbin[1:]
-	}
-	if sbin[0]&0x80 != 0 {
-		sbin = append([]byte{0x00}, sbin...)
-	}
-	sbin = append([]byte{0x02, byte(len(sbin))}, sbin...)
+	rbin := serializeDERInteger(s.r)
+	sbin := serializeDERInteger(s.s)
 
 	remainLen := len(rbin) + len(sbin)
 
 	return append(append(append(marker, byte(remainLen)), rbin...), sbin...)
 }
 
+// NOTE: 先頭の0を取り除き、最上位ビットが立っている場合は負数と区別するため0x00を付ける
+func serializeDERInteger(n *big.Int) []byte {
+	bin := n.Bytes()
+	if len(bin) == 0 || bin[0]&0x80 != 0 {
+		bin = append([]byte{0x00}, bin...)
+	}
+	return append([]byte{0x02, byte(len(bin))}, bin...)
+}
+
+// NOTE: s が n/2 以下か。BIP62 ではsが大きい署名は展性があるので標準ではない
+func (s *Signature) IsLowS() bool {
+	return s.s.Cmp(secp256k1nHalf) <= 0
+}
+
+// NOTE: s が n/2 より大きい場合は n - s に置き換えた署名を返す。検証結果は変わらない
+func (s *Signature) NormalizeS() *Signature {
+	if s.IsLowS() {
+		return NewSignature(s.r, s.s)
+	}
+	return NewSignature(s.r, new(big.Int).Sub(secp256k1n, s.s))
+}
+
+// NOTE: BIP66 の厳格なDERとしてパースする。署名の末尾のハッシュタイプは含めない
+// NOTE: 0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S]
 func ParseSignature(signature []byte) (*Signature, error) {
-	if len(signature) < 6 {
+	// NOTE: 最短は r, s がともに1バイトの場合、最長は33バイトの場合
+	if len(signature) < 8 {
 		return nil, fmt.Errorf("signature is too short")
 	}
+	if len(signature) > 72 {
+		return nil, fmt.Errorf("signature is too long")
+	}
 
 	if signature[0] != 0x30 {
 		return nil, fmt.Errorf("invalid der marker")
@@ -63,26 +83,197 @@ func ParseSignature(signature []byte) (*Signature, error) {
 		return nil, fmt.Errorf("invalid der length: %d != %d", signature[1], len(signature)-2)
 	}
 
-	if signature[2] != 0x02 {
-		return nil, fmt.Errorf("invalud r marker")
-	}
-
 	rLen := int(signature[3])
-	if rLen == 0 || rLen > 33 {
+	// NOTE: sのマーカーと長さを読めること
+	if 5+rLen >= len(signature) {
 		return nil, fmt.Errorf("invalid r length")
 	}
+	sLen := int(signature[5+rLen])
+	if rLen+sLen+6 != len(signature) {
+		return nil, fmt.Errorf("invalid s length")
+	}
 
-	if int(signature[4+rLen]) != 0x02 {
-		return nil, fmt.Errorf("invalid s marker")
+	rbin := signature[4 : 4+rLen]
+	if err := checkDERInteger(signature[2], rbin); err != nil {
+		return nil, fmt.Errorf("invalid r: %w", err)
 	}
+	sbin := signature[6+rLen:]
+	if err := checkDERInteger(signature[4+rLen], sbin); err != nil {
+		return nil, fmt.Errorf("invalid s: %w", err)
+	}
+
+	r := new(big.Int).SetBytes(rbin)
+	s := new(big.Int).SetBytes(sbin)
+
+	return NewSignature(r, s), nil
+}
+
+func checkDERInteger(marker byte, bin []byte) error {
+	if marker != 0x02 {
+		return fmt.Errorf("invalid marker")
+	}
+	if len(bin) == 0 {
+		return fmt.Errorf("zero length")
+	}
+	if bin[0]&0x80 != 0 {
+		return fmt.Errorf("negative value")
+	}
+	// NOTE: 負数と区別するために必要な場合以外は先頭に0を付けてはいけない
+	if len(bin) > 1 && bin[0] == 0x00 && bin[1]&0x80 == 0 {
+		return fmt.Errorf("excessive padding")
+	}
+	return nil
+}
 
-	sLen := int(signature[3+rLen+3])
-	if sLen == 0 || sLen > 33 {
-		return nil, fmt.Errorf("invalid s lemgth")
+// NOTE: BIP66 以前のOpenSSLと同じ緩いDERとしてパースする (Bitcoin Coreの ecdsa_signature_parse_der_lax)
+// NOTE: 長さの不一致、長形式の長さ、余分な0、負数、末尾のゴミを許容する
+// NOTE: r, s が範囲外の場合はエラーにせず、検証に必ず失敗する r = s = 0 の署名を返す
+func ParseSignatureLax(signature []byte) (*Signature, error) {
+	pos := 0
+	if pos == len(signature) || signature[pos] != 0x30 {
+		return nil, fmt.Errorf("invalid der marker")
 	}
+	pos++
 
-	r := new(big.Int).SetBytes(signature[4 : 4+rLen])
-	s := new(big.Int).SetBytes(signature[4+rLen+2:])
+	// NOTE: 全体の長さは読み飛ばす
+	if pos == len(signature) {
+		return nil, fmt.Errorf("signature is too short")
+	}
+	lenByte := int(signature[pos])
+	pos++
+	if lenByte&0x80 != 0 {
+		lenByte -= 0x80
+		if lenByte > len(signature)-pos {
+			return nil, fmt.Errorf("invalid der length")
+		}
+		pos += lenByte
+	}
+
+	rbin, pos, err := parseLaxDERInteger(signature, pos)
+	if err != nil {
+		return nil, fmt.Errorf("invalid r: %w", err)
+	}
+	sbin, _, err := parseLaxDERInteger(signature, pos)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s: %w", err)
+	}
 
+	r := new(big.Int).SetBytes(rbin)
+	s := new(big.Int).SetBytes(sbin)
+	if len(rbin) > 32 || len(sbin) > 32 || r.Cmp(secp256k1n) >= 0 || s.Cmp(secp256k1n) >= 0 {
+		return NewSignature(big.NewInt(0), big.NewInt(0)), nil
+	}
 	return NewSignature(r, s), nil
 }
+
+// NOTE: posから整数を読み、先頭の0を取り除いた値と次の位置を返す
+func parseLaxDERInteger(signature []byte, pos int) ([]byte, int, error) {
+	if pos == len(signature) || signature[pos] != 0x02 {
+		return nil, 0, fmt.Errorf("invalid marker")
+	}
+	pos++
+
+	if pos == len(signature) {
+		return nil, 0, fmt.Errorf("missing length")
+	}
+	length := int(signature[pos])
+	pos++
+	if length&0x80 != 0 {
+		lenBytes := length - 0x80
+		if lenBytes > len(signature)-pos {
+			return nil, 0, fmt.Errorf("invalid length")
+		}
+		for lenBytes > 0 && signature[pos] == 0 {
+			pos++
+			lenBytes--
+		}
+		if lenBytes >= 4 {
+			return nil, 0, fmt.Errorf("length is too long")
+		}
+		length = 0
+		for ; lenBytes > 0; lenBytes-- {
+			length = length<<8 + int(signature[pos])
+			pos++
+		}
+	}
+	if length > len(signature)-pos {
+		return nil, 0, fmt.Errorf("invalid length")
+	}
+
+	bin := signature[pos : pos+length]
+	for len(bin) > 0 && bin[0] == 0 {
+		bin = bin[1:]
+	}
+	return bin, pos + length, nil
+}
+
+// NOTE: 65バイトの復元可能な署名 header || r || s
+// NOTE: header = 27 + recid (+4 公開鍵が圧縮形式の場合)
+func (s *Signature) SerializeCompact(recid byte, compressed bool) []byte {
+	header := 27 + recid
+	if compressed {
+		header += 4
+	}
+	serialized := make([]byte, 0, 65)
+	serialized = append(serialized, header)
+	serialized = append(serialized, utils.PadTo32Bytes(s.r.Bytes())...)
+	serialized = append(serialized, utils.PadTo32Bytes(s.s.Bytes())...)
+	return serialized
+}
+
+// NOTE: 復元可能な署名をパースし、署名、recid、公開鍵が圧縮形式かを返す
+func ParseCompactSignature(signature []byte) (*Signature, byte, bool, error) {
+	if len(signature) != 65 {
+		return nil, 0, false, fmt.Errorf("invalid compact signature length: %d", len(signature))
+	}
+	header := signature[0]
+	if header < 27 || header > 34 {
+		return nil, 0, false, fmt.Errorf("invalid compact signature header: %d", header)
+	}
+	recid := (header - 27) & 3
+	compressed := header >= 31
+	r := new(big.Int).SetBytes(signature[1:33])
+	s := new(big.Int).SetBytes(signature[33:])
+	if r.Sign() == 0 || r.Cmp(secp256k1n) >= 0 || s.Sign() == 0 || s.Cmp(secp256k1n) >= 0 {
+		return nil, 0, false, fmt.Errorf("compact signature is out of range")
+	}
+	return NewSignature(r, s), recid, compressed, nil
+}
+
+// NOTE: BIP340 Schnorr署名。rはRのx座標
+type SchnorrSignature struct {
+	r, s *big.Int
+}
+
+func NewSchnorrSignature(r, s *big.Int) *SchnorrSignature {
+	return &SchnorrSignature{r, s}
+}
+
+func (s *SchnorrSignature) R() *big.Int {
+	return s.r
+}
+
+func (s *SchnorrSignature) S() *big.Int {
+	return s.s
+}
+
+func (s *SchnorrSignature) Equals(other *SchnorrSignature) bool {
+	return s.r.Cmp(other.r) == 0 && s.s.Cmp(other.s) == 0
+}
+
+// NOTE: 32バイトのr || 32バイトのs
+func (s *SchnorrSignature) Serialize() []byte {
+	serialized := make([]byte, 0, 64)
+	serialized = append(serialized, utils.PadTo32Bytes(s.r.Bytes())...)
+	serialized = append(serialized, utils.PadTo32Bytes(s.s.Bytes())...)
+	return serialized
+}
+
+func ParseSchnorrSignature(signature []byte) (*SchnorrSignature, error) {
+	if len(signature) != 64 {
+		return nil, fmt.Errorf("invalid schnorr signature length: %d", len(signature))
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	return NewSchnorrSignature(r, s), nil
+}
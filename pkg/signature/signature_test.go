@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -47,3 +48,354 @@ func TestSignature_Serialize(t *testing.T) {
 		})
 	}
 }
+
+const validDERSignature = "3045022037206a0610995c58074999cb9767b87af4c4978db68c06e8e6e81d282047a7c60221008ca63759c1157ebeaec0d03cecca119fc9a75bf8e6d0fa65c841c8e2738cdaec"
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		sig     string
+		wantErr bool
+		wantR   string
+		wantS   string
+	}{
+		{
+			name:  "valid",
+			sig:   validDERSignature,
+			wantR: "37206a0610995c58074999cb9767b87af4c4978db68c06e8e6e81d282047a7c6",
+			wantS: "8ca63759c1157ebeaec0d03cecca119fc9a75bf8e6d0fa65c841c8e2738cdaec",
+		},
+		{
+			name:  "one byte values",
+			sig:   "3006020101020101",
+			wantR: "1",
+			wantS: "1",
+		},
+		{
+			name:    "too short",
+			sig:     "30050201010201",
+			wantErr: true,
+		},
+		{
+			name:    "invalid der marker",
+			sig:     "31" + validDERSignature[2:],
+			wantErr: true,
+		},
+		{
+			name:    "invalid der length",
+			sig:     "3046" + validDERSignature[4:],
+			wantErr: true,
+		},
+		{
+			name:    "trailing garbage",
+			sig:     "3047" + validDERSignature[4:] + "00",
+			wantErr: true,
+		},
+		{
+			name:    "r length overflows",
+			sig:     "3006020901020101",
+			wantErr: true,
+		},
+		{
+			name:    "s length mismatch",
+			sig:     "3006020101020201",
+			wantErr: true,
+		},
+		{
+			name:    "zero length r",
+			sig:     "3006020002020101",
+			wantErr: true,
+		},
+		{
+			name:    "negative r",
+			sig:     "3006020181020101",
+			wantErr: true,
+		},
+		{
+			name:    "excessive padding r",
+			sig:     "300702020001020101",
+			wantErr: true,
+		},
+		{
+			name:    "invalid s marker",
+			sig:     "3006020101030101",
+			wantErr: true,
+		},
+		{
+			name:    "negative s",
+			sig:     "3006020101020181",
+			wantErr: true,
+		},
+		{
+			name:    "excessive padding s",
+			sig:     "300702010102020001",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, _ := hex.DecodeString(tt.sig)
+			got, err := ParseSignature(sig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			wantR, _ := new(big.Int).SetString(tt.wantR, 16)
+			wantS, _ := new(big.Int).SetString(tt.wantS, 16)
+			if !got.Equals(NewSignature(wantR, wantS)) {
+				t.Errorf("ParseSignature() = (%x, %x), want (%x, %x)", got.R(), got.S(), wantR, wantS)
+			}
+		})
+	}
+}
+
+func TestParseSignatureLax(t *testing.T) {
+	tests := []struct {
+		name    string
+		sig     string
+		wantErr bool
+		wantR   string
+		wantS   string
+	}{
+		{
+			name:  "valid",
+			sig:   validDERSignature,
+			wantR: "37206a0610995c58074999cb9767b87af4c4978db68c06e8e6e81d282047a7c6",
+			wantS: "8ca63759c1157ebeaec0d03cecca119fc9a75bf8e6d0fa65c841c8e2738cdaec",
+		},
+		{
+			name:  "invalid der length",
+			sig:   "3046" + validDERSignature[4:],
+			wantR: "37206a0610995c58074999cb9767b87af4c4978db68c06e8e6e81d282047a7c6",
+			wantS: "8ca63759c1157ebeaec0d03cecca119fc9a75bf8e6d0fa65c841c8e2738cdaec",
+		},
+		{
+			name:  "trailing garbage",
+			sig:   validDERSignature + "0000",
+			wantR: "37206a0610995c58074999cb9767b87af4c4978db68c06e8e6e81d282047a7c6",
+			wantS: "8ca63759c1157ebeaec0d03cecca119fc9a75bf8e6d0fa65c841c8e2738cdaec",
+		},
+		{
+			name:  "negative and padded values",
+			sig:   "3009020181020400000001",
+			wantR: "81",
+			wantS: "1",
+		},
+		{
+			name:  "long form lengths",
+			sig:   "3081080281010102820001" + "01",
+			wantR: "1",
+			wantS: "1",
+		},
+		{
+			name:  "r is out of range",
+			sig:   "3026022100fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141020101",
+			wantR: "0",
+			wantS: "0",
+		},
+		{
+			name:    "invalid der marker",
+			sig:     "31" + validDERSignature[2:],
+			wantErr: true,
+		},
+		{
+			name:    "r length overflows",
+			sig:     "3006020901020101",
+			wantErr: true,
+		},
+		{
+			name:    "missing s",
+			sig:     "3003020101",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, _ := hex.DecodeString(tt.sig)
+			got, err := ParseSignatureLax(sig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSignatureLax() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			wantR, _ := new(big.Int).SetString(tt.wantR, 16)
+			wantS, _ := new(big.Int).SetString(tt.wantS, 16)
+			if !got.Equals(NewSignature(wantR, wantS)) {
+				t.Errorf("ParseSignatureLax() = (%x, %x), want (%x, %x)", got.R(), got.S(), wantR, wantS)
+			}
+		})
+	}
+}
+
+func TestSignature_NormalizeS(t *testing.T) {
+	r := big.NewInt(1)
+	tests := []struct {
+		name     string
+		s        *big.Int
+		wantLowS bool
+		want     *big.Int
+	}{
+		{
+			name:     "low s",
+			s:        big.NewInt(1),
+			wantLowS: true,
+			want:     big.NewInt(1),
+		},
+		{
+			name:     "n/2",
+			s:        secp256k1nHalf,
+			wantLowS: true,
+			want:     secp256k1nHalf,
+		},
+		{
+			name:     "n/2 + 1",
+			s:        new(big.Int).Add(secp256k1nHalf, big.NewInt(1)),
+			wantLowS: false,
+			want:     secp256k1nHalf,
+		},
+		{
+			name:     "n - 1",
+			s:        new(big.Int).Sub(secp256k1n, big.NewInt(1)),
+			wantLowS: false,
+			want:     big.NewInt(1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := NewSignature(r, tt.s)
+			if got := sig.IsLowS(); got != tt.wantLowS {
+				t.Errorf("Signature.IsLowS() = %v, want %v", got, tt.wantLowS)
+			}
+			got := sig.NormalizeS()
+			if got.S().Cmp(tt.want) != 0 || !got.IsLowS() {
+				t.Errorf("Signature.NormalizeS() = %x, want %x", got.S(), tt.want)
+			}
+		})
+	}
+}
+
+func fuzzSeeds(f *testing.F) {
+	for _, seed := range []string{
+		validDERSignature,
+		"3006020101020101",
+		"3006020100020100",
+		"3081080281010102820001",
+		"3009020181020400000001",
+		"30",
+		"",
+	} {
+		b, _ := hex.DecodeString(seed)
+		f.Add(b)
+	}
+}
+
+// NOTE: 厳格なDERでパースできた署名は、シリアライズすると元のバイト列に戻る
+func FuzzParseSignature(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sig, err := ParseSignature(data)
+		if err != nil {
+			return
+		}
+		if got := sig.Serialize(); !reflect.DeepEqual(got, data) {
+			t.Errorf("Signature.Serialize() = %x, want %x", got, data)
+		}
+		lax, err := ParseSignatureLax(data)
+		if err != nil {
+			t.Fatalf("ParseSignatureLax() error = %v", err)
+		}
+		if sig.R().Cmp(secp256k1n) < 0 && sig.S().Cmp(secp256k1n) < 0 && !lax.Equals(sig) {
+			t.Errorf("ParseSignatureLax() = (%x, %x), want (%x, %x)", lax.R(), lax.S(), sig.R(), sig.S())
+		}
+	})
+}
+
+func FuzzParseSignatureLax(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sig, err := ParseSignatureLax(data)
+		if err != nil {
+			return
+		}
+		if sig.R().Cmp(secp256k1n) >= 0 || sig.S().Cmp(secp256k1n) >= 0 {
+			t.Errorf("ParseSignatureLax() = (%x, %x) is out of range", sig.R(), sig.S())
+		}
+	})
+}
+
+func TestParseCompactSignature(t *testing.T) {
+	r := "37206a0610995c58074999cb9767b87af4c4978db68c06e8e6e81d282047a7c6"
+	s := "0ca63759c1157ebeaec0d03cecca119fc9a75bf8e6d0fa65c841c8e2738cdaec"
+	tests := []struct {
+		name           string
+		sig            string
+		wantErr        bool
+		wantRecid      byte
+		wantCompressed bool
+	}{
+		{
+			name:           "uncompressed recid 0",
+			sig:            "1b" + r + s,
+			wantRecid:      0,
+			wantCompressed: false,
+		},
+		{
+			name:           "compressed recid 1",
+			sig:            "20" + r + s,
+			wantRecid:      1,
+			wantCompressed: true,
+		},
+		{
+			name:           "compressed recid 3",
+			sig:            "22" + r + s,
+			wantRecid:      3,
+			wantCompressed: true,
+		},
+		{
+			name:    "header too small",
+			sig:     "1a" + r + s,
+			wantErr: true,
+		},
+		{
+			name:    "header too large",
+			sig:     "23" + r + s,
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			sig:     "1b" + r,
+			wantErr: true,
+		},
+		{
+			name:    "s is zero",
+			sig:     "1b" + r + strings.Repeat("00", 32),
+			wantErr: true,
+		},
+		{
+			name:    "r is n",
+			sig:     "1b" + "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141" + s,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := hex.DecodeString(tt.sig)
+			sig, recid, compressed, err := ParseCompactSignature(b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCompactSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if recid != tt.wantRecid || compressed != tt.wantCompressed {
+				t.Errorf("ParseCompactSignature() = %d, %v, want %d, %v", recid, compressed, tt.wantRecid, tt.wantCompressed)
+			}
+			if got := sig.SerializeCompact(recid, compressed); !reflect.DeepEqual(got, b) {
+				t.Errorf("Signature.SerializeCompact() = %x, want %x", got, b)
+			}
+		})
+	}
+}
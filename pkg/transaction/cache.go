@@ -0,0 +1,167 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheFileExt = ".hex"
+
+// NOTE: dir/<txid>.hex に生のトランザクションを保存し、maxBytesを超えた分は最終アクセスが古いものから削除する
+type DiskCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+}
+
+// NOTE: maxBytesが0以下の場合はサイズを制限しない
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir, maxBytes: maxBytes}, nil
+}
+
+func (c *DiskCache) Get(txid string) (*Transaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path, err := c.path(txid)
+	if err != nil {
+		return nil, false
+	}
+	tx, err := loadTransactionFile(path, txid)
+	if err != nil {
+		// NOTE: 壊れたエントリは削除して、キャッシュミスとして扱う
+		if !os.IsNotExist(err) {
+			os.Remove(path)
+		}
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return tx, true
+}
+
+func (c *DiskCache) Put(tx *Transaction) error {
+	txid, err := tx.ID()
+	if err != nil {
+		return err
+	}
+	serialized, err := tx.Serialize()
+	if err != nil {
+		return err
+	}
+
+	path, err := c.path(txid)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// NOTE: 書き込み途中のファイルを読まないように一時ファイルからrenameする
+	tmp, err := os.CreateTemp(c.dir, txid+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(hex.EncodeToString(serialized)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return c.evict()
+}
+
+func (c *DiskCache) evict() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), cacheFileExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, info := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= info.Size()
+	}
+	return nil
+}
+
+func (c *DiskCache) path(txid string) (string, error) {
+	if err := validateTxid(txid); err != nil {
+		return "", err
+	}
+	return filepath.Join(c.dir, txid+cacheFileExt), nil
+}
+
+// NOTE: txidをファイル名に使うので、ディレクトリの外を指さないように64文字の小文字の16進数だけを受け付ける
+func validateTxid(txid string) error {
+	if len(txid) != 64 {
+		return fmt.Errorf("invalid txid: %q", txid)
+	}
+	for i := 0; i < len(txid); i++ {
+		c := txid[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return fmt.Errorf("invalid txid: %q", txid)
+		}
+	}
+	return nil
+}
+
+// NOTE: 読み込んだトランザクションのIDを再計算して内容を検証する
+func loadTransactionFile(path, txid string) (*Transaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := ParseTransaction(hex.NewDecoder(strings.NewReader(strings.TrimSpace(string(data)))))
+	if err != nil {
+		return nil, err
+	}
+	actualTxid, err := tx.ID()
+	if err != nil {
+		return nil, err
+	}
+	if actualTxid != txid {
+		return nil, fmt.Errorf("stored transaction id does not match expected: %s != %s", actualTxid, txid)
+	}
+	return tx, nil
+}
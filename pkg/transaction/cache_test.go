@@ -0,0 +1,180 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func parseTxHex(txHex string) *Transaction {
+	raw, _ := hex.DecodeString(txHex)
+	tx, _ := ParseTransaction(bytes.NewReader(raw))
+	return tx
+}
+
+func TestDiskCache_Get(t *testing.T) {
+	legacyTx := parseTxHex(legacyTxHex)
+	legacyTxid, _ := legacyTx.ID()
+	segwitTx := parseTxHex(segwitTxHex)
+	segwitTxid, _ := segwitTx.ID()
+	nonMinimalTx := parseTxHex(nonMinimalPushTxHex)
+	nonMinimalTxid, _ := nonMinimalTx.ID()
+
+	tests := []struct {
+		name      string
+		prepare   func(c *DiskCache, dir string)
+		txid      string
+		wantFound bool
+		wantFile  bool
+	}{
+		{
+			name: "stored segwit transaction",
+			prepare: func(c *DiskCache, dir string) {
+				c.Put(segwitTx)
+			},
+			txid:      segwitTxid,
+			wantFound: true,
+			wantFile:  true,
+		},
+		{
+			name: "stored transaction with non-minimal pushes",
+			prepare: func(c *DiskCache, dir string) {
+				c.Put(nonMinimalTx)
+			},
+			txid:      nonMinimalTxid,
+			wantFound: true,
+			wantFile:  true,
+		},
+		{
+			name:      "missing transaction",
+			prepare:   func(c *DiskCache, dir string) {},
+			txid:      legacyTxid,
+			wantFound: false,
+			wantFile:  false,
+		},
+		{
+			name: "corrupted entry is removed",
+			prepare: func(c *DiskCache, dir string) {
+				// NOTE: 別のトランザクションの内容を保存しておく
+				os.WriteFile(filepath.Join(dir, legacyTxid+".hex"), []byte(segwitTxHex), 0644)
+			},
+			txid:      legacyTxid,
+			wantFound: false,
+			wantFile:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c, err := NewDiskCache(dir, 0)
+			if err != nil {
+				t.Fatalf("NewDiskCache() error = %v", err)
+			}
+			tt.prepare(c, dir)
+			got, found := c.Get(tt.txid)
+			if found != tt.wantFound {
+				t.Fatalf("DiskCache.Get() found = %v, want %v", found, tt.wantFound)
+			}
+			if found {
+				if gotTxid, _ := got.ID(); gotTxid != tt.txid {
+					t.Errorf("DiskCache.Get() txid = %v, want %v", gotTxid, tt.txid)
+				}
+			}
+			_, err = os.Stat(filepath.Join(dir, tt.txid+".hex"))
+			if (err == nil) != tt.wantFile {
+				t.Errorf("cache file exists = %v, want %v", err == nil, tt.wantFile)
+			}
+		})
+	}
+}
+
+// NOTE: 64文字の小文字の16進数でないtxidではファイルを読み書きも削除もしない
+func TestDiskCache_InvalidTxid(t *testing.T) {
+	legacyTx := parseTxHex(legacyTxHex)
+	legacyTxid, _ := legacyTx.ID()
+	segwitTxid, _ := parseTxHex(segwitTxHex).ID()
+
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "cache")
+	c, err := NewDiskCache(dir, 0)
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	// NOTE: キャッシュのディレクトリの外に、txidと内容が一致しないファイルを置く
+	outside := filepath.Join(parent, segwitTxid+".hex")
+	os.WriteFile(outside, []byte(legacyTxHex), 0644)
+	c.Put(legacyTx)
+
+	for _, txid := range []string{
+		"../" + segwitTxid,
+		strings.ToUpper(legacyTxid),
+		legacyTxid[:63],
+		legacyTxid + "0",
+		"",
+	} {
+		if _, found := c.Get(txid); found {
+			t.Errorf("DiskCache.Get(%q) found = true, want false", txid)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside cache directory was removed: %v", err)
+	}
+}
+
+func TestDiskCache_Put(t *testing.T) {
+	legacyTx := parseTxHex(legacyTxHex)
+	legacyTxid, _ := legacyTx.ID()
+	segwitTx := parseTxHex(segwitTxHex)
+	segwitTxid, _ := segwitTx.ID()
+
+	dir := t.TempDir()
+	// NOTE: どちらか一方のトランザクションしか保存できないサイズ
+	c, err := NewDiskCache(dir, int64(len(segwitTxHex)))
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	if err := c.Put(legacyTx); err != nil {
+		t.Fatalf("DiskCache.Put() error = %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, legacyTxid+".hex"), past, past)
+	if err := c.Put(segwitTx); err != nil {
+		t.Fatalf("DiskCache.Put() error = %v", err)
+	}
+
+	if _, found := c.Get(legacyTxid); found {
+		t.Errorf("DiskCache.Get(%s) found evicted transaction", legacyTxid)
+	}
+	if _, found := c.Get(segwitTxid); !found {
+		t.Errorf("DiskCache.Get(%s) did not find latest transaction", segwitTxid)
+	}
+}
+
+func TestDiskCache_Concurrent(t *testing.T) {
+	txs := []*Transaction{parseTxHex(legacyTxHex), parseTxHex(segwitTxHex)}
+	c, err := NewDiskCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(tx *Transaction) {
+			defer wg.Done()
+			txid, _ := tx.ID()
+			if err := c.Put(tx); err != nil {
+				t.Errorf("DiskCache.Put() error = %v", err)
+			}
+			if _, found := c.Get(txid); !found {
+				t.Errorf("DiskCache.Get(%s) not found", txid)
+			}
+		}(txs[i%len(txs)])
+	}
+	wg.Wait()
+}
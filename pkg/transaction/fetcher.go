@@ -1,57 +1,216 @@
 package transaction
 
 import (
+	"context"
 	"fmt"
+	"golang-bitcoin/pkg/chaincfg"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NOTE: Esplora互換APIのベースURL
+const (
+	BlockstreamMainnetURL = "https://blockstream.info/api"
+	BlockstreamTestnetURL = "https://blockstream.info/testnet/api"
+	MempoolMainnetURL     = "https://mempool.space/api"
+	MempoolTestnetURL     = "https://mempool.space/testnet/api"
+)
+
+const (
+	defaultFetchTimeout = 30 * time.Second
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
 )
 
 type TransactionFetcher struct {
-	url    string
-	cached map[string]*Transaction
+	baseURL      string
+	client       *http.Client
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+	mu           sync.Mutex
+	cached       map[string]*Transaction
+	disk         *DiskCache
+}
+
+type FetcherOption func(*TransactionFetcher)
+
+// NOTE: Esplora互換のAPIを指す。ローカルのregtest用Esploraなども指定できる
+func WithBaseURL(baseURL string) FetcherOption {
+	return func(tf *TransactionFetcher) {
+		tf.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+func WithHTTPClient(client *http.Client) FetcherOption {
+	return func(tf *TransactionFetcher) {
+		tf.client = client
+	}
+}
+
+// NOTE: リクエスト1回あたりのタイムアウト。0以下の場合はタイムアウトしない
+func WithTimeout(timeout time.Duration) FetcherOption {
+	return func(tf *TransactionFetcher) {
+		tf.timeout = timeout
+	}
+}
+
+// NOTE: 429と5xxの場合に、backoffを倍にしながら最大maxRetries回リトライする
+func WithRetry(maxRetries int, backoff time.Duration) FetcherOption {
+	return func(tf *TransactionFetcher) {
+		tf.maxRetries = maxRetries
+		tf.retryBackoff = backoff
+	}
+}
+
+// NOTE: 取得したトランザクションをディスクにも保存する
+func WithDiskCache(disk *DiskCache) FetcherOption {
+	return func(tf *TransactionFetcher) {
+		tf.disk = disk
+	}
 }
 
-func NewTransactionFetcher(testnet bool) *TransactionFetcher {
-	var url string
-	if testnet {
-		url = "https://blockstream.info/testnet/api/tx"
-	} else {
-		url = "https://blockstream.info/api/tx"
+// NOTE: ベースURLはparamsのエクスプローラ。regtestのようにエクスプローラがない場合はWithBaseURLで指定する
+func NewTransactionFetcher(params *chaincfg.Params, options ...FetcherOption) *TransactionFetcher {
+	tf := &TransactionFetcher{
+		baseURL:      strings.TrimRight(params.ExplorerURL, "/"),
+		client:       http.DefaultClient,
+		timeout:      defaultFetchTimeout,
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+		cached:       make(map[string]*Transaction),
+	}
+	for _, option := range options {
+		option(tf)
 	}
-	return &TransactionFetcher{url, make(map[string]*Transaction)}
+	return tf
 }
 
-func (tf *TransactionFetcher) FetchTransaction(txid string, fresh bool) (*Transaction, error) {
-	if !fresh && tf.cached[txid] != nil {
-		return tf.cached[txid], nil
+func (tf *TransactionFetcher) FetchTransaction(ctx context.Context, txid string, fresh bool) (*Transaction, error) {
+	if !fresh {
+		if tx, ok := tf.lookup(txid); ok {
+			return tx, nil
+		}
+	}
+	if tf.baseURL == "" {
+		return nil, fmt.Errorf("no explorer URL to fetch transaction %s", txid)
+	}
+	url := fmt.Sprintf("%s/tx/%s/raw", tf.baseURL, txid)
+
+	var tx *Transaction
+	var err error
+	for attempt := 0; ; attempt++ {
+		var wait time.Duration
+		tx, wait, err = tf.fetch(ctx, url)
+		if err == nil || wait < 0 || attempt >= tf.maxRetries {
+			break
+		}
+		if wait == 0 {
+			wait = tf.retryBackoff << attempt
+		} else if maxWait := tf.maxRetryWait(); wait > maxWait {
+			// NOTE: サーバーが極端に長いRetry-Afterを返しても待ち続けないように切り詰める
+			wait = maxWait
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
-	url := fmt.Sprintf("%s/%s/raw", tf.url, txid)
-	resp, err := http.Get(url)
+
+	actualTxid, err := tx.ID()
 	if err != nil {
 		return nil, err
 	}
+	if actualTxid != txid {
+		return nil, fmt.Errorf("fetched transaction id does not match expected: %s != %s", actualTxid, txid)
+	}
+
+	tf.store(txid, tx)
+
+	return tx, nil
+}
+
+// NOTE: リトライしない場合はwaitに負の値を返す。waitが0の場合はbackoffに従う
+func (tf *TransactionFetcher) fetch(ctx context.Context, url string) (*Transaction, time.Duration, error) {
+	if tf.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tf.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	resp, err := tf.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("error fetching transaction: %s", resp.Status)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, retryAfter(resp), fmt.Errorf("error fetching transaction: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, -1, fmt.Errorf("error fetching transaction: %s", resp.Status)
 	}
 
 	tx, err := ParseTransaction(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
+	return tx, 0, nil
+}
 
-	/*
-		TODO: witness実装まではコメントアウト
-		actualTxid, err := tx.ID()
-		if err != nil {
-			return nil, err
-		}
-		if actualTxid != txid {
-			return nil, fmt.Errorf("fetched transaction id does not match expected: %s != %s", actualTxid, txid)
-		}
-	*/
+// NOTE: Retry-Afterに従って待つ時間の上限。backoffの最大値とリクエストのタイムアウトのうち長い方
+func (tf *TransactionFetcher) maxRetryWait() time.Duration {
+	maxWait := tf.retryBackoff << max(tf.maxRetries-1, 0)
+	if tf.timeout > maxWait {
+		maxWait = tf.timeout
+	}
+	return maxWait
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
 
+func (tf *TransactionFetcher) lookup(txid string) (*Transaction, bool) {
+	tf.mu.Lock()
+	tx, ok := tf.cached[txid]
+	tf.mu.Unlock()
+	if ok {
+		return tx, true
+	}
+	if tf.disk == nil {
+		return nil, false
+	}
+	tx, ok = tf.disk.Get(txid)
+	if !ok {
+		return nil, false
+	}
+	tf.mu.Lock()
 	tf.cached[txid] = tx
+	tf.mu.Unlock()
+	return tx, true
+}
 
-	return tx, nil
+func (tf *TransactionFetcher) store(txid string, tx *Transaction) {
+	tf.mu.Lock()
+	tf.cached[txid] = tx
+	tf.mu.Unlock()
+	if tf.disk != nil {
+		// NOTE: キャッシュへの書き込みに失敗しても取得自体は成功として扱う
+		tf.disk.Put(tx)
+	}
 }
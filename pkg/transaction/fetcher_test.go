@@ -0,0 +1,209 @@
+package transaction
+
+import (
+	"context"
+	"encoding/hex"
+	"golang-bitcoin/pkg/chaincfg"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransactionFetcher_FetchTransaction(t *testing.T) {
+	raw, _ := hex.DecodeString(segwitTxHex)
+	txid, _ := parseTxHex(segwitTxHex).ID()
+	nonMinimalRaw, _ := hex.DecodeString(nonMinimalPushTxHex)
+	nonMinimalTxid, _ := parseTxHex(nonMinimalPushTxHex).ID()
+
+	tests := []struct {
+		name         string
+		handler      func(calls int32) (int, []byte)
+		txid         string
+		options      []FetcherOption
+		wantErr      bool
+		wantRequests int32
+	}{
+		{
+			name: "esplora compatible backend",
+			handler: func(calls int32) (int, []byte) {
+				return http.StatusOK, raw
+			},
+			txid:         txid,
+			wantErr:      false,
+			wantRequests: 1,
+		},
+		{
+			name: "retry on 503 and 429",
+			handler: func(calls int32) (int, []byte) {
+				switch calls {
+				case 1:
+					return http.StatusServiceUnavailable, nil
+				case 2:
+					return http.StatusTooManyRequests, nil
+				default:
+					return http.StatusOK, raw
+				}
+			},
+			txid:         txid,
+			options:      []FetcherOption{WithRetry(3, time.Millisecond)},
+			wantErr:      false,
+			wantRequests: 3,
+		},
+		{
+			name: "give up after max retries",
+			handler: func(calls int32) (int, []byte) {
+				return http.StatusInternalServerError, nil
+			},
+			txid:         txid,
+			options:      []FetcherOption{WithRetry(2, time.Millisecond)},
+			wantErr:      true,
+			wantRequests: 3,
+		},
+		{
+			name: "no retry on 404",
+			handler: func(calls int32) (int, []byte) {
+				return http.StatusNotFound, nil
+			},
+			txid:         txid,
+			options:      []FetcherOption{WithRetry(3, time.Millisecond)},
+			wantErr:      true,
+			wantRequests: 1,
+		},
+		{
+			// NOTE: 最短でないpushを含んでいても、parseしたトランザクションのtxidは元のバイト列と一致する
+			name: "transaction with non-minimal pushes",
+			handler: func(calls int32) (int, []byte) {
+				return http.StatusOK, nonMinimalRaw
+			},
+			txid:         nonMinimalTxid,
+			wantErr:      false,
+			wantRequests: 1,
+		},
+		{
+			name: "mismatched txid",
+			handler: func(calls int32) (int, []byte) {
+				return http.StatusOK, raw
+			},
+			txid:         "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03",
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/tx/"+tt.txid+"/raw" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				status, body := tt.handler(atomic.AddInt32(&calls, 1))
+				w.WriteHeader(status)
+				w.Write(body)
+			}))
+			defer server.Close()
+
+			options := append([]FetcherOption{WithBaseURL(server.URL + "/api/"), WithHTTPClient(server.Client())}, tt.options...)
+			tf := NewTransactionFetcher(&chaincfg.MainNetParams, options...)
+			got, err := tf.FetchTransaction(context.Background(), tt.txid, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TransactionFetcher.FetchTransaction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if gotTxid, _ := got.ID(); gotTxid != tt.txid {
+					t.Errorf("TransactionFetcher.FetchTransaction() txid = %v, want %v", gotTxid, tt.txid)
+				}
+			}
+			if calls != tt.wantRequests {
+				t.Errorf("requests = %v, want %v", calls, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// NOTE: 極端に長いRetry-Afterは、backoffの最大値かタイムアウトまでに切り詰めてリトライする
+func TestTransactionFetcher_FetchTransactionRetryAfter(t *testing.T) {
+	raw, _ := hex.DecodeString(segwitTxHex)
+	txid, _ := parseTxHex(segwitTxHex).ID()
+	tests := []struct {
+		name    string
+		options []FetcherOption
+	}{
+		{
+			name:    "clamped to max backoff",
+			options: []FetcherOption{WithRetry(3, 10*time.Millisecond), WithTimeout(0)},
+		},
+		{
+			name:    "clamped to request timeout",
+			options: []FetcherOption{WithRetry(1, time.Millisecond), WithTimeout(50 * time.Millisecond)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					w.Header().Set("Retry-After", "86400")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.Write(raw)
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			options := append([]FetcherOption{WithBaseURL(server.URL), WithHTTPClient(server.Client())}, tt.options...)
+			tf := NewTransactionFetcher(&chaincfg.MainNetParams, options...)
+			if _, err := tf.FetchTransaction(ctx, txid, false); err != nil {
+				t.Fatalf("TransactionFetcher.FetchTransaction() error = %v", err)
+			}
+			if calls != 2 {
+				t.Errorf("requests = %v, want 2", calls)
+			}
+		})
+	}
+}
+
+func TestTransactionFetcher_FetchTransactionCancel(t *testing.T) {
+	txid, _ := parseTxHex(segwitTxHex).ID()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tf := NewTransactionFetcher(&chaincfg.MainNetParams, WithBaseURL(server.URL), WithRetry(5, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := tf.FetchTransaction(ctx, txid, false)
+	if err != context.DeadlineExceeded {
+		t.Errorf("TransactionFetcher.FetchTransaction() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("TransactionFetcher.FetchTransaction() took %v after cancellation", elapsed)
+	}
+}
+
+// NOTE: regtestにはエクスプローラがないので、WithBaseURLを指定しなければリクエストせずにエラーにする
+func TestTransactionFetcher_FetchTransactionWithoutExplorer(t *testing.T) {
+	txid, _ := parseTxHex(segwitTxHex).ID()
+	tf := NewTransactionFetcher(&chaincfg.RegressionNetParams, WithHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			t.Errorf("unexpected request: %s", r.URL)
+			return nil, context.Canceled
+		}),
+	}))
+	if _, err := tf.FetchTransaction(context.Background(), txid, false); err == nil {
+		t.Errorf("TransactionFetcher.FetchTransaction() error = nil, want error")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
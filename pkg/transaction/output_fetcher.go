@@ -0,0 +1,83 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// NOTE: inputが参照する以前のoutputを解決するためのインターフェース
+type OutputFetcher interface {
+	FetchOutput(txid string, index uint32) (*Output, error)
+}
+
+func (tf *TransactionFetcher) FetchOutput(txid string, index uint32) (*Output, error) {
+	tx, err := tf.FetchTransaction(context.Background(), txid, false)
+	if err != nil {
+		return nil, err
+	}
+	return outputAt(tx, txid, index)
+}
+
+// NOTE: 事前に登録したoutputのみを返す。テストやオフラインでの検証用
+type MemoryOutputFetcher struct {
+	outputs map[string]*Output
+}
+
+func NewMemoryOutputFetcher() *MemoryOutputFetcher {
+	return &MemoryOutputFetcher{make(map[string]*Output)}
+}
+
+func (mf *MemoryOutputFetcher) AddOutput(txid string, index uint32, output *Output) {
+	mf.outputs[outpointKey(txid, index)] = output
+}
+
+func (mf *MemoryOutputFetcher) AddTransaction(tx *Transaction) error {
+	txid, err := tx.ID()
+	if err != nil {
+		return err
+	}
+	for i, output := range tx.Outputs {
+		mf.AddOutput(txid, uint32(i), output)
+	}
+	return nil
+}
+
+func (mf *MemoryOutputFetcher) FetchOutput(txid string, index uint32) (*Output, error) {
+	output, ok := mf.outputs[outpointKey(txid, index)]
+	if !ok {
+		return nil, fmt.Errorf("output not found: %s", outpointKey(txid, index))
+	}
+	return output, nil
+}
+
+// NOTE: dir/<txid>.hex に保存された生のトランザクションからoutputを解決する
+type FileOutputFetcher struct {
+	dir string
+}
+
+func NewFileOutputFetcher(dir string) *FileOutputFetcher {
+	return &FileOutputFetcher{dir}
+}
+
+func (ff *FileOutputFetcher) FetchOutput(txid string, index uint32) (*Output, error) {
+	if err := validateTxid(txid); err != nil {
+		return nil, err
+	}
+	tx, err := loadTransactionFile(filepath.Join(ff.dir, txid+cacheFileExt), txid)
+	if err != nil {
+		return nil, err
+	}
+	return outputAt(tx, txid, index)
+}
+
+func outputAt(tx *Transaction, txid string, index uint32) (*Output, error) {
+	if int(index) >= len(tx.Outputs) {
+		return nil, fmt.Errorf("output index out of range: %s", outpointKey(txid, index))
+	}
+	return tx.Outputs[index], nil
+}
+
+func outpointKey(txid string, index uint32) string {
+	return fmt.Sprintf("%s:%d", txid, index)
+}
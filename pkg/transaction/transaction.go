@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -24,6 +25,7 @@ type Input struct {
 	PreviousOutputIndex uint32
 	ScriptSig           *script.Script
 	Sequence            uint32
+	Witness             [][]byte
 }
 
 type Output struct {
@@ -61,49 +63,65 @@ func ParseTransaction(reader io.Reader) (*Transaction, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error reading number of inputs: %v", err)
 	}
-	inputs := make([]*Input, numInputs)
-	for i := 0; i < int(numInputs); i++ {
-		inputs[i], err = ParseInput(breader)
+	// NOTE: 個数は信用できないので、先に確保せずに読めた分だけ追加する
+	var inputs []*Input
+	for i := uint64(0); i < numInputs; i++ {
+		input, err := ParseInput(breader)
 		if err != nil {
 			return nil, fmt.Errorf("error reading input %d: %v", i, err)
 		}
+		inputs = append(inputs, input)
 	}
 
 	numOutputs, err := utils.ParseVarInt(breader)
 	if err != nil {
 		return nil, fmt.Errorf("error reading number of outputs: %v", err)
 	}
-	outputs := make([]*Output, numOutputs)
-	for i := 0; i < int(numOutputs); i++ {
-		outputs[i], err = ParseOutput(breader)
+	var outputs []*Output
+	for i := uint64(0); i < numOutputs; i++ {
+		output, err := ParseOutput(breader)
 		if err != nil {
 			return nil, fmt.Errorf("error reading output %d: %v", i, err)
 		}
+		outputs = append(outputs, output)
 	}
 
 	if isSegwit {
-		// TODO: Parse witness data
+		// NOTE: witnessはinputの順番に並んでいる
+		for i := range inputs {
+			inputs[i].Witness, err = ParseWitness(breader)
+			if err != nil {
+				return nil, fmt.Errorf("error reading witness %d: %v", i, err)
+			}
+		}
 	}
 
-	remainingData, err := io.ReadAll(breader)
-	if err != nil {
-		return nil, fmt.Errorf("error reading remaining data: %v", err)
-	}
-	if len(remainingData) < 4 {
-		return nil, fmt.Errorf("remaining data is too short to contain locktime")
+	buf = make([]byte, 4)
+	if _, err := io.ReadFull(breader, buf); err != nil {
+		return nil, fmt.Errorf("error reading locktime: %v", err)
 	}
-	locktime := binary.LittleEndian.Uint32(remainingData[len(remainingData)-4:])
+	locktime := binary.LittleEndian.Uint32(buf)
 
 	return &Transaction{version, inputs, outputs, locktime, isSegwit}, nil
 }
 
 func (t *Transaction) Serialize() ([]byte, error) {
+	return t.serialize(t.IsSegwit)
+}
+
+// NOTE: txidの計算にはwitnessを除いたシリアライズを用いる
+func (t *Transaction) serialize(includeWitness bool) ([]byte, error) {
 	serialized := make([]byte, 0)
 
 	buf := make([]byte, 4)
 	binary.LittleEndian.PutUint32(buf, t.Version)
 	serialized = append(serialized, buf...)
 
+	if includeWitness {
+		// NOTE: marker, flag
+		serialized = append(serialized, 0x00, 0x01)
+	}
+
 	numInputs, err := utils.SerializeVarInt(uint64(len(t.Inputs)))
 	if err != nil {
 		return nil, err
@@ -122,6 +140,12 @@ func (t *Transaction) Serialize() ([]byte, error) {
 		serialized = append(serialized, output.Serialize()...)
 	}
 
+	if includeWitness {
+		for _, input := range t.Inputs {
+			serialized = append(serialized, input.SerializeWitness()...)
+		}
+	}
+
 	buf = make([]byte, 4)
 	binary.LittleEndian.PutUint32(buf, t.Locktime)
 	serialized = append(serialized, buf...)
@@ -130,29 +154,51 @@ func (t *Transaction) Serialize() ([]byte, error) {
 }
 
 func (t *Transaction) ID() (string, error) {
+	serialized, err := t.serialize(false)
+	if err != nil {
+		return "", err
+	}
+
+	// NOTE: IDはハッシュをlittle-endianで表示する
+	hash256 := utils.ReverseBytes(utils.Hash256(serialized))
+
+	return hex.EncodeToString(hash256), nil
+}
+
+func (t *Transaction) WitnessID() (string, error) {
 	serialized, err := t.Serialize()
 	if err != nil {
 		return "", err
 	}
 
-	hash256 := utils.Hash256(serialized)
+	hash256 := utils.ReverseBytes(utils.Hash256(serialized))
 
 	return hex.EncodeToString(hash256), nil
 }
 
-func (t *Transaction) Fee(testnet bool) (uint64, error) {
+// NOTE: 金額はuint64なので、合計が桁あふれする場合やoutputの合計がinputの合計を超える場合はエラーにする
+func (t *Transaction) Fee(fetcher OutputFetcher) (uint64, error) {
 	var inputSum uint64
 	for _, input := range t.Inputs {
-		value, err := input.Value(testnet)
+		value, err := input.Value(fetcher)
 		if err != nil {
 			return 0, err
 		}
+		if inputSum+value < inputSum {
+			return 0, fmt.Errorf("sum of input values overflows")
+		}
 		inputSum += value
 	}
 	var outputSum uint64
 	for _, output := range t.Outputs {
+		if outputSum+output.Value < outputSum {
+			return 0, fmt.Errorf("sum of output values overflows")
+		}
 		outputSum += output.Value
 	}
+	if outputSum > inputSum {
+		return 0, fmt.Errorf("transaction has negative fee: outputs %d exceed inputs %d", outputSum, inputSum)
+	}
 	return inputSum - outputSum, nil
 }
 
@@ -164,6 +210,7 @@ func (t *Transaction) DeepCopy() *Transaction {
 			PreviousOutputIndex: input.PreviousOutputIndex,
 			ScriptSig:           input.ScriptSig,
 			Sequence:            input.Sequence,
+			Witness:             input.Witness,
 		}
 	}
 
@@ -183,18 +230,56 @@ func (t *Transaction) DeepCopy() *Transaction {
 	}
 }
 
-func (t *Transaction) SigHash(index int, testnet bool) ([]byte, error) {
+// NOTE: scriptCodeは署名を検証するscript (scriptPubKeyやredeem script) の、最後に実行されたOP_CODESEPARATORより後ろの部分
+// NOTE: scriptCodeがnilの場合はscriptPubKey全体に署名する
+func (t *Transaction) SigHash(index int, hashType uint32, scriptCode *script.Script, fetcher OutputFetcher) ([]byte, error) {
+	baseType := hashType & 0x1f
+	anyoneCanPay := hashType&script.SIGHASH_ANYONECANPAY != 0
+
+	// NOTE: SIGHASH_SINGLEで対応するoutputがない場合は、歴史的な経緯により1を署名する
+	if baseType == script.SIGHASH_SINGLE && index >= len(t.Outputs) {
+		one := make([]byte, 32)
+		one[0] = 0x01
+		return one, nil
+	}
+
 	txCopy := t.DeepCopy()
 	for i := 0; i < len(txCopy.Inputs); i++ {
 		if i != index {
 			txCopy.Inputs[i].ScriptSig = script.NewScript()
+			// NOTE: NONE, SINGLEでは他のinputのsequenceは署名の対象外
+			if baseType == script.SIGHASH_NONE || baseType == script.SIGHASH_SINGLE {
+				txCopy.Inputs[i].Sequence = 0
+			}
 		}
 	}
-	scriptPubKey, err := txCopy.Inputs[index].ScriptPubKey(testnet)
-	if err != nil {
-		return nil, err
+	if scriptCode == nil {
+		scriptPubKey, err := txCopy.Inputs[index].ScriptPubKey(fetcher)
+		if err != nil {
+			return nil, err
+		}
+		scriptCode = scriptPubKey
+	}
+	// NOTE: 残ったOP_CODESEPARATORは、実行されたかどうかに関わらず取り除いてから署名する
+	txCopy.Inputs[index].ScriptSig = scriptCode.RemoveCodeSeparators()
+
+	switch baseType {
+	case script.SIGHASH_NONE:
+		txCopy.Outputs = []*Output{}
+	case script.SIGHASH_SINGLE:
+		// NOTE: 対応するoutputより前のoutputは空にする
+		txCopy.Outputs = txCopy.Outputs[:index+1]
+		for i := 0; i < index; i++ {
+			txCopy.Outputs[i] = &Output{
+				Value:        0xffffffffffffffff,
+				ScriptPubKey: script.NewScript(),
+			}
+		}
+	}
+
+	if anyoneCanPay {
+		txCopy.Inputs = []*Input{txCopy.Inputs[index]}
 	}
-	txCopy.Inputs[index].ScriptSig = scriptPubKey
 
 	serialized, err := txCopy.Serialize()
 	if err != nil {
@@ -202,38 +287,282 @@ func (t *Transaction) SigHash(index int, testnet bool) ([]byte, error) {
 	}
 
 	buf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(buf, 1)
+	binary.LittleEndian.PutUint32(buf, hashType)
 	serialized = append(serialized, buf...)
 	hash := utils.Hash256(serialized)
 
 	return hash, nil
 }
 
-func (t *Transaction) VerifyInput(index int, testnet bool) error {
-	sigHash, err := t.SigHash(index, testnet)
+func (t *Transaction) HashPrevouts() []byte {
+	allPrevouts := make([]byte, 0, len(t.Inputs)*36)
+	for _, input := range t.Inputs {
+		allPrevouts = append(allPrevouts, utils.ReverseBytes(input.PreviousOutputHash)...)
+		allPrevouts = binary.LittleEndian.AppendUint32(allPrevouts, input.PreviousOutputIndex)
+	}
+	return utils.Hash256(allPrevouts)
+}
+
+func (t *Transaction) HashSequence() []byte {
+	allSequence := make([]byte, 0, len(t.Inputs)*4)
+	for _, input := range t.Inputs {
+		allSequence = binary.LittleEndian.AppendUint32(allSequence, input.Sequence)
+	}
+	return utils.Hash256(allSequence)
+}
+
+func (t *Transaction) HashOutputs() []byte {
+	allOutputs := make([]byte, 0)
+	for _, output := range t.Outputs {
+		allOutputs = append(allOutputs, output.Serialize()...)
+	}
+	return utils.Hash256(allOutputs)
+}
+
+// NOTE: BIP143のsegwit v0用の署名ハッシュ
+// NOTE: witnessScriptは最後に実行されたOP_CODESEPARATORより後ろの部分で、legacyと異なりOP_CODESEPARATORは取り除かない
+func (t *Transaction) SigHashBIP143(index int, hashType uint32, redeemScript, witnessScript *script.Script, fetcher OutputFetcher) ([]byte, error) {
+	input := t.Inputs[index]
+	baseType := hashType & 0x1f
+	anyoneCanPay := hashType&script.SIGHASH_ANYONECANPAY != 0
+
+	// NOTE: witnessScriptがあればP2WSH、redeemScriptがあればP2SH-P2WPKH、
+	//       どちらもなければP2WPKHとしてscriptCodeを決める
+	var scriptCode *script.Script
+	if witnessScript != nil {
+		scriptCode = witnessScript
+	} else {
+		var witnessProgram *script.Script
+		if redeemScript != nil {
+			witnessProgram = redeemScript
+		} else {
+			scriptPubKey, err := input.ScriptPubKey(fetcher)
+			if err != nil {
+				return nil, err
+			}
+			witnessProgram = scriptPubKey
+		}
+		// NOTE: OP_0 <20-byte hash>
+		if len(witnessProgram.Instructions) != 2 || len(witnessProgram.Instructions[1].Data) != 20 {
+			return nil, fmt.Errorf("script is not a p2wpkh witness program")
+		}
+		scriptCode = script.NewP2PKHScriptFromHash160(witnessProgram.Instructions[1].Data)
+	}
+	serializedScriptCode, err := scriptCode.Serialize()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	z := new(big.Int).SetBytes(sigHash)
-	scriptPubkey, err := t.Inputs[index].ScriptPubKey(testnet)
+	scriptCodeLen, err := utils.SerializeVarInt(uint64(len(serializedScriptCode)))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	scriptSig := t.Inputs[index].ScriptSig
-	scriptSig.Add(scriptPubkey)
-	return scriptSig.Evaluate(z)
+
+	value, err := input.Value(fetcher)
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: ハッシュタイプによって署名の対象外となる部分はゼロで埋める
+	hashPrevouts := make([]byte, 32)
+	hashSequence := make([]byte, 32)
+	hashOutputs := make([]byte, 32)
+	if !anyoneCanPay {
+		hashPrevouts = t.HashPrevouts()
+	}
+	if !anyoneCanPay && baseType != script.SIGHASH_SINGLE && baseType != script.SIGHASH_NONE {
+		hashSequence = t.HashSequence()
+	}
+	if baseType != script.SIGHASH_SINGLE && baseType != script.SIGHASH_NONE {
+		hashOutputs = t.HashOutputs()
+	} else if baseType == script.SIGHASH_SINGLE && index < len(t.Outputs) {
+		hashOutputs = utils.Hash256(t.Outputs[index].Serialize())
+	}
+
+	serialized := make([]byte, 0)
+	serialized = binary.LittleEndian.AppendUint32(serialized, t.Version)
+	serialized = append(serialized, hashPrevouts...)
+	serialized = append(serialized, hashSequence...)
+	serialized = append(serialized, utils.ReverseBytes(input.PreviousOutputHash)...)
+	serialized = binary.LittleEndian.AppendUint32(serialized, input.PreviousOutputIndex)
+	serialized = append(serialized, scriptCodeLen...)
+	serialized = append(serialized, serializedScriptCode...)
+	serialized = binary.LittleEndian.AppendUint64(serialized, value)
+	serialized = binary.LittleEndian.AppendUint32(serialized, input.Sequence)
+	serialized = append(serialized, hashOutputs...)
+	serialized = binary.LittleEndian.AppendUint32(serialized, t.Locktime)
+	serialized = binary.LittleEndian.AppendUint32(serialized, hashType)
+
+	return utils.Hash256(serialized), nil
 }
 
-func (t *Transaction) Verify(testnet bool) error {
-	fee, err := t.Fee(testnet)
+// NOTE: BIP341 leafHashがnilの場合はkey path spend、それ以外はscript path spendの署名ハッシュ
+func (t *Transaction) SigHashTaproot(index int, hashType uint32, leafHash []byte, codeSepPos uint32, fetcher OutputFetcher) ([]byte, error) {
+	input := t.Inputs[index]
+	baseType := hashType & 0x03
+	anyoneCanPay := hashType&script.SIGHASH_ANYONECANPAY != 0
+	if hashType > 0x03 && (hashType < 0x81 || hashType > 0x83) {
+		return nil, fmt.Errorf("invalid taproot hash type: %x", hashType)
+	}
+	// NOTE: SIGHASH_DEFAULT(0x00)はSIGHASH_ALLと同じ範囲に署名する
+	if hashType == 0 {
+		baseType = script.SIGHASH_ALL
+	}
+
+	serialized := []byte{0x00, byte(hashType)}
+	serialized = binary.LittleEndian.AppendUint32(serialized, t.Version)
+	serialized = binary.LittleEndian.AppendUint32(serialized, t.Locktime)
+
+	if !anyoneCanPay {
+		// NOTE: 全てのinputが使用するoutputの金額とscriptPubKeyにも署名する
+		prevouts := make([]byte, 0, len(t.Inputs)*36)
+		amounts := make([]byte, 0, len(t.Inputs)*8)
+		scriptPubKeys := make([]byte, 0)
+		sequences := make([]byte, 0, len(t.Inputs)*4)
+		for _, in := range t.Inputs {
+			prevouts = append(prevouts, utils.ReverseBytes(in.PreviousOutputHash)...)
+			prevouts = binary.LittleEndian.AppendUint32(prevouts, in.PreviousOutputIndex)
+			output, err := fetcher.FetchOutput(hex.EncodeToString(in.PreviousOutputHash), in.PreviousOutputIndex)
+			if err != nil {
+				return nil, err
+			}
+			serializedOutput := output.Serialize()
+			amounts = append(amounts, serializedOutput[:8]...)
+			scriptPubKeys = append(scriptPubKeys, serializedOutput[8:]...)
+			sequences = binary.LittleEndian.AppendUint32(sequences, in.Sequence)
+		}
+		serialized = append(serialized, sha256Sum(prevouts)...)
+		serialized = append(serialized, sha256Sum(amounts)...)
+		serialized = append(serialized, sha256Sum(scriptPubKeys)...)
+		serialized = append(serialized, sha256Sum(sequences)...)
+	}
+	if baseType != script.SIGHASH_NONE && baseType != script.SIGHASH_SINGLE {
+		outputs := make([]byte, 0)
+		for _, output := range t.Outputs {
+			outputs = append(outputs, output.Serialize()...)
+		}
+		serialized = append(serialized, sha256Sum(outputs)...)
+	}
+
+	// NOTE: 2つ以上の要素があり、最後の要素が0x50で始まる場合はannex
+	var annex []byte
+	if len(input.Witness) >= 2 {
+		last := input.Witness[len(input.Witness)-1]
+		if len(last) > 0 && last[0] == script.TAPROOT_ANNEX_TAG {
+			annex = last
+		}
+	}
+	spendType := byte(0)
+	if leafHash != nil {
+		spendType |= 0x02
+	}
+	if annex != nil {
+		spendType |= 0x01
+	}
+	serialized = append(serialized, spendType)
+
+	if anyoneCanPay {
+		output, err := fetcher.FetchOutput(hex.EncodeToString(input.PreviousOutputHash), input.PreviousOutputIndex)
+		if err != nil {
+			return nil, err
+		}
+		serialized = append(serialized, utils.ReverseBytes(input.PreviousOutputHash)...)
+		serialized = binary.LittleEndian.AppendUint32(serialized, input.PreviousOutputIndex)
+		serialized = append(serialized, output.Serialize()...)
+		serialized = binary.LittleEndian.AppendUint32(serialized, input.Sequence)
+	} else {
+		serialized = binary.LittleEndian.AppendUint32(serialized, uint32(index))
+	}
+	if annex != nil {
+		annexLen, err := utils.SerializeVarInt(uint64(len(annex)))
+		if err != nil {
+			return nil, err
+		}
+		serialized = append(serialized, sha256Sum(append(annexLen, annex...))...)
+	}
+	if baseType == script.SIGHASH_SINGLE {
+		// NOTE: legacyと異なり、対応するoutputがない場合は無効
+		if index >= len(t.Outputs) {
+			return nil, fmt.Errorf("no output corresponding to input %d for sighash single", index)
+		}
+		serialized = append(serialized, sha256Sum(t.Outputs[index].Serialize())...)
+	}
+	if leafHash != nil {
+		// NOTE: key_versionは0
+		serialized = append(serialized, leafHash...)
+		serialized = append(serialized, 0x00)
+		serialized = binary.LittleEndian.AppendUint32(serialized, codeSepPos)
+	}
+
+	return utils.TaggedHash("TapSighash", serialized), nil
+}
+
+func sha256Sum(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+func (t *Transaction) VerifyInput(index int, fetcher OutputFetcher) error {
+	return t.VerifyInputWithFlags(index, fetcher, script.DEFAULT_VERIFY_FLAGS)
+}
+
+func (t *Transaction) VerifyInputWithFlags(index int, fetcher OutputFetcher, flags script.VerifyFlags) error {
+	scriptPubkey, err := t.Inputs[index].ScriptPubKey(fetcher)
 	if err != nil {
 		return err
 	}
-	if fee < 0 {
-		return fmt.Errorf("transaction has negative fee")
+	var redeemScript *script.Script
+	if flags&script.SCRIPT_VERIFY_P2SH != 0 && scriptPubkey.IsP2SHScriptPubkey() {
+		redeemScript, err = t.Inputs[index].ScriptSig.RedeemScript()
+		if err != nil {
+			return err
+		}
+	}
+	// NOTE: 署名ごとにハッシュタイプやscriptCodeが異なるため、zは署名検証時に計算する
+	sigHash := func(hashType uint32, scriptCode *script.Script) (*big.Int, error) {
+		hash, err := t.SigHash(index, hashType, scriptCode, fetcher)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(hash), nil
+	}
+	program := scriptPubkey
+	if redeemScript != nil {
+		program = redeemScript
+	}
+	if version, witnessProgram, ok := program.WitnessProgram(); ok && version == 0 && flags&script.SCRIPT_VERIFY_WITNESS != 0 {
+		// NOTE: witness programの署名はBIP143の方式で計算する。P2WSHではscriptCodeは実行中のwitness script
+		sigHash = func(hashType uint32, scriptCode *script.Script) (*big.Int, error) {
+			var witnessScript *script.Script
+			if len(witnessProgram) == 32 {
+				witnessScript = scriptCode
+			}
+			hash, err := t.SigHashBIP143(index, hashType, redeemScript, witnessScript, fetcher)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Int).SetBytes(hash), nil
+		}
+	}
+	taprootSigHash := func(hashType uint32, leafHash []byte, codeSepPos uint32) ([]byte, error) {
+		return t.SigHashTaproot(index, hashType, leafHash, codeSepPos, fetcher)
+	}
+	ctx := &script.EvalContext{
+		SigHash:        sigHash,
+		TaprootSigHash: taprootSigHash,
+		Version:        t.Version,
+		LockTime:       t.Locktime,
+		Sequence:       t.Inputs[index].Sequence,
+		Flags:          flags,
+	}
+	return script.VerifyScript(t.Inputs[index].ScriptSig, scriptPubkey, t.Inputs[index].Witness, ctx)
+}
+
+func (t *Transaction) Verify(fetcher OutputFetcher) error {
+	if _, err := t.Fee(fetcher); err != nil {
+		return err
 	}
 	for i := 0; i < len(t.Inputs); i++ {
-		err := t.VerifyInput(i, testnet)
+		err := t.VerifyInput(i, fetcher)
 		if err != nil {
 			return err
 		}
@@ -242,7 +571,7 @@ func (t *Transaction) Verify(testnet bool) error {
 }
 
 func NewInput(previousOutputHash []byte, previousOutputIndex uint32, scriptSig *script.Script, sequence uint32) *Input {
-	return &Input{previousOutputHash, previousOutputIndex, scriptSig, sequence}
+	return &Input{previousOutputHash, previousOutputIndex, scriptSig, sequence, nil}
 }
 
 func ParseInput(reader io.Reader) (*Input, error) {
@@ -274,7 +603,7 @@ func ParseInput(reader io.Reader) (*Input, error) {
 	}
 	sequence := binary.LittleEndian.Uint32(buf)
 
-	return &Input{previousOutputHash, previousOutputIndex, scriptSig, sequence}, nil
+	return &Input{previousOutputHash, previousOutputIndex, scriptSig, sequence, nil}, nil
 }
 
 func (i *Input) Serialize() []byte {
@@ -308,22 +637,61 @@ func (i *Input) Serialize() []byte {
 	return serialized
 }
 
-func (i *Input) Value(testnet bool) (uint64, error) {
-	fetcher := NewTransactionFetcher(testnet)
-	tx, err := fetcher.FetchTransaction(hex.EncodeToString(i.PreviousOutputHash), false)
+// NOTE: 要素数や長さは信用できないので、先に確保せずに読めた分だけ確保する
+func ParseWitness(reader io.Reader) ([][]byte, error) {
+	numItems, err := utils.ParseVarInt(reader)
+	if err != nil {
+		return nil, err
+	}
+	witness := make([][]byte, 0)
+	for j := uint64(0); j < numItems; j++ {
+		itemLen, err := utils.ParseVarInt(reader)
+		if err != nil {
+			return nil, err
+		}
+		item, err := utils.ReadBytes(reader, itemLen)
+		if err != nil {
+			return nil, err
+		}
+		witness = append(witness, item)
+	}
+	return witness, nil
+}
+
+func (i *Input) SerializeWitness() []byte {
+	var serialized []byte
+
+	numItems, err := utils.SerializeVarInt(uint64(len(i.Witness)))
+	if err != nil {
+		return nil
+	}
+	serialized = append(serialized, numItems...)
+	for _, item := range i.Witness {
+		itemLen, err := utils.SerializeVarInt(uint64(len(item)))
+		if err != nil {
+			return nil
+		}
+		serialized = append(serialized, itemLen...)
+		serialized = append(serialized, item...)
+	}
+
+	return serialized
+}
+
+func (i *Input) Value(fetcher OutputFetcher) (uint64, error) {
+	output, err := fetcher.FetchOutput(hex.EncodeToString(i.PreviousOutputHash), i.PreviousOutputIndex)
 	if err != nil {
 		return 0, err
 	}
-	return tx.Outputs[i.PreviousOutputIndex].Value, nil
+	return output.Value, nil
 }
 
-func (i *Input) ScriptPubKey(testnet bool) (*script.Script, error) {
-	fetcher := NewTransactionFetcher(testnet)
-	tx, err := fetcher.FetchTransaction(hex.EncodeToString(i.PreviousOutputHash), false)
+func (i *Input) ScriptPubKey(fetcher OutputFetcher) (*script.Script, error) {
+	output, err := fetcher.FetchOutput(hex.EncodeToString(i.PreviousOutputHash), i.PreviousOutputIndex)
 	if err != nil {
 		return nil, err
 	}
-	return tx.Outputs[i.PreviousOutputIndex].ScriptPubKey, nil
+	return output.ScriptPubKey, nil
 }
 
 func NewOutput(value uint64, scriptPubKey *script.Script) *Output {
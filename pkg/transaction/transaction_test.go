@@ -0,0 +1,1244 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang-bitcoin/pkg/privkey"
+	"golang-bitcoin/pkg/script"
+	"golang-bitcoin/pkg/utils"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	// NOTE: プログラミング・ビットコイン 5章のトランザクション
+	legacyTxHex = "0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600"
+	// NOTE: scriptSigが 0x64, 0x00, 0x51 の1バイトのpushと最短でない OP_PUSHDATA1 のpushを含むトランザクション
+	nonMinimalPushTxHex = "0100000001111111111111111111111111111111111111111111111111111111111111111100000000090164010001514c0101ffffffff01e803000000000000015100000000"
+	// NOTE: BIP143 Native P2WPKHの例
+	segwitTxHex = "01000000000102fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f00000000494830450221008b9d1dc26ba6a9cb62127b02742fa9d754cd3bebf337f7a55d114c8e5cdd30be022040529b194ba3f9281a99f2b1c0a19c0489bc22ede944ccf4ecbab4cc618ef3ed01eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac000247304402203609e17b84f6a7d30c80bfa610b5b4542f32a8a0d5447a12fb1366d7f01cc44a0220573a954c4518331561406f90300e8f3358f51928d43c212a8caed02de67eebee0121025476c2e83188368da1ff3e292e7acafcdb3566bb0ad253f62fc70f07aeee635711000000"
+)
+
+func TestParseTransaction(t *testing.T) {
+	type want struct {
+		isSegwit    bool
+		numInputs   int
+		numOutputs  int
+		witnessLens []int
+		locktime    uint32
+	}
+	tests := []struct {
+		name  string
+		rawTx string
+		want  want
+	}{
+		{
+			name:  "legacy",
+			rawTx: legacyTxHex,
+			want: want{
+				isSegwit:    false,
+				numInputs:   1,
+				numOutputs:  2,
+				witnessLens: []int{0},
+				locktime:    410393,
+			},
+		},
+		{
+			name:  "segwit",
+			rawTx: segwitTxHex,
+			want: want{
+				isSegwit:    true,
+				numInputs:   2,
+				numOutputs:  2,
+				witnessLens: []int{0, 2},
+				locktime:    17,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.rawTx)
+			tx, err := ParseTransaction(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("ParseTransaction() error = %v", err)
+			}
+			if tx.IsSegwit != tt.want.isSegwit {
+				t.Errorf("Transaction.IsSegwit = %v, want %v", tx.IsSegwit, tt.want.isSegwit)
+			}
+			if len(tx.Inputs) != tt.want.numInputs {
+				t.Fatalf("len(Transaction.Inputs) = %v, want %v", len(tx.Inputs), tt.want.numInputs)
+			}
+			if len(tx.Outputs) != tt.want.numOutputs {
+				t.Errorf("len(Transaction.Outputs) = %v, want %v", len(tx.Outputs), tt.want.numOutputs)
+			}
+			gotWitnessLens := make([]int, len(tx.Inputs))
+			for i, input := range tx.Inputs {
+				gotWitnessLens[i] = len(input.Witness)
+			}
+			if !reflect.DeepEqual(gotWitnessLens, tt.want.witnessLens) {
+				t.Errorf("witness lengths = %v, want %v", gotWitnessLens, tt.want.witnessLens)
+			}
+			if tx.Locktime != tt.want.locktime {
+				t.Errorf("Transaction.Locktime = %v, want %v", tx.Locktime, tt.want.locktime)
+			}
+		})
+	}
+}
+
+// NOTE: 個数や長さが入力の残りより大きい場合は、確保する前にエラーになる
+func TestParseTransaction_Malformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		rawTx string
+	}{
+		{
+			name:  "too many inputs",
+			rawTx: "01000000ffffffffffffffffff",
+		},
+		{
+			name:  "script length exceeds input",
+			rawTx: "0100000001111111111111111111111111111111111111111111111111111111111111111100000000ffffffffffffffffff",
+		},
+		{
+			name:  "pushdata4 length exceeds script",
+			rawTx: "0100000001111111111111111111111111111111111111111111111111111111111111111100000000054effffffffffffffff",
+		},
+		{
+			name:  "too many witness items",
+			rawTx: "0100000000010111111111111111111111111111111111111111111111111111111111111111110000000000ffffffff01e8030000000000000151ffffffffffffffffff",
+		},
+		{
+			name:  "witness item length exceeds input",
+			rawTx: "0100000000010111111111111111111111111111111111111111111111111111111111111111110000000000ffffffff01e803000000000000015101feffffffff",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.rawTx)
+			if _, err := ParseTransaction(bytes.NewReader(raw)); err == nil {
+				t.Errorf("ParseTransaction() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestTransaction_Serialize(t *testing.T) {
+	tests := []struct {
+		name  string
+		rawTx string
+	}{
+		{
+			name:  "legacy round trip",
+			rawTx: legacyTxHex,
+		},
+		{
+			name:  "segwit round trip",
+			rawTx: segwitTxHex,
+		},
+		{
+			name:  "non-minimal push round trip",
+			rawTx: nonMinimalPushTxHex,
+		},
+		{
+			name:  "unparsable scriptPubKey round trip",
+			rawTx: bip341TxHex,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.rawTx)
+			tx, err := ParseTransaction(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("ParseTransaction() error = %v", err)
+			}
+			got, err := tx.Serialize()
+			if err != nil {
+				t.Fatalf("Transaction.Serialize() error = %v", err)
+			}
+			if !bytes.Equal(got, raw) {
+				t.Errorf("Transaction.Serialize() = %x, want %x", got, raw)
+			}
+		})
+	}
+}
+
+func TestTransaction_ID(t *testing.T) {
+	tests := []struct {
+		name          string
+		rawTx         string
+		wantGenerator func(raw []byte) (string, string)
+	}{
+		{
+			name:  "legacy",
+			rawTx: legacyTxHex,
+			wantGenerator: func(raw []byte) (string, string) {
+				id := "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+				return id, id
+			},
+		},
+		{
+			name:  "segwit",
+			rawTx: segwitTxHex,
+			wantGenerator: func(raw []byte) (string, string) {
+				// NOTE: witnessを除いたトランザクションを組み立てて比較する
+				//       witnessは 00 + 02 47<署名> 21<公開鍵> の108バイト
+				stripped := append([]byte{}, raw[:4]...)
+				stripped = append(stripped, raw[6:len(raw)-4-108]...)
+				stripped = append(stripped, raw[len(raw)-4:]...)
+				id := hex.EncodeToString(utils.ReverseBytes(utils.Hash256(stripped)))
+				wid := hex.EncodeToString(utils.ReverseBytes(utils.Hash256(raw)))
+				return id, wid
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.rawTx)
+			wantID, wantWitnessID := tt.wantGenerator(raw)
+			tx, err := ParseTransaction(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("ParseTransaction() error = %v", err)
+			}
+			if got, _ := tx.ID(); got != wantID {
+				t.Errorf("Transaction.ID() = %v, want %v", got, wantID)
+			}
+			if got, _ := tx.WitnessID(); got != wantWitnessID {
+				t.Errorf("Transaction.WitnessID() = %v, want %v", got, wantWitnessID)
+			}
+		})
+	}
+}
+
+func parseScriptHex(scriptHex string) *script.Script {
+	raw, _ := hex.DecodeString(scriptHex)
+	length, _ := utils.SerializeVarInt(uint64(len(raw)))
+	s, _ := script.ParseScript(bytes.NewReader(append(length, raw...)))
+	return s
+}
+
+func TestTransaction_Fee(t *testing.T) {
+	tests := []struct {
+		name         string
+		inputValues  []uint64
+		outputValues []uint64
+		want         uint64
+		wantErr      bool
+	}{
+		{name: "positive fee", inputValues: []uint64{5000, 3000}, outputValues: []uint64{4000, 2500}, want: 1500},
+		{name: "zero fee", inputValues: []uint64{5000}, outputValues: []uint64{5000}, want: 0},
+		{name: "outputs exceed inputs", inputValues: []uint64{5000}, outputValues: []uint64{4000, 1001}, wantErr: true},
+		{name: "input sum overflows", inputValues: []uint64{math.MaxUint64, 1}, outputValues: []uint64{1}, wantErr: true},
+		{name: "output sum overflows", inputValues: []uint64{5000}, outputValues: []uint64{math.MaxUint64, 5001}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewMemoryOutputFetcher()
+			inputs := make([]*Input, len(tt.inputValues))
+			for i, value := range tt.inputValues {
+				prevHash := bytes.Repeat([]byte{byte(i + 1)}, 32)
+				inputs[i] = NewInput(prevHash, 0, script.NewScript(), 0xffffffff)
+				fetcher.AddOutput(hex.EncodeToString(prevHash), 0, NewOutput(value, script.NewScript()))
+			}
+			outputs := make([]*Output, len(tt.outputValues))
+			for i, value := range tt.outputValues {
+				outputs[i] = NewOutput(value, script.NewScript())
+			}
+			tx := NewTransaction(1, inputs, outputs, 0, false)
+			got, err := tx.Fee(fetcher)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transaction.Fee() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Transaction.Fee() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransaction_SigHashBIP143(t *testing.T) {
+	// NOTE: BIP143 P2SH-P2WSHの例。6-of-6のmultisigを6種類のhash typeで署名する
+	p2shP2wshTxHex := "010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000"
+	p2shP2wshWitnessScript := parseScriptHex("56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae")
+	p2shP2wshRedeemScript := parseScriptHex("0020a16b5755f7f6f96dbd65f5f0d6ab9418b89af4b1f14a1bb8a09062c35f0dcb54")
+	p2shP2wshFetcher := func(tx *Transaction) OutputFetcher {
+		fetcher := NewMemoryOutputFetcher()
+		redeemScript, _ := p2shP2wshRedeemScript.Serialize()
+		fetcher.AddOutput(hex.EncodeToString(tx.Inputs[0].PreviousOutputHash), tx.Inputs[0].PreviousOutputIndex, NewOutput(987654321, script.NewP2SHScriptFromHash160(utils.Hash160(redeemScript))))
+		return fetcher
+	}
+
+	type args struct {
+		index         int
+		hashType      uint32
+		redeemScript  *script.Script
+		witnessScript *script.Script
+	}
+	tests := []struct {
+		name             string
+		rawTx            string
+		fetcherGenerator func(tx *Transaction) OutputFetcher
+		args             args
+		want             string
+	}{
+		{
+			name:  "native p2wpkh",
+			rawTx: segwitTxHex,
+			fetcherGenerator: func(tx *Transaction) OutputFetcher {
+				fetcher := NewMemoryOutputFetcher()
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[1].PreviousOutputHash), tx.Inputs[1].PreviousOutputIndex, NewOutput(600000000, parseScriptHex("00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1")))
+				return fetcher
+			},
+			args: args{
+				index:    1,
+				hashType: script.SIGHASH_ALL,
+			},
+			want: "c37af31116d1b27caf68aae9e3ac82f1477929014d5b917657d0eb49478cb670",
+		},
+		{
+			name:  "p2sh-p2wpkh",
+			rawTx: "0100000001db6b1b20aa0fd7b23880be2ecbd4a98130974cf4748fb66092ac4d3ceb1a54770100000000feffffff02b8b4eb0b000000001976a914a457b684d7f0d539a46a45bbc043f35b59d0d96388ac0008af2f000000001976a914fd270b1ee6abcaea97fea7ad0402e8bd8ad6d77c88ac92040000",
+			fetcherGenerator: func(tx *Transaction) OutputFetcher {
+				fetcher := NewMemoryOutputFetcher()
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[0].PreviousOutputHash), tx.Inputs[0].PreviousOutputIndex, NewOutput(1000000000, parseScriptHex("a9144733f37cf4db86fbc2efed2500b4f4e49f31202387")))
+				return fetcher
+			},
+			args: args{
+				index:        0,
+				hashType:     script.SIGHASH_ALL,
+				redeemScript: parseScriptHex("001479091972186c449eb1ded22b78e40d009bdf0089"),
+			},
+			want: "64f3b0f4dd2bb3aa1ce8566d220cc74dda9df97d8490cc81d89d735c92e59fb6",
+		}, {
+			name:             "p2sh-p2wsh 6-of-6 sighash all",
+			rawTx:            p2shP2wshTxHex,
+			fetcherGenerator: p2shP2wshFetcher,
+			args: args{
+				index:         0,
+				hashType:      script.SIGHASH_ALL,
+				redeemScript:  p2shP2wshRedeemScript,
+				witnessScript: p2shP2wshWitnessScript,
+			},
+			want: "185c0be5263dce5b4bb50a047973c1b6272bfbd0103a89444597dc40b248ee7c",
+		},
+		{
+			name:             "p2sh-p2wsh 6-of-6 sighash none",
+			rawTx:            p2shP2wshTxHex,
+			fetcherGenerator: p2shP2wshFetcher,
+			args: args{
+				index:         0,
+				hashType:      script.SIGHASH_NONE,
+				redeemScript:  p2shP2wshRedeemScript,
+				witnessScript: p2shP2wshWitnessScript,
+			},
+			want: "e9733bc60ea13c95c6527066bb975a2ff29a925e80aa14c213f686cbae5d2f36",
+		},
+		{
+			name:             "p2sh-p2wsh 6-of-6 sighash single",
+			rawTx:            p2shP2wshTxHex,
+			fetcherGenerator: p2shP2wshFetcher,
+			args: args{
+				index:         0,
+				hashType:      script.SIGHASH_SINGLE,
+				redeemScript:  p2shP2wshRedeemScript,
+				witnessScript: p2shP2wshWitnessScript,
+			},
+			want: "1e1f1c303dc025bd664acb72e583e933fae4cff9148bf78c157d1e8f78530aea",
+		},
+		{
+			name:             "p2sh-p2wsh 6-of-6 sighash all anyonecanpay",
+			rawTx:            p2shP2wshTxHex,
+			fetcherGenerator: p2shP2wshFetcher,
+			args: args{
+				index:         0,
+				hashType:      script.SIGHASH_ALL | script.SIGHASH_ANYONECANPAY,
+				redeemScript:  p2shP2wshRedeemScript,
+				witnessScript: p2shP2wshWitnessScript,
+			},
+			want: "2a67f03e63a6a422125878b40b82da593be8d4efaafe88ee528af6e5a9955c6e",
+		},
+		{
+			name:             "p2sh-p2wsh 6-of-6 sighash none anyonecanpay",
+			rawTx:            p2shP2wshTxHex,
+			fetcherGenerator: p2shP2wshFetcher,
+			args: args{
+				index:         0,
+				hashType:      script.SIGHASH_NONE | script.SIGHASH_ANYONECANPAY,
+				redeemScript:  p2shP2wshRedeemScript,
+				witnessScript: p2shP2wshWitnessScript,
+			},
+			want: "781ba15f3779d5542ce8ecb5c18716733a5ee42a6f51488ec96154934e2c890a",
+		},
+		{
+			name:             "p2sh-p2wsh 6-of-6 sighash single anyonecanpay",
+			rawTx:            p2shP2wshTxHex,
+			fetcherGenerator: p2shP2wshFetcher,
+			args: args{
+				index:         0,
+				hashType:      script.SIGHASH_SINGLE | script.SIGHASH_ANYONECANPAY,
+				redeemScript:  p2shP2wshRedeemScript,
+				witnessScript: p2shP2wshWitnessScript,
+			},
+			want: "511e8e52ed574121fc1b654970395502128263f62662e076dc6baf05c2e6a99b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.rawTx)
+			tx, err := ParseTransaction(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("ParseTransaction() error = %v", err)
+			}
+			got, err := tx.SigHashBIP143(tt.args.index, tt.args.hashType, tt.args.redeemScript, tt.args.witnessScript, tt.fetcherGenerator(tx))
+			if err != nil {
+				t.Fatalf("Transaction.SigHashBIP143() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("Transaction.SigHashBIP143() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransaction_SigHash(t *testing.T) {
+	raw, _ := hex.DecodeString(segwitTxHex)
+	tests := []struct {
+		name     string
+		index    int
+		hashType uint32
+		want     string
+	}{
+		{
+			name:     "sighash single without matching output",
+			index:    2,
+			hashType: script.SIGHASH_SINGLE,
+			want:     "0100000000000000000000000000000000000000000000000000000000000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, _ := ParseTransaction(bytes.NewReader(raw))
+			// NOTE: 対応するoutputがない位置にinputを追加する
+			tx.Inputs = append(tx.Inputs, NewInput(tx.Inputs[0].PreviousOutputHash, 2, script.NewScript(), 0xffffffff))
+			got, err := tx.SigHash(tt.index, tt.hashType, nil, NewMemoryOutputFetcher())
+			if err != nil {
+				t.Fatalf("Transaction.SigHash() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("Transaction.SigHash() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// NOTE: Bitcoin Coreのsighash.jsonから選んだケース。hashTypeは符号付きで、期待値はバイト順を反転した表記
+func TestTransaction_SigHashCoreVectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		rawTx      string
+		scriptCode string
+		index      int
+		hashType   int32
+		want       string
+	}{
+		{"sighash all", "cf7bdc250249e22cbe23baf6b648328d31773ea0e771b3b76a48b4748d7fbd390e88a004d30000000003ac536a4ab8cce0e097136c90b2037f231b7fde2063017facd40ed4e5896da7ad00e9c71dd70ae600000000096a0063516352525365ffffffff01b71e3e00000000000300536a00000000", "", 1, 546970113, "6a815ba155270af102322c882f26d22da11c5330a751f520807936b320b9af5d"},
+		{"sighash all anyonecanpay", "df0a32ae01c4672fd1abd0b2623aae0a1a8256028df57e532f9a472d1a9ceb194267b6ee190200000009536a6a51516a525251b545f9e803469a2302000000000465526500810631040000000000441f5b050000000006530051006aaceb183c76", "536a635252ac6a", 0, 1601138113, "9a0435996cc58bdba09643927fe48c1fc908d491a050abbef8daec87f323c58f"},
+		{"sighash none", "97be4f7702dc20b087a1fdd533c7de762a3f2867a8f439bddf0dcec9a374dfd0276f9c55cc0300000000cdfb1dbe6582499569127bda6ca4aaff02c132dc73e15dcd91d73da77e92a32a13d1a0ba0200000002ab51ffffffff048cfbe202000000000900516351515363ac535128ce0100000000076aac5365ab6aabc84e8302000000000863536a53ab6a6552f051230500000000066aac535153510848d813", "ac51", 0, 229541474, "e5da9a416ea883be1f8b8b2d178463633f19de3fa82ae25d44ffb531e35bdbc8"},
+		{"sighash none anyonecanpay", "b3cad3a7041c2c17d90a2cd994f6c37307753fa3635e9ef05ab8b1ff121ca11239a0902e700300000009ab635300006aac5163ffffffffcec91722c7468156dce4664f3c783afef147f0e6f80739c83b5f09d5a09a57040200000004516a6552ffffffff969d1c6daf8ef53a70b7cdf1b4102fb3240055a8eaeaed2489617cd84cfd56cf020000000352ab53ffffffff46598b6579494a77b593681c33422a99559b9993d77ca2fa97833508b0c169f80200000009655300655365516351ffffffff04d7ddf800000000000853536a65ac6351ab09f3420300000000056aab65abac33589d04000000000952656a65655151acac944d6f0400000000006a8004ba", "005165", 1, 1035865506, "fe1dc9e8554deecf8f50c417c670b839cc9d650722ebaaf36572418756075d58"},
+		{"sighash single", "6f62138301436f33a00b84a26a0457ccbfc0f82403288b9cbae39986b34357cb2ff9b889b302000000045253655335a7ff6701bac9960400000000086552ab656352635200000000", "6aac51", 0, 1444414211, "502a2435fd02898d2ff3ab08a3c19078414b32ec9b73d64a944834efc9dae10c"},
+		{"sighash single anyonecanpay", "9ff618e60136f8e6bb7eabaaac7d6e2535f5fba95854be6d2726f986eaa9537cb283c701ff02000000026a65ffffffff012d1c0905000000000865ab00ac6a516a652f9ad240", "51515253635351ac", 0, 1571304387, "659cd3203095d4a8672646add7d77831a1926fc5b66128801979939383695a79"},
+		{"undefined base type", "fea256ce01272d125e577c0a09570a71366898280dda279b021000db1325f27edda41a53460100000002ab53c752c21c013c2b3a01000000000000000000", "65", 0, 1145543262, "076b9f844f6ae429de228a2c337c704df1652c292b6c6494882190638dad9efd"},
+		{"undefined base type anyonecanpay", "eabc0aa701fe489c0e4e6222d72b52f083166b49d63ad1410fb98caed027b6a71c02ab830c03000000075253ab63530065ffffffff01a5dc0b05000000000253533e820177", "", 0, 954499283, "1d849b92eedb9bf26bd4ced52ce9cb0595164295b0526842ab1096001fcd31b1"},
+		{"negative hash type", "e3cdbfb4014d90ae6a4401e85f7ac717adc2c035858bf6ff48979dd399d155bce1f150daea0300000002ac51a67a0d39017f6c71040000000005535200535200000000", "", 0, -1899950911, "c1c7df8206e661d593f6455db1d61a364a249407f88e99ecad05346e495b38d7"},
+		{"code separators in script code", "720d4693025ca3d347360e219e9bc746ef8f7bc88e8795162e5e2f0b0fc99dc17116fc937100000000046353520045cb1fd79824a100d30b6946eab9b219daea2b0cdca6c86367c0c36af98f19ac64f3575002000000008a1c881003ed16f3050000000008536a63630000abac45e0e704000000000151f6551a05000000000963536565515363abab00000000", "6553ab6a6a510000ab", 1, 1249091393, "a575fa4f59a8e90cd07de012c78fe8f981183bb170b9c50fcc292b8c164cbc3b"},
+		{"code separator before checksig", "5c45d09801bb4d8e7679d857b86b97697472d514f8b76d862460e7421e8617b15a2df217c6010000000863acacab6565006affffffff01156dbc03000000000952ac63516551ac6aac00000000", "6aabac", 0, 1310125891, "270445ab77258ced2e5e22a6d0d8c36ac7c30fff9beefa4b3e981867b03fa0ad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := parseTxHex(tt.rawTx)
+			got, err := tx.SigHash(tt.index, uint32(tt.hashType), parseScriptHex(tt.scriptCode), nil)
+			if err != nil {
+				t.Fatalf("Transaction.SigHash() error = %v", err)
+			}
+			if hex.EncodeToString(utils.ReverseBytes(got)) != tt.want {
+				t.Errorf("Transaction.SigHash() = %x, want %v", utils.ReverseBytes(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestTransaction_VerifyInput(t *testing.T) {
+	raw, _ := hex.DecodeString(segwitTxHex)
+	// NOTE: BIP143の例の1つ目のinputはP2PK
+	p2pkSecret, _ := new(big.Int).SetString("bbc27228ddcb9209d7fd6f36b02f7dfa6252af40bb2f1cbc7a557da8027ff866", 16)
+	p2pkScriptPubKey := parseScriptHex("2103c9f4836b9a4f77fc0d81f7bcb01b7f1b35916864b9476c241ce9fc198bd25432ac")
+	tests := []struct {
+		name        string
+		txGenerator func(fetcher OutputFetcher) *Transaction
+		wantErr     bool
+	}{
+		{
+			name: "p2pk with sighash all",
+			txGenerator: func(fetcher OutputFetcher) *Transaction {
+				tx, _ := ParseTransaction(bytes.NewReader(raw))
+				return tx
+			},
+			wantErr: false,
+		},
+		{
+			name: "p2pk resigned with sighash none | anyonecanpay",
+			txGenerator: func(fetcher OutputFetcher) *Transaction {
+				tx, _ := ParseTransaction(bytes.NewReader(raw))
+				hashType := uint32(script.SIGHASH_NONE | script.SIGHASH_ANYONECANPAY)
+				z, _ := tx.SigHash(0, hashType, nil, fetcher)
+				sig := privkey.NewPrivKey(p2pkSecret).Sign(new(big.Int).SetBytes(z))
+				tx.Inputs[0].ScriptSig = script.NewScript()
+				tx.Inputs[0].ScriptSig.Instructions = append(tx.Inputs[0].ScriptSig.Instructions, script.NewPushInstruction(append(sig.Serialize(), byte(hashType))))
+				// NOTE: 署名対象外のoutputを変更しても検証は成功する
+				tx.Outputs = tx.Outputs[:1]
+				return tx
+			},
+			wantErr: false,
+		},
+		{
+			name: "p2pk with modified output",
+			txGenerator: func(fetcher OutputFetcher) *Transaction {
+				tx, _ := ParseTransaction(bytes.NewReader(raw))
+				tx.Outputs[0].Value -= 1
+				return tx
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewMemoryOutputFetcher()
+			tx, _ := ParseTransaction(bytes.NewReader(raw))
+			fetcher.AddOutput(hex.EncodeToString(tx.Inputs[0].PreviousOutputHash), tx.Inputs[0].PreviousOutputIndex, NewOutput(625000000, p2pkScriptPubKey))
+			tx = tt.txGenerator(fetcher)
+			if err := tx.VerifyInput(0, fetcher); (err != nil) != tt.wantErr {
+				t.Errorf("Transaction.VerifyInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransaction_VerifyInputP2SH(t *testing.T) {
+	// NOTE: 2-of-2 multisigのredeem scriptを使うP2SHのinput
+	p2shTxHex := "0100000001868278ed6ddfb6c1ed3ad5f8181eb0c7a385aa0836f01d5e4789e6bd304d87221a000000db00483045022100dc92655fe37036f47756db8102e0d7d5e28b3beb83a8fef4f5dc0559bddfb94e02205a36d4e4e6c7fcd16658c50783e00c341609977aed3ad00937bf4ee942a8993701483045022100da6bee3c93766232079a01639d07fa869598749729ae323eab8eef53577d611b02207bef15429dcadce2121ea07f233115c6f09034c0be68db99980b9a6c5e75402201475221022626e955ea6ea6d98850c994f9107b036b1334f18ca8830bfff1295d21cfdb702103b287eaf122eea69030a0e9feed096bed8045c8b98bec453e1ffac7fbdbd4bb7152aeffffffff04d3b11400000000001976a914904a49878c0adfc3aa05de7afad2cc15f483a56a88ac7f400900000000001976a914418327e3f3dda4cf5b9089325a4b95abdfa0334088ac722c0c00000000001976a914ba35042cfe9fc66fd35ac2224eebdafd1028ad2788acdc4ace020000000017a91474d691da1574e6b3c192ecfb52cc8984ee7b6c568700000000"
+	p2shScriptPubKey := parseScriptHex("a91474d691da1574e6b3c192ecfb52cc8984ee7b6c5687")
+	tests := []struct {
+		name        string
+		txGenerator func() *Transaction
+		flags       script.VerifyFlags
+		wantErr     bool
+	}{
+		{
+			name: "2 of 2 multisig redeem script",
+			txGenerator: func() *Transaction {
+				return parseTxHex(p2shTxHex)
+			},
+			flags:   script.DEFAULT_VERIFY_FLAGS,
+			wantErr: false,
+		},
+		{
+			name: "redeem script is not executed without p2sh flag",
+			txGenerator: func() *Transaction {
+				tx := parseTxHex(p2shTxHex)
+				// NOTE: redeem scriptを実行すれば失敗するように署名を取り除く
+				instructions := tx.Inputs[0].ScriptSig.Instructions
+				tx.Inputs[0].ScriptSig = script.NewScript()
+				tx.Inputs[0].ScriptSig.Instructions = []script.Instruction{instructions[0], instructions[3]}
+				return tx
+			},
+			// NOTE: ハッシュの一致のみを確認する。Stackに要素が残っても一番上が真なら有効
+			flags:   script.SCRIPT_VERIFY_NONE,
+			wantErr: false,
+		},
+		{
+			name: "redeem script with missing signature",
+			txGenerator: func() *Transaction {
+				tx := parseTxHex(p2shTxHex)
+				instructions := tx.Inputs[0].ScriptSig.Instructions
+				tx.Inputs[0].ScriptSig = script.NewScript()
+				tx.Inputs[0].ScriptSig.Instructions = []script.Instruction{instructions[0], instructions[1], instructions[1], instructions[3]}
+				return tx
+			},
+			flags:   script.DEFAULT_VERIFY_FLAGS,
+			wantErr: true,
+		},
+		{
+			name: "scriptSig is not push only",
+			txGenerator: func() *Transaction {
+				tx := parseTxHex(p2shTxHex)
+				instructions := tx.Inputs[0].ScriptSig.Instructions
+				tx.Inputs[0].ScriptSig = script.NewScript()
+				tx.Inputs[0].ScriptSig.Instructions = []script.Instruction{instructions[0], instructions[1], instructions[2], script.NewOpInstruction(script.OP_NOP), instructions[3]}
+				return tx
+			},
+			flags:   script.DEFAULT_VERIFY_FLAGS,
+			wantErr: true,
+		},
+		{
+			name: "modified output",
+			txGenerator: func() *Transaction {
+				tx := parseTxHex(p2shTxHex)
+				tx.Outputs[0].Value -= 1
+				return tx
+			},
+			flags:   script.DEFAULT_VERIFY_FLAGS,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := tt.txGenerator()
+			fetcher := NewMemoryOutputFetcher()
+			fetcher.AddOutput(hex.EncodeToString(tx.Inputs[0].PreviousOutputHash), tx.Inputs[0].PreviousOutputIndex, NewOutput(0, p2shScriptPubKey))
+			if err := tx.VerifyInputWithFlags(0, fetcher, tt.flags); (err != nil) != tt.wantErr {
+				t.Errorf("Transaction.VerifyInputWithFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransaction_VerifyInputWitness(t *testing.T) {
+	// NOTE: BIP143 P2SH-P2WPKHの例
+	p2shP2wpkhTxHex := "01000000000101db6b1b20aa0fd7b23880be2ecbd4a98130974cf4748fb66092ac4d3ceb1a5477010000001716001479091972186c449eb1ded22b78e40d009bdf0089feffffff02b8b4eb0b000000001976a914a457b684d7f0d539a46a45bbc043f35b59d0d96388ac0008af2f000000001976a914fd270b1ee6abcaea97fea7ad0402e8bd8ad6d77c88ac02473044022047ac8e878352d3ebbde1c94ce3a10d057c24175747116f8288e5d794d12d482f0220217f36a485cae903c713331d877c1f64677e3622ad4010726870540656fe9dcb012103ad1d8e89212f0b92c74d23bb710c00662ad1470198ac48c43f7d6f93a2a2687392040000"
+
+	// NOTE: 2-of-2 multisigのwitness scriptを使うP2WSH
+	keys := []privkey.PrivKey{privkey.NewPrivKey(big.NewInt(1001)), privkey.NewPrivKey(big.NewInt(1002))}
+	witnessScript := script.NewScript()
+	witnessScript.Instructions = []script.Instruction{script.NewOpInstruction(script.OP_2), script.NewPushInstruction(keys[0].PubKey().Serialize(true)), script.NewPushInstruction(keys[1].PubKey().Serialize(true)), script.NewOpInstruction(script.OP_2), script.NewOpInstruction(script.OP_CHECKMULTISIG)}
+	rawWitnessScript, _ := witnessScript.Serialize()
+	witnessScriptHash := sha256.Sum256(rawWitnessScript)
+	p2wshScriptPubKey := script.NewScript()
+	p2wshScriptPubKey.Instructions = []script.Instruction{script.NewOpInstruction(script.OP_0), script.NewPushInstruction(witnessScriptHash[:])}
+	p2wshTx := func(fetcher *MemoryOutputFetcher) *Transaction {
+		input := NewInput(bytes.Repeat([]byte{0x11}, 32), 0, script.NewScript(), 0xffffffff)
+		tx := NewTransaction(2, []*Input{input}, []*Output{NewOutput(90000, parseScriptHex("76a9141c4bc762dd5423e332166702cb75f40df79fea1288ac"))}, 0, true)
+		fetcher.AddOutput(hex.EncodeToString(input.PreviousOutputHash), 0, NewOutput(100000, p2wshScriptPubKey))
+		z, _ := tx.SigHashBIP143(0, script.SIGHASH_ALL, nil, witnessScript, fetcher)
+		input.Witness = [][]byte{{}}
+		for _, key := range keys {
+			input.Witness = append(input.Witness, append(key.Sign(new(big.Int).SetBytes(z)).Serialize(), script.SIGHASH_ALL))
+		}
+		input.Witness = append(input.Witness, rawWitnessScript)
+		return tx
+	}
+
+	tests := []struct {
+		name        string
+		txGenerator func(fetcher *MemoryOutputFetcher) (*Transaction, int)
+		wantErr     bool
+	}{
+		{
+			name: "p2wpkh",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := parseTxHex(segwitTxHex)
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[1].PreviousOutputHash), tx.Inputs[1].PreviousOutputIndex, NewOutput(600000000, parseScriptHex("00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1")))
+				return tx, 1
+			},
+			wantErr: false,
+		},
+		{
+			name: "p2wpkh with modified output",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := parseTxHex(segwitTxHex)
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[1].PreviousOutputHash), tx.Inputs[1].PreviousOutputIndex, NewOutput(600000000, parseScriptHex("00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1")))
+				tx.Outputs[0].Value -= 1
+				return tx, 1
+			},
+			wantErr: true,
+		},
+		{
+			name: "p2wpkh with wrong amount",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := parseTxHex(segwitTxHex)
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[1].PreviousOutputHash), tx.Inputs[1].PreviousOutputIndex, NewOutput(600000001, parseScriptHex("00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1")))
+				return tx, 1
+			},
+			wantErr: true,
+		},
+		{
+			name: "p2wpkh with non-empty scriptSig",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := parseTxHex(segwitTxHex)
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[1].PreviousOutputHash), tx.Inputs[1].PreviousOutputIndex, NewOutput(600000000, parseScriptHex("00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1")))
+				tx.Inputs[1].ScriptSig.Instructions = []script.Instruction{script.NewOpInstruction(script.OP_1)}
+				return tx, 1
+			},
+			wantErr: true,
+		},
+		{
+			name: "p2sh-p2wpkh",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := parseTxHex(p2shP2wpkhTxHex)
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[0].PreviousOutputHash), tx.Inputs[0].PreviousOutputIndex, NewOutput(1000000000, parseScriptHex("a9144733f37cf4db86fbc2efed2500b4f4e49f31202387")))
+				return tx, 0
+			},
+			wantErr: false,
+		},
+		{
+			name: "p2sh-p2wpkh with extra scriptSig push",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := parseTxHex(p2shP2wpkhTxHex)
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[0].PreviousOutputHash), tx.Inputs[0].PreviousOutputIndex, NewOutput(1000000000, parseScriptHex("a9144733f37cf4db86fbc2efed2500b4f4e49f31202387")))
+				tx.Inputs[0].ScriptSig.Instructions = append([]script.Instruction{script.NewOpInstruction(script.OP_1)}, tx.Inputs[0].ScriptSig.Instructions...)
+				return tx, 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "p2wsh multisig",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				return p2wshTx(fetcher), 0
+			},
+			wantErr: false,
+		},
+		{
+			name: "p2wsh missing signature",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := p2wshTx(fetcher)
+				tx.Inputs[0].Witness[1] = []byte{}
+				return tx, 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "p2wsh witness script hash mismatch",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := p2wshTx(fetcher)
+				tx.Inputs[0].Witness[3] = append(tx.Inputs[0].Witness[3], script.OP_NOP)
+				return tx, 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "p2wsh empty witness",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := p2wshTx(fetcher)
+				tx.Inputs[0].Witness = nil
+				return tx, 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "unexpected witness on legacy input",
+			txGenerator: func(fetcher *MemoryOutputFetcher) (*Transaction, int) {
+				tx := parseTxHex(segwitTxHex)
+				fetcher.AddOutput(hex.EncodeToString(tx.Inputs[0].PreviousOutputHash), tx.Inputs[0].PreviousOutputIndex, NewOutput(625000000, parseScriptHex("2103c9f4836b9a4f77fc0d81f7bcb01b7f1b35916864b9476c241ce9fc198bd25432ac")))
+				tx.Inputs[0].Witness = [][]byte{{0x01}}
+				return tx, 0
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewMemoryOutputFetcher()
+			tx, index := tt.txGenerator(fetcher)
+			if err := tx.VerifyInput(index, fetcher); (err != nil) != tt.wantErr {
+				t.Errorf("Transaction.VerifyInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// NOTE: 実行されたOP_CODESEPARATORより前の部分は署名の対象外
+func TestTransaction_VerifyInputCodeSeparator(t *testing.T) {
+	key := privkey.NewPrivKey(big.NewInt(1003))
+	pubkey := script.NewPushInstruction(key.PubKey().Serialize(true))
+	newScript := func(instructions ...script.Instruction) *script.Script {
+		s := script.NewScript()
+		s.Instructions = instructions
+		return s
+	}
+	// NOTE: OP_NOP OP_CODESEPARATOR <pubkey> OP_CHECKSIG
+	codeSepScript := newScript(script.NewOpInstruction(script.OP_NOP), script.NewOpInstruction(script.OP_CODESEPARATOR), pubkey, script.NewOpInstruction(script.OP_CHECKSIG))
+	subScript := newScript(pubkey, script.NewOpInstruction(script.OP_CHECKSIG))
+	// NOTE: OP_0 OP_IF OP_CODESEPARATOR OP_ENDIF <pubkey> OP_CHECKSIG
+	unexecutedScript := newScript(script.NewOpInstruction(script.OP_0), script.NewOpInstruction(script.OP_IF), script.NewOpInstruction(script.OP_CODESEPARATOR), script.NewOpInstruction(script.OP_ENDIF), pubkey, script.NewOpInstruction(script.OP_CHECKSIG))
+
+	tests := []struct {
+		name         string
+		segwit       bool
+		script       *script.Script
+		signedScript *script.Script
+		wantErr      bool
+	}{
+		{name: "legacy signs script after code separator", script: codeSepScript, signedScript: subScript, wantErr: false},
+		{name: "legacy signs whole script", script: codeSepScript, signedScript: codeSepScript, wantErr: true},
+		{name: "legacy code separator in unexecuted branch", script: unexecutedScript, signedScript: unexecutedScript, wantErr: false},
+		{name: "p2wsh signs script after code separator", segwit: true, script: codeSepScript, signedScript: subScript, wantErr: false},
+		{name: "p2wsh signs whole script", segwit: true, script: codeSepScript, signedScript: codeSepScript, wantErr: true},
+		{name: "p2wsh code separator in unexecuted branch", segwit: true, script: unexecutedScript, signedScript: unexecutedScript, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewMemoryOutputFetcher()
+			input := NewInput(bytes.Repeat([]byte{0x22}, 32), 0, script.NewScript(), 0xffffffff)
+			tx := NewTransaction(2, []*Input{input}, []*Output{NewOutput(90000, parseScriptHex("76a9141c4bc762dd5423e332166702cb75f40df79fea1288ac"))}, 0, tt.segwit)
+			var z []byte
+			if tt.segwit {
+				rawScript, _ := tt.script.Serialize()
+				scriptHash := sha256.Sum256(rawScript)
+				fetcher.AddOutput(hex.EncodeToString(input.PreviousOutputHash), 0, NewOutput(100000, script.NewWitnessScriptPubkey(0, scriptHash[:])))
+				z, _ = tx.SigHashBIP143(0, script.SIGHASH_ALL, nil, tt.signedScript, fetcher)
+				sig := append(key.Sign(new(big.Int).SetBytes(z)).Serialize(), script.SIGHASH_ALL)
+				input.Witness = [][]byte{sig, rawScript}
+			} else {
+				fetcher.AddOutput(hex.EncodeToString(input.PreviousOutputHash), 0, NewOutput(100000, tt.script))
+				z, _ = tx.SigHash(0, script.SIGHASH_ALL, tt.signedScript, fetcher)
+				sig := append(key.Sign(new(big.Int).SetBytes(z)).Serialize(), script.SIGHASH_ALL)
+				input.ScriptSig = newScript(script.NewPushInstruction(sig))
+			}
+			if err := tx.VerifyInput(0, fetcher); (err != nil) != tt.wantErr {
+				t.Errorf("Transaction.VerifyInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// NOTE: legacyでは署名自身のpushをscriptCodeから取り除いてから署名ハッシュを計算する。segwitでは取り除かない
+func TestTransaction_VerifyInputFindAndDelete(t *testing.T) {
+	keys := []privkey.PrivKey{privkey.NewPrivKey(big.NewInt(1004)), privkey.NewPrivKey(big.NewInt(1005))}
+	pubkeys := []script.Instruction{script.NewPushInstruction(keys[0].PubKey().Serialize(true)), script.NewPushInstruction(keys[1].PubKey().Serialize(true))}
+	newScript := func(instructions ...script.Instruction) *script.Script {
+		s := script.NewScript()
+		s.Instructions = instructions
+		return s
+	}
+	// NOTE: 署名をscriptに含めても、取り除いた後のscriptに署名すれば循環しない
+	// NOTE: <sig> OP_DROP <pubkey> OP_CHECKSIG
+	checkSigScripts := func(sigs [][]byte) (*script.Script, *script.Script) {
+		signed := newScript(script.NewOpInstruction(script.OP_DROP), pubkeys[0], script.NewOpInstruction(script.OP_CHECKSIG))
+		if sigs == nil {
+			return signed, nil
+		}
+		return signed, newScript(script.NewPushInstruction(sigs[0]), script.NewOpInstruction(script.OP_DROP), pubkeys[0], script.NewOpInstruction(script.OP_CHECKSIG))
+	}
+	// NOTE: <sig 0> <sig 1> OP_2DROP OP_2 <pubkey 0> <pubkey 1> OP_2 OP_CHECKMULTISIG
+	// NOTE: 1つ目の署名を検証するときも、両方の署名が取り除かれたscriptCodeを使う
+	multiSigScripts := func(sigs [][]byte) (*script.Script, *script.Script) {
+		signed := newScript(script.NewOpInstruction(script.OP_2DROP), script.NewOpInstruction(script.OP_2), pubkeys[0], pubkeys[1], script.NewOpInstruction(script.OP_2), script.NewOpInstruction(script.OP_CHECKMULTISIG))
+		if sigs == nil {
+			return signed, nil
+		}
+		return signed, newScript(append([]script.Instruction{script.NewPushInstruction(sigs[0]), script.NewPushInstruction(sigs[1])}, signed.Instructions...)...)
+	}
+
+	tests := []struct {
+		name    string
+		segwit  bool
+		numSigs int
+		// NOTE: sigsがnilの場合は署名するscriptCodeを、そうでなければ署名を含むscriptを返す
+		scripts func(sigs [][]byte) (*script.Script, *script.Script)
+		wantErr bool
+	}{
+		{name: "legacy checksig with its own signature", numSigs: 1, scripts: checkSigScripts, wantErr: false},
+		{name: "legacy checkmultisig with its own signatures", numSigs: 2, scripts: multiSigScripts, wantErr: false},
+		{name: "p2wsh checksig does not remove signature", segwit: true, numSigs: 1, scripts: checkSigScripts, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewMemoryOutputFetcher()
+			input := NewInput(bytes.Repeat([]byte{0x33}, 32), 0, script.NewScript(), 0xffffffff)
+			tx := NewTransaction(2, []*Input{input}, []*Output{NewOutput(90000, parseScriptHex("76a9141c4bc762dd5423e332166702cb75f40df79fea1288ac"))}, 0, tt.segwit)
+			signedScript, _ := tt.scripts(nil)
+			// NOTE: 署名ハッシュはoutputの金額に依存するので、署名する前に登録しておく
+			fetcher.AddOutput(hex.EncodeToString(input.PreviousOutputHash), 0, NewOutput(100000, script.NewScript()))
+			var z []byte
+			if tt.segwit {
+				z, _ = tx.SigHashBIP143(0, script.SIGHASH_ALL, nil, signedScript, fetcher)
+			} else {
+				z, _ = tx.SigHash(0, script.SIGHASH_ALL, signedScript, fetcher)
+			}
+			sigs := make([][]byte, tt.numSigs)
+			for i := range sigs {
+				sigs[i] = append(keys[i].Sign(new(big.Int).SetBytes(z)).Serialize(), script.SIGHASH_ALL)
+			}
+			_, fullScript := tt.scripts(sigs)
+			fetcher = NewMemoryOutputFetcher()
+			if tt.segwit {
+				rawScript, _ := fullScript.Serialize()
+				scriptHash := sha256.Sum256(rawScript)
+				fetcher.AddOutput(hex.EncodeToString(input.PreviousOutputHash), 0, NewOutput(100000, script.NewWitnessScriptPubkey(0, scriptHash[:])))
+				input.Witness = [][]byte{sigs[0], rawScript}
+			} else {
+				fetcher.AddOutput(hex.EncodeToString(input.PreviousOutputHash), 0, NewOutput(100000, fullScript))
+				input.ScriptSig = script.NewScript()
+				if len(sigs) > 1 {
+					input.ScriptSig.Instructions = append(input.ScriptSig.Instructions, script.NewOpInstruction(script.OP_0))
+				}
+				for _, sig := range sigs {
+					input.ScriptSig.Instructions = append(input.ScriptSig.Instructions, script.NewPushInstruction(sig))
+				}
+			}
+			if err := tx.VerifyInput(0, fetcher); (err != nil) != tt.wantErr {
+				t.Errorf("Transaction.VerifyInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// NOTE: input 0はkey path、input 1は単一の署名のleaf、input 2はOP_CHECKSIGADDによる2-of-2のleafとannexを使う
+const taprootTxHex = "02000000000103aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa0000000000fdffffffbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb0100000000ffffffffcccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc0200000000feffffff03f0490200000000001600141d0f172a0ecb48aee1be1f2687d2963ae33f71a1a086010000000000225120a0bc500418095c97a82a1368d588e509bfda839117054752e6b8bcd850f8c66b409c0000000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac0140d1df491defbec0d370b2410d4ed9915b9e7c8b57678293d55c874ca7d854a28f7b5075845b35cdda051abd165d3600c3a04197ed09052072fdeab4f4c5b7ff8203411fae4767b619339c023f752375edd7cc5d93f8a4058083f45425d3b401d786e0509510978dfde04544f5688ae09ec20b15ca15a0df4a24835e7d7e9335505a2f012220ec6d499aefd540e90357f1004a136049d1f7df5ad99c44c46e3ed4169e40acb6ac41c0e5740e63bad28081ed7cf654dd6c19029ca03382fc05ab5f5dda81f2c55b845bdc49c4500654aecb71c070827cdfebe87dbf0b9db9643d4342abdbac14f72a53054009ece9ff7bf065ee29b7573c3b1b621651a07f88a85b77f75fdce29d63da06031ec3337ce38b24b8f31d4365e7f05c0a8bf92bfa6c1cb9aac04bb456bc9c99434126b8a5aad9804b34153c5a9ecf6bbbd4145a8c54760978bd73ff7a95cc7d003ce84c7a292b51f74d4df83176ffbf84b0b2db8ffc05f77749a687b85fd0b541d6834620ec6d499aefd540e90357f1004a136049d1f7df5ad99c44c46e3ed4169e40acb6ac2071550e6c83a9381f35c568d1a80e11fa3e0efc97dfd0e0f17492a2edb64c37a9ba529c41c0e5740e63bad28081ed7cf654dd6c19029ca03382fc05ab5f5dda81f2c55b845bb9a64f69c0d2bafe2df9d105e43586f9b6c535281dcf1420d55a60d37f9fa1580350010200000000"
+
+func newTaprootOutputFetcher(tx *Transaction) *MemoryOutputFetcher {
+	fetcher := NewMemoryOutputFetcher()
+	amounts := []uint64{100000, 120000, 80000}
+	scriptPubKeys := []string{
+		"5120a0bc500418095c97a82a1368d588e509bfda839117054752e6b8bcd850f8c66b",
+		"5120f1c34327cdc344cf1eeada5d311916495faffd4e157eafc9cee8e7561de85302",
+		"5120f1c34327cdc344cf1eeada5d311916495faffd4e157eafc9cee8e7561de85302",
+	}
+	for i, input := range tx.Inputs {
+		fetcher.AddOutput(hex.EncodeToString(input.PreviousOutputHash), input.PreviousOutputIndex, NewOutput(amounts[i], parseScriptHex(scriptPubKeys[i])))
+	}
+	return fetcher
+}
+
+func TestTransaction_SigHashTaproot(t *testing.T) {
+	leafHash, _ := hex.DecodeString("b9a64f69c0d2bafe2df9d105e43586f9b6c535281dcf1420d55a60d37f9fa158")
+	tests := []struct {
+		name     string
+		index    int
+		hashType uint32
+		leafHash []byte
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "key path sighash default",
+			index:    0,
+			hashType: 0x00,
+			leafHash: nil,
+			want:     "eca7009dc9654dc2b7dc6b7f0aa79e065d1fc35eb3f433decc586957b65316a4",
+		},
+		{
+			name:     "script path sighash all",
+			index:    1,
+			hashType: script.SIGHASH_ALL,
+			leafHash: leafHash,
+			want:     "c8676ef8d023a7506c704beaccf06fc9cfd1efbe9e5811c49978bacd72f34638",
+		},
+		{
+			name:     "invalid hash type",
+			index:    0,
+			hashType: 0x04,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := parseTxHex(taprootTxHex)
+			got, err := tx.SigHashTaproot(tt.index, tt.hashType, tt.leafHash, 0xffffffff, newTaprootOutputFetcher(tx))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transaction.SigHashTaproot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && hex.EncodeToString(got) != tt.want {
+				t.Errorf("Transaction.SigHashTaproot() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// NOTE: BIP341 wallet-test-vectors.json の keyPathSpending。2番目のoutputのscriptPubKeyはparseできないバイト列
+const bip341TxHex = "02000000097de20cbff686da83a54981d2b9bab3586f4ca7e48f57f5b55963115f3b334e9c010000000000000000d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd990000000000fffffffff8e1f583384333689228c5d28eac13366be082dc57441760d957275419a418420000000000fffffffff0689180aa63b30cb162a73c6d2a38b7eeda2a83ece74310fda0843ad604853b0100000000feffffffaa5202bdf6d8ccd2ee0f0202afbbb7461d9264a25e5bfd3c5a52ee1239e0ba6c0000000000feffffff956149bdc66faa968eb2be2d2faa29718acbfe3941215893a2a3446d32acd050000000000000000000e664b9773b88c09c32cb70a2a3e4da0ced63b7ba3b22f848531bbb1d5d5f4c94010000000000000000e9aa6b8e6c9de67619e6a3924ae25696bb7b694bb677a632a74ef7eadfd4eabf0000000000ffffffffa778eb6a263dc090464cd125c466b5a99667720b1c110468831d058aa1b82af10100000000ffffffff0200ca9a3b000000001976a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac807840cb0000000020ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b0065cd1d"
+
+func newBIP341OutputFetcher(tx *Transaction) *MemoryOutputFetcher {
+	fetcher := NewMemoryOutputFetcher()
+	amounts := []uint64{420000000, 462000000, 294000000, 504000000, 630000000, 378000000, 672000000, 546000000, 588000000}
+	scriptPubKeys := []string{
+		"512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343",
+		"5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+		"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac",
+		"5120e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e",
+		"512091b64d5324723a985170e4dc5a0f84c041804f2cd12660fa5dec09fc21783605",
+		"00147dd65592d0ab2fe0d0257d571abf032cd9db93dc",
+		"512075169f4001aa68f15bbed28b218df1d0a62cbbcf1188c6665110c293c907b831",
+		"5120712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5",
+		"512077e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220",
+	}
+	for i, input := range tx.Inputs {
+		fetcher.AddOutput(hex.EncodeToString(input.PreviousOutputHash), input.PreviousOutputIndex, NewOutput(amounts[i], parseScriptHex(scriptPubKeys[i])))
+	}
+	return fetcher
+}
+
+func TestTransaction_SigHashTaprootBIP341(t *testing.T) {
+	tests := []struct {
+		name     string
+		index    int
+		hashType uint32
+		annex    string
+		want     string
+		witness  string
+	}{
+		{
+			name:     "sighash single",
+			index:    0,
+			hashType: script.SIGHASH_SINGLE,
+			want:     "2514a6272f85cfa0f45eb907fcb0d121b808ed37c6ea160a5a9046ed5526d555",
+			witness:  "ed7c1647cb97379e76892be0cacff57ec4a7102aa24296ca39af7541246d8ff14d38958d4cc1e2e478e4d4a764bbfd835b16d4e314b72937b29833060b87276c03",
+		},
+		{
+			name:     "sighash single anyonecanpay",
+			index:    1,
+			hashType: script.SIGHASH_SINGLE | script.SIGHASH_ANYONECANPAY,
+			want:     "325a644af47e8a5a2591cda0ab0723978537318f10e6a63d4eed783b96a71a4d",
+			witness:  "052aedffc554b41f52b521071793a6b88d6dbca9dba94cf34c83696de0c1ec35ca9c5ed4ab28059bd606a4f3a657eec0bb96661d42921b5f50a95ad33675b54f83",
+		},
+		{
+			name:     "sighash all",
+			index:    3,
+			hashType: script.SIGHASH_ALL,
+			want:     "bf013ea93474aa67815b1b6cc441d23b64fa310911d991e713cd34c7f5d46669",
+			witness:  "ff45f742a876139946a149ab4d9185574b98dc919d2eb6754f8abaa59d18b025637a3aa043b91817739554f4ed2026cf8022dbd83e351ce1fabc272841d2510a01",
+		},
+		{
+			name:     "sighash default",
+			index:    4,
+			hashType: 0x00,
+			want:     "4f900a0bae3f1446fd48490c2958b5a023228f01661cda3496a11da502a7f7ef",
+			witness:  "b4010dd48a617db09926f729e79c33ae0b4e94b79f04a1ae93ede6315eb3669de185a17d2b0ac9ee09fd4c64b678a0b61a0a86fa888a273c8511be83bfd6810f",
+		},
+		{
+			name:     "sighash none",
+			index:    6,
+			hashType: script.SIGHASH_NONE,
+			want:     "15f25c298eb5cdc7eb1d638dd2d45c97c4c59dcaec6679cfc16ad84f30876b85",
+			witness:  "a3785919a2ce3c4ce26f298c3d51619bc474ae24014bcdd31328cd8cfbab2eff3395fa0a16fe5f486d12f22a9cedded5ae74feb4bbe5351346508c5405bcfee002",
+		},
+		{
+			name:     "sighash none anyonecanpay",
+			index:    7,
+			hashType: script.SIGHASH_NONE | script.SIGHASH_ANYONECANPAY,
+			want:     "cd292de50313804dabe4685e83f923d2969577191a3e1d2882220dca88cbeb10",
+			witness:  "ea0c6ba90763c2d3a296ad82ba45881abb4f426b3f87af162dd24d5109edc1cdd11915095ba47c3a9963dc1e6c432939872bc49212fe34c632cd3ab9fed429c482",
+		},
+		{
+			name:     "sighash all anyonecanpay",
+			index:    8,
+			hashType: script.SIGHASH_ALL | script.SIGHASH_ANYONECANPAY,
+			want:     "cccb739eca6c13a8a89e6e5cd317ffe55669bbda23f2fd37b0f18755e008edd2",
+			witness:  "bbc9584a11074e83bc8c6759ec55401f0ae7b03ef290c3139814f545b58a9f8127258000874f44bc46db7646322107d4d86aec8e73b8719a61fff761d75b5dd981",
+		},
+		{
+			// NOTE: 1番目のinputのsigMsgのspend_typeを1にし、input_indexの後にsha_annexを挿入したものから計算した値
+			name:     "sighash single with annex",
+			index:    0,
+			hashType: script.SIGHASH_SINGLE,
+			annex:    "50a1b2c3",
+			want:     "d4b57e9a4e0c4647f8a195a58405bad29297072aa01925a2d3560bd57fbd0e91",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := parseTxHex(bip341TxHex)
+			fetcher := newBIP341OutputFetcher(tx)
+			if tt.annex != "" {
+				// NOTE: annexを判定するには2つ以上の要素が必要なので、署名の代わりの要素を置く
+				annex, _ := hex.DecodeString(tt.annex)
+				tx.Inputs[tt.index].Witness = [][]byte{make([]byte, 64), annex}
+			}
+			got, err := tx.SigHashTaproot(tt.index, tt.hashType, nil, 0xffffffff, fetcher)
+			if err != nil {
+				t.Fatalf("Transaction.SigHashTaproot() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("Transaction.SigHashTaproot() = %x, want %v", got, tt.want)
+			}
+			if tt.witness == "" {
+				return
+			}
+			// NOTE: 期待されるwitnessの署名が、scriptPubKeyの出力鍵で検証できる
+			sig, _ := hex.DecodeString(tt.witness)
+			tx.Inputs[tt.index].Witness = [][]byte{sig}
+			tx.IsSegwit = true
+			if err := tx.VerifyInput(tt.index, fetcher); err != nil {
+				t.Errorf("Transaction.VerifyInput() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestTransaction_VerifyInputTaproot(t *testing.T) {
+	tests := []struct {
+		name       string
+		index      int
+		txModifier func(tx *Transaction)
+		wantErr    bool
+	}{
+		{
+			name:       "key path",
+			index:      0,
+			txModifier: func(tx *Transaction) {},
+			wantErr:    false,
+		},
+		{
+			name:       "script path single signature",
+			index:      1,
+			txModifier: func(tx *Transaction) {},
+			wantErr:    false,
+		},
+		{
+			name:       "script path checksigadd with annex",
+			index:      2,
+			txModifier: func(tx *Transaction) {},
+			wantErr:    false,
+		},
+		{
+			name:  "key path with modified output",
+			index: 0,
+			txModifier: func(tx *Transaction) {
+				tx.Outputs[0].Value -= 1
+			},
+			wantErr: true,
+		},
+		{
+			name:  "key path with explicit sighash default",
+			index: 0,
+			txModifier: func(tx *Transaction) {
+				tx.Inputs[0].Witness[0] = append(tx.Inputs[0].Witness[0], 0x00)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "script path with wrong merkle path",
+			index: 1,
+			txModifier: func(tx *Transaction) {
+				tx.Inputs[1].Witness[2] = append([]byte{}, tx.Inputs[1].Witness[2]...)
+				tx.Inputs[1].Witness[2][40] ^= 0x01
+			},
+			wantErr: true,
+		},
+		{
+			name:  "script path with wrong parity",
+			index: 1,
+			txModifier: func(tx *Transaction) {
+				tx.Inputs[1].Witness[2] = append([]byte{}, tx.Inputs[1].Witness[2]...)
+				tx.Inputs[1].Witness[2][0] ^= 0x01
+			},
+			wantErr: true,
+		},
+		{
+			name:  "script path with invalid signature",
+			index: 1,
+			txModifier: func(tx *Transaction) {
+				tx.Inputs[1].Witness[0] = append([]byte{}, tx.Inputs[1].Witness[0]...)
+				tx.Inputs[1].Witness[0][10] ^= 0x01
+			},
+			wantErr: true,
+		},
+		{
+			name:  "checksigadd with one empty signature",
+			index: 2,
+			txModifier: func(tx *Transaction) {
+				tx.Inputs[2].Witness[0] = []byte{}
+			},
+			wantErr: true,
+		},
+		{
+			name:  "annex is committed",
+			index: 2,
+			txModifier: func(tx *Transaction) {
+				tx.Inputs[2].Witness[4] = []byte{script.TAPROOT_ANNEX_TAG, 0x01, 0x03}
+			},
+			wantErr: true,
+		},
+		{
+			name:  "sighash default in checksigadd commits to all outputs",
+			index: 2,
+			txModifier: func(tx *Transaction) {
+				// NOTE: 2つ目の署名はSIGHASH_DEFAULTのため、1つ目の署名のみ有効なままとなる
+				tx.Outputs[0].Value -= 1
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := parseTxHex(taprootTxHex)
+			fetcher := newTaprootOutputFetcher(tx)
+			tt.txModifier(tx)
+			if err := tx.VerifyInput(tt.index, fetcher); (err != nil) != tt.wantErr {
+				t.Errorf("Transaction.VerifyInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileOutputFetcher_FetchOutput(t *testing.T) {
+	dir := t.TempDir()
+	raw, _ := hex.DecodeString(legacyTxHex)
+	tx, _ := ParseTransaction(bytes.NewReader(raw))
+	txid, _ := tx.ID()
+	os.WriteFile(filepath.Join(dir, txid+".hex"), []byte(legacyTxHex+"\n"), 0644)
+	// NOTE: txidと内容が一致しないファイル
+	os.WriteFile(filepath.Join(dir, strings.Repeat("00", 32)+".hex"), []byte(legacyTxHex), 0644)
+
+	tests := []struct {
+		name      string
+		txid      string
+		index     uint32
+		wantValue uint64
+		wantErr   bool
+	}{
+		{
+			name:      "stored transaction",
+			txid:      txid,
+			index:     1,
+			wantValue: 10011545,
+			wantErr:   false,
+		},
+		{
+			name:    "index out of range",
+			txid:    txid,
+			index:   2,
+			wantErr: true,
+		},
+		{
+			name:    "mismatched txid",
+			txid:    strings.Repeat("00", 32),
+			index:   0,
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			txid:    strings.Repeat("11", 32),
+			index:   0,
+			wantErr: true,
+		},
+		{
+			name:    "uppercase txid",
+			txid:    strings.ToUpper(txid),
+			index:   1,
+			wantErr: true,
+		},
+		{
+			name:    "path traversal",
+			txid:    "../" + filepath.Base(dir) + "/" + txid,
+			index:   1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewFileOutputFetcher(dir)
+			got, err := fetcher.FetchOutput(tt.txid, tt.index)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FileOutputFetcher.FetchOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Value != tt.wantValue {
+				t.Errorf("FileOutputFetcher.FetchOutput().Value = %v, want %v", got.Value, tt.wantValue)
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
+	"math/big"
 
 	"golang.org/x/crypto/ripemd160"
 )
@@ -30,6 +34,14 @@ func CompareBytes(a, b []byte) bool {
 	return true
 }
 
+func ReverseBytes(input []byte) []byte {
+	reversed := make([]byte, len(input))
+	for i := range input {
+		reversed[i] = input[len(input)-1-i]
+	}
+	return reversed
+}
+
 func Hash160(data []byte) []byte {
 	h := sha256.New()
 	h.Write(data)
@@ -51,6 +63,18 @@ func Hash256(data []byte) []byte {
 	return h.Sum(nil)
 }
 
+// NOTE: BIP340 タグごとに異なるハッシュ関数として使うため、タグのハッシュを2回前置する
+func TaggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
 func ParseVarInt(rader io.Reader) (uint64, error) {
 	buf := make([]byte, 1)
 	if _, err := io.ReadFull(rader, buf); err != nil {
@@ -80,6 +104,31 @@ func ParseVarInt(rader io.Reader) (uint64, error) {
 	}
 }
 
+// NOTE: 一度に確保する最大のバイト数。これより長い場合は読めた分だけ確保を広げる
+const readChunkSize = 1 << 16
+
+// NOTE: 入力から読んだ長さのバイト列を読む。長さを確保する前に信用しないように、実際に読めたバイト数に応じて確保する
+func ReadBytes(reader io.Reader, n uint64) ([]byte, error) {
+	if n <= readChunkSize {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("length is too large: %d", n)
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, reader, int64(n)); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 func SerializeVarInt(n uint64) ([]byte, error) {
 	if n < 0xfd {
 		return []byte{byte(n)}, nil
@@ -100,3 +149,13 @@ func SerializeVarInt(n uint64) ([]byte, error) {
 		return buf, nil
 	}
 }
+
+// NOTE: 秘密の値を保持していた big.Int のワードを上書きしてから0にする
+func ZeroBigInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	clear(words[:cap(words)])
+	x.SetInt64(0)
+}